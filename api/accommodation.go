@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"tickets/entities"
+)
+
+// AccommodationUnavailable is used when no accommodation service is configured,
+// the common clients don't include accommodation-api.
+// Hotel rooms can't be booked, so bundles with a hotel are rolled back instead of retrying the booking forever.
+type AccommodationUnavailable struct{}
+
+func (AccommodationUnavailable) BookHotelRoom(
+	ctx context.Context,
+	request entities.BookHotelRoomRequest,
+) (entities.BookHotelRoomResponse, error) {
+	return entities.BookHotelRoomResponse{}, entities.ErrHotelBookingUnavailable
+}
+
+// CancelHotelRoomBooking is never called, as no hotel room is booked.
+func (AccommodationUnavailable) CancelHotelRoomBooking(ctx context.Context, request entities.CancelHotelRoomBookingRequest) error {
+	return fmt.Errorf("can't cancel hotel booking %s: %w", request.HotelBookingID, entities.ErrHotelBookingUnavailable)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"tickets/entities"
+
+	"github.com/google/uuid"
+)
+
+type AccommodationMock struct {
+	lock sync.Mutex
+
+	HotelRoomBookings      []entities.BookHotelRoomRequest
+	CanceledHotelBookings  []uuid.UUID
+	hotelBookingsByRequest map[string]uuid.UUID
+}
+
+func (a *AccommodationMock) BookHotelRoom(
+	ctx context.Context,
+	request entities.BookHotelRoomRequest,
+) (entities.BookHotelRoomResponse, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.HotelRoomBookings = append(a.HotelRoomBookings, request)
+
+	if a.hotelBookingsByRequest == nil {
+		a.hotelBookingsByRequest = make(map[string]uuid.UUID)
+	}
+
+	bookingID, ok := a.hotelBookingsByRequest[request.IdempotencyKey]
+	if !ok {
+		bookingID = uuid.New()
+		a.hotelBookingsByRequest[request.IdempotencyKey] = bookingID
+	}
+
+	return entities.BookHotelRoomResponse{HotelBookingID: bookingID}, nil
+}
+
+func (a *AccommodationMock) CancelHotelRoomBooking(ctx context.Context, request entities.CancelHotelRoomBookingRequest) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.CanceledHotelBookings = append(a.CanceledHotelBookings, request.HotelBookingID)
+
+	return nil
+}
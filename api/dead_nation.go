@@ -8,6 +8,7 @@ import (
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/clients"
 	"github.com/ThreeDotsLabs/go-event-driven/common/clients/dead_nation"
+	"github.com/google/uuid"
 )
 
 type DeadNationClient struct {
@@ -16,7 +17,7 @@ type DeadNationClient struct {
 
 func NewDeadNationClient(clients *clients.Clients) *DeadNationClient {
 	if clients == nil {
-		panic("NewFilesApiClient: clients is nil")
+		panic("NewDeadNationClient: clients is nil")
 	}
 
 	return &DeadNationClient{clients: clients}
@@ -36,9 +37,24 @@ func (c DeadNationClient) BookInDeadNation(ctx context.Context, request entities
 		return fmt.Errorf("failed to book place in Dead Nation: %w", err)
 	}
 
-	if resp.StatusCode() != http.StatusOK {
+	switch {
+	case resp.StatusCode() == http.StatusOK:
+		return nil
+	case isPermanentRejection(resp.StatusCode()):
+		return fmt.Errorf("%w: status code %d: %s", entities.ErrDeadNationBookingRejected, resp.StatusCode(), resp.Body)
+	default:
 		return fmt.Errorf("unexpected status code from dead nation: %d", resp.StatusCode())
 	}
+}
+
+// CancelInDeadNation returns entities.ErrDeadNationCancellationNotSupported,
+// the Dead Nation API offers only POST /ticket-booking, so bookings are canceled there manually.
+func (c DeadNationClient) CancelInDeadNation(ctx context.Context, bookingID uuid.UUID) error {
+	return fmt.Errorf("%w: booking %s", entities.ErrDeadNationCancellationNotSupported, bookingID)
+}
 
-	return nil
+// isPermanentRejection returns true for responses which won't change when the booking is retried.
+// Other errors may be temporary, so they are retried until the message is moved to the poison queue.
+func isPermanentRejection(statusCode int) bool {
+	return statusCode == http.StatusConflict || statusCode == http.StatusUnprocessableEntity
 }
@@ -2,21 +2,42 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"tickets/entities"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
 )
 
 type DeadNationMock struct {
 	lock sync.Mutex
 
 	DeadNationBookings []entities.DeadNationBooking
+	CanceledBookings   []uuid.UUID
+
+	// RejectedEventIDs simulates Dead Nation events which reject every booking, like sold out shows
+	RejectedEventIDs []uuid.UUID
 }
 
 func (c *DeadNationMock) BookInDeadNation(ctx context.Context, request entities.DeadNationBooking) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	if lo.Contains(c.RejectedEventIDs, request.DeadNationEventID) {
+		return fmt.Errorf("%w: event %s is sold out", entities.ErrDeadNationBookingRejected, request.DeadNationEventID)
+	}
+
 	c.DeadNationBookings = append(c.DeadNationBookings, request)
 
 	return nil
 }
+
+func (c *DeadNationMock) CancelInDeadNation(ctx context.Context, bookingID uuid.UUID) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.CanceledBookings = append(c.CanceledBookings, bookingID)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"tickets/entities"
+)
+
+// FixedExchangeRates are exchange rates configured upfront, like "EUR/USD=1.08,GBP/USD=1.27".
+// It's meant to be replaced by a client of a rates provider when reports need current rates.
+type FixedExchangeRates struct {
+	rates map[string]entities.Decimal
+}
+
+func NewFixedExchangeRates(rates map[string]entities.Decimal) FixedExchangeRates {
+	return FixedExchangeRates{rates: rates}
+}
+
+// ParseFixedExchangeRates parses comma separated rates in the "FROM/TO=rate" format.
+func ParseFixedExchangeRates(value string) (FixedExchangeRates, error) {
+	rates := map[string]entities.Decimal{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		pair, rateValue, ok := strings.Cut(entry, "=")
+		if !ok {
+			return FixedExchangeRates{}, fmt.Errorf("invalid exchange rate %q, expected FROM/TO=rate", entry)
+		}
+
+		from, to, ok := strings.Cut(pair, "/")
+		if !ok {
+			return FixedExchangeRates{}, fmt.Errorf("invalid currency pair %q, expected FROM/TO", pair)
+		}
+		for _, currency := range []string{from, to} {
+			if _, ok := entities.CurrencyMinorUnits(currency); !ok {
+				return FixedExchangeRates{}, fmt.Errorf("invalid currency %q in exchange rate %q", currency, entry)
+			}
+		}
+
+		rate, err := entities.ParseDecimal(rateValue)
+		if err != nil {
+			return FixedExchangeRates{}, fmt.Errorf("invalid exchange rate %q: %w", entry, err)
+		}
+		if rate.Sign() <= 0 {
+			return FixedExchangeRates{}, fmt.Errorf("exchange rate %q is not positive", entry)
+		}
+
+		rates[from+"/"+to] = rate
+	}
+
+	return NewFixedExchangeRates(rates), nil
+}
+
+func (r FixedExchangeRates) ExchangeRate(ctx context.Context, from string, to string) (entities.Decimal, error) {
+	if strings.EqualFold(from, to) {
+		return entities.NewDecimal(1, 0), nil
+	}
+
+	rate, ok := r.rates[strings.ToUpper(from)+"/"+strings.ToUpper(to)]
+	if !ok {
+		return entities.Decimal{}, fmt.Errorf("no exchange rate from %s to %s", from, to)
+	}
+
+	return rate, nil
+}
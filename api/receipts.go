@@ -24,7 +24,7 @@ func (c ReceiptsServiceClient) IssueReceipt(ctx context.Context, request entitie
 	resp, err := c.clients.Receipts.PutReceiptsWithResponse(ctx, receipts.CreateReceipt{
 		IdempotencyKey: &request.IdempotencyKey,
 		Price: receipts.Money{
-			MoneyAmount:   request.Price.Amount,
+			MoneyAmount:   request.Price.Amount.String(),
 			MoneyCurrency: request.Price.Currency,
 		},
 		TicketId: request.TicketID,
@@ -101,3 +101,21 @@ func (t TransportationClient) CancelFlightTickets(ctx context.Context, request e
 
 	return nil
 }
+
+func (t TransportationClient) CancelTaxiBooking(ctx context.Context, request entities.CancelTaxiBookingRequest) error {
+	resp, err := t.clients.Transportation.DeleteTaxiBookingBookingIdWithResponse(ctx, request.TaxiBookingID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel taxi booking: %w", err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusNoContent:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unexpected status code for DELETE transportation-api/transportation/taxi-booking for booking %s: %d",
+			request.TaxiBookingID,
+			resp.StatusCode(),
+		)
+	}
+}
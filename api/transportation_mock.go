@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"tickets/entities"
+
+	"github.com/google/uuid"
+)
+
+// TransportationMock records requests and, like the real service, returns the same booking for a repeated idempotency key.
+type TransportationMock struct {
+	lock sync.Mutex
+
+	FlightBookings        []entities.BookFlightTicketRequest
+	TaxiBookings          []entities.BookTaxiRequest
+	CanceledFlightTickets []uuid.UUID
+	CanceledTaxiBookings  []uuid.UUID
+
+	flightTickets map[string][]uuid.UUID
+	taxiBookings  map[string]uuid.UUID
+}
+
+func (t *TransportationMock) BookFlight(
+	ctx context.Context,
+	request entities.BookFlightTicketRequest,
+) (entities.BookFlightTicketResponse, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.FlightBookings = append(t.FlightBookings, request)
+
+	if t.flightTickets == nil {
+		t.flightTickets = make(map[string][]uuid.UUID)
+	}
+
+	ticketIDs, ok := t.flightTickets[request.IdempotencyKey]
+	if !ok {
+		for range request.PassengerNames {
+			ticketIDs = append(ticketIDs, uuid.New())
+		}
+		t.flightTickets[request.IdempotencyKey] = ticketIDs
+	}
+
+	return entities.BookFlightTicketResponse{TicketIds: ticketIDs}, nil
+}
+
+func (t *TransportationMock) BookTaxi(ctx context.Context, request entities.BookTaxiRequest) (entities.BookTaxiResponse, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.TaxiBookings = append(t.TaxiBookings, request)
+
+	if t.taxiBookings == nil {
+		t.taxiBookings = make(map[string]uuid.UUID)
+	}
+
+	bookingID, ok := t.taxiBookings[request.IdempotencyKey]
+	if !ok {
+		bookingID = uuid.New()
+		t.taxiBookings[request.IdempotencyKey] = bookingID
+	}
+
+	return entities.BookTaxiResponse{TaxiBookingId: bookingID}, nil
+}
+
+func (t *TransportationMock) CancelFlightTickets(ctx context.Context, request entities.CancelFlightTicketsRequest) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.CanceledFlightTickets = append(t.CanceledFlightTickets, request.TicketIds...)
+
+	return nil
+}
+
+func (t *TransportationMock) CancelTaxiBooking(ctx context.Context, request entities.CancelTaxiBookingRequest) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.CanceledTaxiBookings = append(t.CanceledTaxiBookings, request.TaxiBookingID)
+
+	return nil
+}
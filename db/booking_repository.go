@@ -5,11 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"tickets/db/util"
 	"tickets/entities"
 	"tickets/message/events"
 	"tickets/message/events/outbox"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type BookingRepository struct {
@@ -27,9 +30,25 @@ func NewBookingRepository(db *sqlx.DB) BookingRepository {
 var (
 	ErrBookingAlreadyExists = errors.New("booking already exists")
 	ErrNoPlacesLeft         = errors.New("no places left")
+	ErrBookingNotFound      = errors.New("booking not found")
+	ErrSeatsNotAvailable    = errors.New("seats not available")
+	ErrShowHasNoSeatMap     = errors.New("show has no seat map")
+	ErrUnknownPriceCategory = errors.New("unknown price category")
 )
 
-func (b BookingRepository) Add(ctx context.Context, booking entities.Booking) error {
+const bookingColumns = `
+	booking_id,
+	show_id,
+	number_of_tickets,
+	customer_email,
+	canceled_at,
+	refunded_tickets,
+	coalesce(price_category, '') AS price_category,
+	ticket_price_amount AS "ticket_price.amount",
+	coalesce(ticket_price_currency, '') AS "ticket_price.currency"
+`
+
+func (b BookingRepository) Add(ctx context.Context, booking entities.Booking) (err error) {
 	tx, err := b.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
 		return fmt.Errorf("could not begin transaction: %w", err)
@@ -44,45 +63,82 @@ func (b BookingRepository) Add(ctx context.Context, booking entities.Booking) er
 		err = tx.Commit()
 	}()
 
-	availableSeats := 0
-	err = tx.GetContext(ctx, &availableSeats, `
+	return b.add(ctx, tx, booking)
+}
+
+// add stores the booking in tx, which must be serializable, so concurrent bookings can't overbook the show.
+// The show is locked FOR SHARE, so it can't be canceled before the booking is committed.
+func (b BookingRepository) add(ctx context.Context, tx *sqlx.Tx, booking entities.Booking) error {
+	var show struct {
+		AvailableSeats  int                      `db:"available_seats"`
+		PriceCategories entities.PriceCategories `db:"price_categories"`
+		Canceled        bool                     `db:"canceled"`
+	}
+	err := tx.GetContext(ctx, &show, `
 		SELECT
-		    number_of_tickets AS available_seats
+		    number_of_tickets AS available_seats,
+		    price_categories,
+		    canceled_at IS NOT NULL AS canceled
 		FROM
 		    shows
 		WHERE
 		    show_id = $1
+		FOR SHARE
 	`, booking.ShowID)
 	if err != nil {
 		return fmt.Errorf("could not get available seats: %w", err)
 	}
 
-	alreadyBookedSeats := 0
-	err = tx.GetContext(ctx, &alreadyBookedSeats, `
-		SELECT
-		    coalesce(SUM(number_of_tickets), 0) AS already_booked_seats
-		FROM
-		    bookings
-		WHERE
-		    show_id = $1
-	`, booking.ShowID)
+	if show.Canceled {
+		return ErrShowCanceled
+	}
+
+	// the price is always computed from the show, prices sent by the customer are not trusted
+	if len(show.PriceCategories) > 0 {
+		category, ok := show.PriceCategories.Find(booking.PriceCategory)
+		if !ok {
+			return ErrUnknownPriceCategory
+		}
+		booking.PriceCategory = category.Name
+		booking.TicketPrice = category.Price
+	} else if booking.PriceCategory != "" {
+		return ErrUnknownPriceCategory
+	}
+
+	alreadyTakenSeats, err := takenSeats(ctx, tx, booking.ShowID)
 	if err != nil {
-		return fmt.Errorf("could not get already booked seats: %w", err)
+		return err
 	}
 
-	if availableSeats-alreadyBookedSeats < booking.NumberOfTickets {
+	if show.AvailableSeats-alreadyTakenSeats < booking.NumberOfTickets {
 		return ErrNoPlacesLeft
 	}
 
 	_, err = tx.NamedExecContext(ctx, `
 		INSERT INTO 
-		    bookings (booking_id, show_id, number_of_tickets, customer_email) 
-		VALUES (:booking_id, :show_id, :number_of_tickets, :customer_email)
+		    bookings (
+		        booking_id, show_id, number_of_tickets, customer_email,
+		        price_category, ticket_price_amount, ticket_price_currency
+		    ) 
+		VALUES (
+		    :booking_id, :show_id, :number_of_tickets, :customer_email,
+		    NULLIF(:price_category, ''),
+		    (CASE WHEN :price_category = '' THEN NULL ELSE :ticket_price.amount END)::numeric,
+		    NULLIF(:ticket_price.currency, '')
+		)
 		`, booking)
 	if isErrorUniqueViolation(err) {
 		// now AddBooking is called via Pub/Sub, we are taking into account at-least-once delivery
 		return ErrBookingAlreadyExists
 	}
+	if err != nil {
+		return fmt.Errorf("could not add booking: %w", err)
+	}
+
+	booking.Seats, err = b.allocateSeats(ctx, tx, booking)
+	if err != nil {
+		return err
+	}
 
 	outboxPublisher, err := outbox.NewPublisherForDb(ctx, tx)
 	if err != nil {
@@ -95,6 +151,9 @@ func (b BookingRepository) Add(ctx context.Context, booking entities.Booking) er
 		NumberOfTickets: booking.NumberOfTickets,
 		CustomerEmail:   booking.CustomerEmail,
 		ShowId:          booking.ShowID,
+		Seats:           booking.Seats,
+		PriceCategory:   booking.PriceCategory,
+		TicketPrice:     ticketPrice(booking),
 	})
 	if err != nil {
 		return fmt.Errorf("could not publish event: %w", err)
@@ -102,3 +161,428 @@ func (b BookingRepository) Add(ctx context.Context, booking entities.Booking) er
 
 	return nil
 }
+
+// takenSeats returns the number of seats of not canceled bookings, except refunded tickets, and seats held for waitlist offers.
+func takenSeats(ctx context.Context, tx *sqlx.Tx, showID uuid.UUID) (int, error) {
+	seats := 0
+	err := tx.GetContext(ctx, &seats, `
+		SELECT
+		    (
+		        SELECT coalesce(SUM(number_of_tickets - refunded_tickets), 0)
+		        FROM bookings
+		        WHERE show_id = $1 AND canceled_at IS NULL
+		    ) + (
+		        SELECT coalesce(SUM(number_of_tickets), 0)
+		        FROM waitlist_entries
+		        WHERE show_id = $1 AND status = $2 AND offer_expires_at > now()
+		    )
+	`, showID, entities.WaitlistEntryOffered)
+	if err != nil {
+		return 0, fmt.Errorf("could not get taken seats: %w", err)
+	}
+
+	return seats, nil
+}
+
+// allocateSeats assigns seats of shows with a seat map to the booking.
+// Seats chosen by the customer are allocated only if all of them are free, otherwise free seats are allocated
+// in the seat map order, keeping accessible seats for the last.
+// A seat can be allocated only while it has no booking, so concurrent bookings can't get the same seat.
+func (b BookingRepository) allocateSeats(ctx context.Context, tx *sqlx.Tx, booking entities.Booking) ([]entities.Seat, error) {
+	showSeats := 0
+	err := tx.GetContext(ctx, &showSeats, `SELECT count(*) FROM show_seats WHERE show_id = $1`, booking.ShowID)
+	if err != nil {
+		return nil, fmt.Errorf("could not count show seats: %w", err)
+	}
+
+	if showSeats == 0 {
+		if len(booking.Seats) > 0 {
+			return nil, ErrShowHasNoSeatMap
+		}
+
+		// general admission
+		return nil, nil
+	}
+
+	var seats []entities.Seat
+	if len(booking.Seats) > 0 {
+		var sections, rows, numbers []string
+		for _, seat := range booking.Seats {
+			sections = append(sections, seat.Section)
+			rows = append(rows, seat.Row)
+			numbers = append(numbers, seat.Number)
+		}
+
+		err = tx.SelectContext(ctx, &seats, `
+			UPDATE
+			    show_seats
+			SET
+			    booking_id = $1
+			WHERE
+			    show_id = $2
+			    AND booking_id IS NULL
+			    AND (seat_section, seat_row, seat_number) IN (
+			        SELECT * FROM unnest($3::text[], $4::text[], $5::text[])
+			    )
+			RETURNING
+			    seat_section, seat_row, seat_number, accessible
+		`, booking.BookingID, booking.ShowID, pq.Array(sections), pq.Array(rows), pq.Array(numbers))
+		if err != nil {
+			return nil, fmt.Errorf("could not allocate chosen seats: %w", err)
+		}
+
+		if len(seats) != len(booking.Seats) {
+			return nil, ErrSeatsNotAvailable
+		}
+
+		return seats, nil
+	}
+
+	err = tx.SelectContext(ctx, &seats, `
+		UPDATE
+		    show_seats
+		SET
+		    booking_id = $1
+		WHERE
+		    show_id = $2
+		    AND position IN (
+		        SELECT
+		            position
+		        FROM
+		            show_seats
+		        WHERE
+		            show_id = $2 AND booking_id IS NULL
+		        ORDER BY
+		            accessible, position
+		        LIMIT $3
+		    )
+		RETURNING
+		    seat_section, seat_row, seat_number, accessible
+	`, booking.BookingID, booking.ShowID, booking.NumberOfTickets)
+	if err != nil {
+		return nil, fmt.Errorf("could not allocate seats: %w", err)
+	}
+
+	if len(seats) != booking.NumberOfTickets {
+		return nil, ErrNoPlacesLeft
+	}
+
+	return seats, nil
+}
+
+func (b BookingRepository) findSeats(ctx context.Context, bookingID uuid.UUID) ([]entities.Seat, error) {
+	var seats []entities.Seat
+	err := sqlx.SelectContext(ctx, util.Executor(ctx, b.db), &seats, `
+		SELECT
+		    seat_section, seat_row, seat_number, accessible
+		FROM
+		    show_seats
+		WHERE
+		    booking_id = $1
+		ORDER BY
+		    position
+	`, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get booking seats: %w", err)
+	}
+
+	return seats, nil
+}
+
+func (b BookingRepository) FindByID(ctx context.Context, bookingID uuid.UUID) (entities.Booking, error) {
+	var booking entities.Booking
+	err := sqlx.GetContext(ctx, util.Executor(ctx, b.db), &booking, `
+		SELECT
+		    `+bookingColumns+`
+		FROM
+		    bookings
+		WHERE
+		    booking_id = $1
+	`, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.Booking{}, ErrBookingNotFound
+	}
+	if err != nil {
+		return entities.Booking{}, fmt.Errorf("could not get booking: %w", err)
+	}
+
+	booking.Seats, err = b.findSeats(ctx, bookingID)
+	if err != nil {
+		return entities.Booking{}, err
+	}
+
+	return booking, nil
+}
+
+// IsCanceled returns false for bookings which were not made by us.
+func (b BookingRepository) IsCanceled(ctx context.Context, bookingID uuid.UUID) (bool, error) {
+	var canceled bool
+	err := sqlx.GetContext(ctx, util.Executor(ctx, b.db), &canceled, `
+		SELECT canceled_at IS NOT NULL FROM bookings WHERE booking_id = $1
+	`, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not get booking: %w", err)
+	}
+
+	return canceled, nil
+}
+
+// AssignTicketSeat returns the seat of the ticket. The first time it's called for the ticket,
+// the ticket gets the first seat of its booking which has no ticket yet.
+// It returns false if the booking has no seats left for the ticket, for example because its show has no seat map.
+func (b BookingRepository) AssignTicketSeat(ctx context.Context, bookingID uuid.UUID, ticketID uuid.UUID) (entities.Seat, bool, error) {
+	var seat entities.Seat
+	var assigned bool
+
+	err := util.UpdateInTx(
+		ctx,
+		b.db,
+		sql.LevelReadCommitted,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			err := tx.GetContext(ctx, &seat, `
+				SELECT
+				    seat_section, seat_row, seat_number, accessible
+				FROM
+				    show_seats
+				WHERE
+				    ticket_id = $1
+			`, ticketID)
+			if err == nil {
+				assigned = true
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("could not get ticket seat: %w", err)
+			}
+
+			// seats locked by other tickets of the booking are skipped, so each ticket gets a different seat
+			err = tx.GetContext(ctx, &seat, `
+				UPDATE
+				    show_seats
+				SET
+				    ticket_id = $2
+				WHERE
+				    (show_id, seat_section, seat_row, seat_number) = (
+				        SELECT
+				            show_id, seat_section, seat_row, seat_number
+				        FROM
+				            show_seats
+				        WHERE
+				            booking_id = $1 AND ticket_id IS NULL
+				        ORDER BY
+				            position
+				        LIMIT 1
+				        FOR UPDATE SKIP LOCKED
+				    )
+				RETURNING
+				    seat_section, seat_row, seat_number, accessible
+			`, bookingID, ticketID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not assign seat to ticket: %w", err)
+			}
+
+			assigned = true
+
+			return nil
+		},
+	)
+	if err != nil {
+		return entities.Seat{}, false, err
+	}
+
+	return seat, assigned, nil
+}
+
+// FindByShowID returns bookings of the show which weren't canceled.
+func (b BookingRepository) FindByShowID(ctx context.Context, showID uuid.UUID) ([]entities.Booking, error) {
+	var bookings []entities.Booking
+	err := sqlx.SelectContext(ctx, util.Executor(ctx, b.db), &bookings, `
+		SELECT
+		    `+bookingColumns+`
+		FROM
+		    bookings
+		WHERE
+		    show_id = $1 AND canceled_at IS NULL
+	`, showID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get show bookings: %w", err)
+	}
+
+	return bookings, nil
+}
+
+// Cancel marks the booking as canceled, so its seats are available again.
+// Canceling an already canceled booking does nothing, BookingCanceled_v1 is published only once.
+func (b BookingRepository) Cancel(ctx context.Context, bookingID uuid.UUID) error {
+	return util.UpdateInTx(
+		ctx,
+		b.db,
+		sql.LevelReadCommitted,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var booking entities.Booking
+			err := tx.GetContext(ctx, &booking, `
+				UPDATE
+				    bookings
+				SET
+				    canceled_at = now()
+				WHERE
+				    booking_id = $1 AND canceled_at IS NULL
+				RETURNING
+				    `+bookingColumns+`
+			`, bookingID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// the booking doesn't exist or it was already canceled
+				_, err = b.FindByID(ctx, bookingID)
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("could not cancel booking: %w", err)
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE show_seats SET booking_id = NULL, ticket_id = NULL WHERE booking_id = $1`, bookingID)
+			if err != nil {
+				return fmt.Errorf("could not release booking seats: %w", err)
+			}
+
+			outboxPublisher, err := outbox.NewPublisherForDb(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("could not create outbox publisher: %w", err)
+			}
+
+			err = events.NewEventBus(outboxPublisher).Publish(ctx, entities.BookingCanceled_v1{
+				Header:          entities.NewEventHeader(),
+				BookingID:       booking.BookingID,
+				NumberOfTickets: booking.NumberOfTickets,
+				CustomerEmail:   booking.CustomerEmail,
+				ShowId:          booking.ShowID,
+			})
+			if err != nil {
+				return fmt.Errorf("could not publish event: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
+
+// AddRefundedTicket records the refund of the ticket in its booking, releases the seat of the ticket
+// and returns the booking. The seat of the refunded ticket can be booked again.
+// It returns false if the ticket has no booking or its refund was already recorded,
+// the booking is still returned when the refund was already recorded.
+// Refunds of tickets of the same booking update the same row, so concurrent refunds don't miss each other.
+func (b BookingRepository) AddRefundedTicket(ctx context.Context, ticketID string) (entities.Booking, bool, error) {
+	var booking entities.Booking
+	var recorded bool
+
+	err := util.UpdateInTx(
+		ctx,
+		b.db,
+		sql.LevelRepeatableRead,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var bookingID uuid.UUID
+			err := tx.GetContext(ctx, &bookingID, `
+				UPDATE
+				    tickets
+				SET
+				    refunded_at = now()
+				WHERE
+				    ticket_id = $1 AND refunded_at IS NULL AND booking_id IS NOT NULL
+				RETURNING
+				    booking_id
+			`, ticketID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return b.findRefundedTicketBooking(ctx, tx, ticketID, &booking)
+			}
+			if err != nil {
+				return fmt.Errorf("could not mark ticket as refunded: %w", err)
+			}
+
+			err = tx.GetContext(ctx, &booking, `
+				UPDATE
+				    bookings
+				SET
+				    refunded_tickets = refunded_tickets + 1
+				WHERE
+				    booking_id = $1
+				RETURNING
+				    `+bookingColumns+`
+			`, bookingID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// tickets can be confirmed for bookings which were not made by us
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not add refunded ticket to booking: %w", err)
+			}
+
+			// the seat of the ticket is released, or any seat of the booking if the ticket wasn't printed yet
+			_, err = tx.ExecContext(ctx, `
+				UPDATE
+				    show_seats
+				SET
+				    booking_id = NULL, ticket_id = NULL
+				WHERE
+				    (show_id, seat_section, seat_row, seat_number) = (
+				        SELECT
+				            show_id, seat_section, seat_row, seat_number
+				        FROM
+				            show_seats
+				        WHERE
+				            booking_id = $1 AND (ticket_id = $2 OR ticket_id IS NULL)
+				        ORDER BY
+				            ticket_id IS NULL, position DESC
+				        LIMIT 1
+				        FOR UPDATE
+				    )
+			`, bookingID, ticketID)
+			if err != nil {
+				return fmt.Errorf("could not release seat of refunded ticket: %w", err)
+			}
+
+			recorded = true
+
+			return nil
+		},
+	)
+	if err != nil {
+		return entities.Booking{}, false, err
+	}
+
+	return booking, recorded, nil
+}
+
+// findRefundedTicketBooking finds the booking of a ticket which refund was already recorded.
+// The booking stays empty if the ticket wasn't refunded or has no booking.
+func (b BookingRepository) findRefundedTicketBooking(ctx context.Context, tx *sqlx.Tx, ticketID string, booking *entities.Booking) error {
+	err := tx.GetContext(ctx, booking, `
+		SELECT
+		    `+bookingColumns+`
+		FROM
+		    bookings
+		WHERE
+		    booking_id = (SELECT booking_id FROM tickets WHERE ticket_id = $1 AND refunded_at IS NOT NULL)
+	`, ticketID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get booking of refunded ticket: %w", err)
+	}
+
+	return nil
+}
+
+// ticketPrice returns nil for bookings of shows without price categories.
+func ticketPrice(booking entities.Booking) *entities.Money {
+	if booking.PriceCategory == "" {
+		return nil
+	}
+
+	return &booking.TicketPrice
+}
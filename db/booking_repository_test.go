@@ -55,6 +55,91 @@ func TestBookingsRepository_AddBooking_seats_limit(t *testing.T) {
 		requireNotEnoughSeatsError(t, err)
 	})
 
+	t.Run("cancel_releases_seats", func(t *testing.T) {
+		showID := uuid.New()
+
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 2,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Exmaple vanue",
+		})
+		require.NoError(t, err)
+
+		bookingID := uuid.New()
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       bookingID,
+			ShowID:          showID,
+			NumberOfTickets: 2,
+			CustomerEmail:   "foo@bar.com",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, bookingsRepo.Cancel(ctx, bookingID))
+		// canceling twice is a no-op
+		require.NoError(t, bookingsRepo.Cancel(ctx, bookingID))
+
+		booking, err := bookingsRepo.FindByID(ctx, bookingID)
+		require.NoError(t, err)
+		assert.NotNil(t, booking.CanceledAt)
+
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       uuid.New(),
+			ShowID:          showID,
+			NumberOfTickets: 2,
+			CustomerEmail:   "foo@bar.com",
+		})
+		require.NoError(t, err)
+
+		require.ErrorIs(t, bookingsRepo.Cancel(ctx, uuid.New()), ErrBookingNotFound)
+	})
+
+	t.Run("price_category", func(t *testing.T) {
+		showID := uuid.New()
+
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 10,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Exmaple vanue",
+			PriceCategories: entities.PriceCategories{
+				{Name: "standard", Price: entities.MustNewMoney("50.00", "EUR")},
+				{Name: "student", Price: entities.MustNewMoney("25.50", "EUR")},
+			},
+		})
+		require.NoError(t, err)
+
+		bookingID := uuid.New()
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       bookingID,
+			ShowID:          showID,
+			NumberOfTickets: 2,
+			CustomerEmail:   "foo@bar.com",
+			PriceCategory:   "student",
+			// the price is computed from the show
+			TicketPrice: entities.MustNewMoney("0.01", "EUR"),
+		})
+		require.NoError(t, err)
+
+		booking, err := bookingsRepo.FindByID(ctx, bookingID)
+		require.NoError(t, err)
+		assert.Equal(t, "student", booking.PriceCategory)
+		assert.True(t, entities.MustNewMoney("25.50", "EUR").Equal(booking.TicketPrice), booking.TicketPrice.String())
+
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       uuid.New(),
+			ShowID:          showID,
+			NumberOfTickets: 1,
+			CustomerEmail:   "foo@bar.com",
+			PriceCategory:   "vip",
+		})
+		require.ErrorIs(t, err, ErrUnknownPriceCategory)
+	})
+
 	t.Run("parallel_overbooking", func(t *testing.T) {
 		showID := uuid.New()
 
@@ -119,6 +204,395 @@ func TestBookingsRepository_AddBooking_seats_limit(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("parallel_overbooking_seats", func(t *testing.T) {
+		showID := uuid.New()
+
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 4,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Exmaple vanue",
+			SeatMap:         testSeatMap(),
+		})
+		require.NoError(t, err)
+
+		chosenSeats := []entities.Seat{
+			{Section: "A", Row: "1", Number: "2"},
+			{Section: "A", Row: "2", Number: "1"},
+		}
+
+		succeeded := bookInParallel(t, 50, func() entities.Booking {
+			return entities.Booking{
+				BookingID:       uuid.New(),
+				ShowID:          showID,
+				NumberOfTickets: len(chosenSeats),
+				CustomerEmail:   "foo@bar.com",
+				Seats:           chosenSeats,
+			}
+		})
+		require.Len(t, succeeded, 1)
+
+		booking, err := bookingsRepo.FindByID(ctx, succeeded[0])
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []entities.Seat{
+			{Section: "A", Row: "1", Number: "2"},
+			{Section: "A", Row: "2", Number: "1", Accessible: true},
+		}, booking.Seats)
+	})
+
+	t.Run("parallel_seat_allocation", func(t *testing.T) {
+		showID := uuid.New()
+
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 4,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Exmaple vanue",
+			SeatMap:         testSeatMap(),
+		})
+		require.NoError(t, err)
+
+		// conflicting workers are rolled back, so they are retried like a redelivered message
+		allocatedSeats := map[entities.Seat]uuid.UUID{}
+		for len(allocatedSeats) < 4 {
+			succeeded := bookInParallel(t, 10, func() entities.Booking {
+				return entities.Booking{
+					BookingID:       uuid.New(),
+					ShowID:          showID,
+					NumberOfTickets: 1,
+					CustomerEmail:   "foo@bar.com",
+				}
+			})
+
+			for _, bookingID := range succeeded {
+				booking, err := bookingsRepo.FindByID(ctx, bookingID)
+				require.NoError(t, err)
+				require.Len(t, booking.Seats, 1)
+
+				seat := booking.Seats[0]
+				require.NotContains(t, allocatedSeats, seat, "seat allocated twice")
+				allocatedSeats[seat] = bookingID
+			}
+
+			if len(allocatedSeats) < 4 {
+				require.NotEmpty(t, succeeded, "no booking succeeded while seats are free")
+			}
+		}
+
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       uuid.New(),
+			ShowID:          showID,
+			NumberOfTickets: 1,
+			CustomerEmail:   "foo@bar.com",
+		})
+		require.ErrorIs(t, err, ErrNoPlacesLeft)
+	})
+
+	t.Run("parallel_booking_and_cancel", func(t *testing.T) {
+		showID := uuid.New()
+
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 100,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Exmaple vanue",
+		})
+		require.NoError(t, err)
+
+		succeededCh := make(chan []uuid.UUID)
+		go func() {
+			succeededCh <- bookInParallel(t, 50, func() entities.Booking {
+				return entities.Booking{
+					BookingID:       uuid.New(),
+					ShowID:          showID,
+					NumberOfTickets: 1,
+					CustomerEmail:   "foo@bar.com",
+				}
+			})
+		}()
+
+		err = showsRepo.Cancel(ctx, showID)
+		require.NoError(t, err)
+
+		// bookings found right after the show is canceled are the ones canceled by CancelShowBookingsHandler
+		bookingsToCancel, err := bookingsRepo.FindByShowID(ctx, showID)
+		require.NoError(t, err)
+
+		succeeded := <-succeededCh
+
+		bookingIDsToCancel := make([]uuid.UUID, 0, len(bookingsToCancel))
+		for _, booking := range bookingsToCancel {
+			bookingIDsToCancel = append(bookingIDsToCancel, booking.BookingID)
+		}
+		assert.ElementsMatch(t, succeeded, bookingIDsToCancel, "booking added after the show was canceled")
+
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       uuid.New(),
+			ShowID:          showID,
+			NumberOfTickets: 1,
+			CustomerEmail:   "foo@bar.com",
+		})
+		require.ErrorIs(t, err, ErrShowCanceled)
+	})
+}
+
+func TestBookingsRepository_AssignTicketSeat(t *testing.T) {
+	ctx := context.Background()
+
+	db := getDb()
+	require.NoError(t, InitializeDatabaseSchema(db))
+
+	bookingsRepo := NewBookingRepository(db)
+
+	showID := uuid.New()
+	err := NewShowRepository(db).Add(ctx, entities.Show{
+		ShowID:          showID,
+		DeadNationID:    uuid.New(),
+		NumberOfTickets: 4,
+		StartTime:       time.Now().Add(time.Hour),
+		Title:           "Example title",
+		Venue:           "Exmaple vanue",
+		SeatMap:         testSeatMap(),
+	})
+	require.NoError(t, err)
+
+	bookingID := uuid.New()
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       bookingID,
+		ShowID:          showID,
+		NumberOfTickets: 3,
+		CustomerEmail:   "foo@bar.com",
+	})
+	require.NoError(t, err)
+
+	ticketIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	wg := sync.WaitGroup{}
+	seats := make([]entities.Seat, len(ticketIDs))
+	for i, ticketID := range ticketIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			seat, ok, err := bookingsRepo.AssignTicketSeat(ctx, bookingID, ticketID)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			seats[i] = seat
+		}()
+	}
+	wg.Wait()
+
+	booking, err := bookingsRepo.FindByID(ctx, bookingID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, booking.Seats, seats, "each ticket should get its own seat of the booking")
+
+	// the seat is kept when the ticket is printed again
+	seat, ok, err := bookingsRepo.AssignTicketSeat(ctx, bookingID, ticketIDs[0])
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, seats[0], seat)
+
+	_, ok, err = bookingsRepo.AssignTicketSeat(ctx, bookingID, uuid.New())
+	require.NoError(t, err)
+	assert.False(t, ok, "all seats of the booking have tickets")
+}
+
+// testSeatMap has 4 seats, the first seat of the second row is accessible.
+func testSeatMap() *entities.SeatMap {
+	return &entities.SeatMap{
+		Sections: []entities.SeatMapSection{
+			{
+				Name: "A",
+				Rows: []entities.SeatMapRow{
+					{Name: "1", Seats: []entities.SeatMapSeat{{Number: "1"}, {Number: "2"}}},
+					{Name: "2", Seats: []entities.SeatMapSeat{{Number: "1", Accessible: true}, {Number: "2"}}},
+				},
+			},
+		},
+	}
+}
+
+// bookInParallel adds bookings from workersCount goroutines at once and returns IDs of bookings which were added.
+func bookInParallel(t *testing.T, workersCount int, newBooking func() entities.Booking) []uuid.UUID {
+	t.Helper()
+
+	bookingsRepo := NewBookingRepository(getDb())
+
+	unlock := make(chan struct{})
+	lock := sync.Mutex{}
+	var succeeded []uuid.UUID
+
+	wg := sync.WaitGroup{}
+	wg.Add(workersCount)
+
+	for i := 0; i < workersCount; i++ {
+		booking := newBooking()
+
+		go func() {
+			defer wg.Done()
+
+			<-unlock
+			if err := bookingsRepo.Add(context.Background(), booking); err != nil {
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			succeeded = append(succeeded, booking.BookingID)
+		}()
+	}
+	close(unlock)
+
+	wg.Wait()
+
+	return succeeded
+}
+
+func TestBookingsRepository_AddRefundedTicket(t *testing.T) {
+	ctx := context.Background()
+
+	db := getDb()
+
+	err := InitializeDatabaseSchema(db)
+	require.NoError(t, err)
+
+	bookingsRepo := NewBookingRepository(db)
+	showsRepo := NewShowRepository(db)
+	ticketsRepo := NewTicketRepository(db)
+
+	showID := uuid.New()
+	err = showsRepo.Add(ctx, entities.Show{
+		ShowID:          showID,
+		DeadNationID:    uuid.New(),
+		NumberOfTickets: 2,
+		StartTime:       time.Now().Add(time.Hour),
+		Title:           "Example title",
+		Venue:           "Exmaple vanue",
+	})
+	require.NoError(t, err)
+
+	bookingID := uuid.New()
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       bookingID,
+		ShowID:          showID,
+		NumberOfTickets: 2,
+		CustomerEmail:   "foo@bar.com",
+	})
+	require.NoError(t, err)
+
+	ticketIDs := []string{uuid.NewString(), uuid.NewString()}
+	for _, ticketID := range ticketIDs {
+		err := ticketsRepo.Add(ctx, entities.Ticket{
+			TicketID:      ticketID,
+			Price:         entities.MustNewMoney("10.00", "EUR"),
+			CustomerEmail: "foo@bar.com",
+			BookingID:     bookingID.String(),
+		})
+		require.NoError(t, err)
+	}
+
+	booking, recorded, err := bookingsRepo.AddRefundedTicket(ctx, ticketIDs[0])
+	require.NoError(t, err)
+	assert.True(t, recorded)
+	assert.Equal(t, 1, booking.RefundedTickets)
+
+	// the same ticket refunded again is not counted, but its booking is returned
+	booking, recorded, err = bookingsRepo.AddRefundedTicket(ctx, ticketIDs[0])
+	require.NoError(t, err)
+	assert.False(t, recorded)
+	assert.Equal(t, bookingID, booking.BookingID)
+	assert.Equal(t, 1, booking.RefundedTickets)
+
+	booking, recorded, err = bookingsRepo.AddRefundedTicket(ctx, ticketIDs[1])
+	require.NoError(t, err)
+	assert.True(t, recorded)
+	assert.Equal(t, 2, booking.RefundedTickets)
+
+	tickets, err := ticketsRepo.FindByBookingID(ctx, bookingID.String())
+	require.NoError(t, err)
+	assert.Empty(t, tickets)
+
+	// seats of refunded tickets can be booked again
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       uuid.New(),
+		ShowID:          showID,
+		NumberOfTickets: 2,
+		CustomerEmail:   "foo@bar.com",
+	})
+	require.NoError(t, err)
+}
+
+func TestBookingsRepository_AddRefundedTicket_releases_seat(t *testing.T) {
+	ctx := context.Background()
+
+	db := getDb()
+
+	err := InitializeDatabaseSchema(db)
+	require.NoError(t, err)
+
+	bookingsRepo := NewBookingRepository(db)
+	showsRepo := NewShowRepository(db)
+	ticketsRepo := NewTicketRepository(db)
+
+	showID := uuid.New()
+	err = showsRepo.Add(ctx, entities.Show{
+		ShowID:          showID,
+		DeadNationID:    uuid.New(),
+		NumberOfTickets: 4,
+		StartTime:       time.Now().Add(time.Hour),
+		Title:           "Example title",
+		Venue:           "Exmaple vanue",
+		SeatMap:         testSeatMap(),
+	})
+	require.NoError(t, err)
+
+	bookingID := uuid.New()
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       bookingID,
+		ShowID:          showID,
+		NumberOfTickets: 4,
+		CustomerEmail:   "foo@bar.com",
+	})
+	require.NoError(t, err)
+
+	ticketID := uuid.New()
+	err = ticketsRepo.Add(ctx, entities.Ticket{
+		TicketID:      ticketID.String(),
+		Price:         entities.MustNewMoney("10.00", "EUR"),
+		CustomerEmail: "foo@bar.com",
+		BookingID:     bookingID.String(),
+	})
+	require.NoError(t, err)
+
+	seat, assigned, err := bookingsRepo.AssignTicketSeat(ctx, bookingID, ticketID)
+	require.NoError(t, err)
+	require.True(t, assigned)
+
+	_, recorded, err := bookingsRepo.AddRefundedTicket(ctx, ticketID.String())
+	require.NoError(t, err)
+	require.True(t, recorded)
+
+	booking, err := bookingsRepo.FindByID(ctx, bookingID)
+	require.NoError(t, err)
+	assert.Len(t, booking.Seats, 3)
+	assert.NotContains(t, booking.Seats, seat)
+
+	freedSeatBookingID := uuid.New()
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       freedSeatBookingID,
+		ShowID:          showID,
+		NumberOfTickets: 1,
+		CustomerEmail:   "foo@bar.com",
+		Seats:           []entities.Seat{{Section: seat.Section, Row: seat.Row, Number: seat.Number}},
+	})
+	require.NoError(t, err)
 }
 
 func requireNotEnoughSeatsError(t *testing.T, err error) {
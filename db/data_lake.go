@@ -10,26 +10,47 @@ import (
 	"github.com/lib/pq"
 )
 
+// EventUpcaster upcasts stored event payloads to the current version of the event.
+type EventUpcaster interface {
+	Upcast(eventName string, payload []byte) (string, []byte, error)
+}
+
 type DataLake struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	upcaster EventUpcaster
 }
 
-func NewDataLake(db *sqlx.DB) DataLake {
+func NewDataLake(db *sqlx.DB, upcaster EventUpcaster) DataLake {
 	if db == nil {
 		panic("db is nil")
 	}
+	if upcaster == nil {
+		panic("upcaster is nil")
+	}
 
-	return DataLake{db: db}
+	return DataLake{db: db, upcaster: upcaster}
 }
 
+// FindAll returns events upcasted to their current version.
+// Events are stored in the version in which they were published.
 func (d DataLake) FindAll(ctx context.Context) ([]entities.DataLakeEvent, error) {
-	var events []entities.DataLakeEvent
-	err := d.db.SelectContext(ctx, &events, "SELECT * FROM events ORDER BY published_at ASC")
+	var dataLakeEvents []entities.DataLakeEvent
+	err := d.db.SelectContext(ctx, &dataLakeEvents, "SELECT * FROM events ORDER BY published_at ASC")
 	if err != nil {
 		return nil, fmt.Errorf("could not get events from data lake: %w", err)
 	}
 
-	return events, nil
+	for i, event := range dataLakeEvents {
+		eventName, payload, err := d.upcaster.Upcast(event.EventName, event.EventPayload)
+		if err != nil {
+			return nil, fmt.Errorf("could not upcast event %s: %w", event.EventID, err)
+		}
+
+		dataLakeEvents[i].EventName = eventName
+		dataLakeEvents[i].EventPayload = payload
+	}
+
+	return dataLakeEvents, nil
 }
 
 func (d DataLake) Store(ctx context.Context, event entities.DataLakeEvent) error {
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"tickets/entities"
+	"tickets/observability"
+	"time"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+type DeadLetterRepository struct {
+	db *sqlx.DB
+}
+
+func NewDeadLetterRepository(db *sqlx.DB) DeadLetterRepository {
+	if db == nil {
+		panic("db is nil")
+	}
+
+	return DeadLetterRepository{db: db}
+}
+
+type deadLetterRow struct {
+	DeadLetterID uuid.UUID `db:"dead_letter_id"`
+	MessageUUID  string    `db:"message_uuid"`
+	Topic        string    `db:"topic"`
+	HandlerName  string    `db:"handler_name"`
+	Payload      []byte    `db:"payload"`
+	Metadata     []byte    `db:"metadata"`
+	Error        string    `db:"error"`
+	RetryCount   int       `db:"retry_count"`
+	FailedAt     time.Time `db:"failed_at"`
+}
+
+func (r deadLetterRow) toEntity() (entities.DeadLetter, error) {
+	var metadata map[string]string
+	if err := json.Unmarshal(r.Metadata, &metadata); err != nil {
+		return entities.DeadLetter{}, fmt.Errorf("could not unmarshal dead letter metadata: %w", err)
+	}
+
+	return entities.DeadLetter{
+		DeadLetterID: r.DeadLetterID,
+		MessageUUID:  r.MessageUUID,
+		Topic:        r.Topic,
+		HandlerName:  r.HandlerName,
+		Payload:      r.Payload,
+		Metadata:     metadata,
+		Error:        r.Error,
+		RetryCount:   r.RetryCount,
+		FailedAt:     r.FailedAt,
+	}, nil
+}
+
+func (d DeadLetterRepository) Add(ctx context.Context, deadLetter entities.DeadLetter) error {
+	metadata, err := json.Marshal(deadLetter.Metadata)
+	if err != nil {
+		return fmt.Errorf("could not marshal dead letter metadata: %w", err)
+	}
+
+	_, err = d.db.NamedExecContext(
+		ctx,
+		`
+		INSERT INTO
+			dead_letters (dead_letter_id, message_uuid, topic, handler_name, payload, metadata, error, retry_count, failed_at)
+		VALUES
+			(:dead_letter_id, :message_uuid, :topic, :handler_name, :payload, :metadata, :error, :retry_count, :failed_at)
+		ON CONFLICT DO NOTHING
+		`,
+		deadLetterRow{
+			DeadLetterID: deadLetter.DeadLetterID,
+			MessageUUID:  deadLetter.MessageUUID,
+			Topic:        deadLetter.Topic,
+			HandlerName:  deadLetter.HandlerName,
+			Payload:      deadLetter.Payload,
+			Metadata:     metadata,
+			Error:        deadLetter.Error,
+			RetryCount:   deadLetter.RetryCount,
+			FailedAt:     deadLetter.FailedAt,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not save dead letter: %w", err)
+	}
+
+	d.refreshParkedGauge(ctx)
+
+	return nil
+}
+
+func (d DeadLetterRepository) FindAll(ctx context.Context) ([]entities.DeadLetter, error) {
+	var rows []deadLetterRow
+	err := d.db.SelectContext(ctx, &rows, `
+		SELECT
+		    *
+		FROM
+		    dead_letters
+		ORDER BY failed_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get dead letters: %w", err)
+	}
+
+	deadLetters := make([]entities.DeadLetter, 0, len(rows))
+	for _, row := range rows {
+		deadLetter, err := row.toEntity()
+		if err != nil {
+			return nil, err
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+func (d DeadLetterRepository) FindByID(ctx context.Context, deadLetterID uuid.UUID) (entities.DeadLetter, error) {
+	var row deadLetterRow
+	err := d.db.GetContext(ctx, &row, `
+		SELECT
+		    *
+		FROM
+		    dead_letters
+		WHERE
+		    dead_letter_id = $1
+	`, deadLetterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.DeadLetter{}, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return entities.DeadLetter{}, fmt.Errorf("could not get dead letter: %w", err)
+	}
+
+	return row.toEntity()
+}
+
+func (d DeadLetterRepository) Remove(ctx context.Context, deadLetterID uuid.UUID) error {
+	res, err := d.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE dead_letter_id = $1`, deadLetterID)
+	if err != nil {
+		return fmt.Errorf("could not remove dead letter: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	d.refreshParkedGauge(ctx)
+
+	return nil
+}
+
+func (d DeadLetterRepository) Count(ctx context.Context) (int, error) {
+	count := 0
+	err := d.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM dead_letters`)
+	if err != nil {
+		return 0, fmt.Errorf("could not count dead letters: %w", err)
+	}
+
+	return count, nil
+}
+
+func (d DeadLetterRepository) refreshParkedGauge(ctx context.Context) {
+	count, err := d.Count(ctx)
+	if err != nil {
+		// metrics are best-effort, the gauge will be refreshed on the next change
+		log.FromContext(ctx).WithError(err).Warn("Could not refresh dead letters gauge")
+		return
+	}
+
+	observability.DeadLettersParked.Set(float64(count))
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"tickets/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterRepository_Add_and_Remove(t *testing.T) {
+	ctx := context.Background()
+
+	dbConn := getDb()
+	err := InitializeDatabaseSchema(dbConn)
+	require.NoError(t, err)
+
+	repo := NewDeadLetterRepository(dbConn)
+
+	deadLetter := entities.DeadLetter{
+		DeadLetterID: uuid.New(),
+		MessageUUID:  uuid.NewString(),
+		Topic:        "events.BookingMade_v1",
+		HandlerName:  "ops_read_model.OnBookingMade",
+		Payload:      []byte(`{"booking_id": "foo"}`),
+		Metadata:     map[string]string{"correlation_id": "bar"},
+		Error:        "read model for booking foo not exist yet",
+		RetryCount:   10,
+		FailedAt:     time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	err = repo.Add(ctx, deadLetter)
+	require.NoError(t, err)
+
+	found, err := repo.FindByID(ctx, deadLetter.DeadLetterID)
+	require.NoError(t, err)
+	assert.Equal(t, deadLetter, found)
+
+	err = repo.Remove(ctx, deadLetter.DeadLetterID)
+	require.NoError(t, err)
+
+	_, err = repo.FindByID(ctx, deadLetter.DeadLetterID)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+
+	err = repo.Remove(ctx, deadLetter.DeadLetterID)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
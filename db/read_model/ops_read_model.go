@@ -7,24 +7,25 @@ import (
 	"fmt"
 	"tickets/db/util"
 	"tickets/entities"
+	"tickets/message/events"
+	"tickets/message/events/outbox"
+	"tickets/observability"
 
 	"time"
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/log"
-	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
 )
 
 type OpsBookingReadModel struct {
-	db       *sqlx.DB
-	eventBus *cqrs.EventBus
+	db *sqlx.DB
 }
 
-func NewOpsBookingReadModel(db *sqlx.DB, eventBus *cqrs.EventBus) OpsBookingReadModel {
+func NewOpsBookingReadModel(db *sqlx.DB) OpsBookingReadModel {
 	return OpsBookingReadModel{
-		db:       db,
-		eventBus: eventBus,
+		db: db,
 	}
 }
 
@@ -71,16 +72,47 @@ func (r OpsBookingReadModel) AllReservations(receiptIssueDateFilter string) ([]e
 	return result, nil
 }
 
+// RevenueByCurrency sums prices of confirmed tickets which weren't refunded nor canceled, by their currency.
+// Timestamps which were not set are stored as zero time.
+func (r OpsBookingReadModel) RevenueByCurrency(ctx context.Context) ([]entities.Money, error) {
+	var revenue []entities.Money
+	err := r.db.SelectContext(ctx, &revenue, `
+		SELECT
+		    upper(ticket->>'price_currency') AS currency,
+		    sum((ticket->>'price_amount')::numeric) AS amount
+		FROM
+		    read_model_ops_bookings,
+		    jsonb_each(payload->'tickets') AS tickets(ticket_id, ticket)
+		WHERE
+		    coalesce(payload->>'canceled_at', $1) = $1
+		    AND coalesce(ticket->>'confirmed_at', $1) <> $1
+		    AND coalesce(ticket->>'refunded_at', $1) = $1
+		    AND coalesce(ticket->>'price_amount', '') <> ''
+		GROUP BY
+		    1
+		ORDER BY
+		    1
+	`, time.Time{}.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("could not sum revenue: %w", err)
+	}
+
+	return revenue, nil
+}
+
 func (r OpsBookingReadModel) BookingReadModel(ctx context.Context, bookingID string) (entities.OpsBooking, error) {
 	return r.findReadModelByBookingID(ctx, bookingID, r.db)
 }
 
 func (r OpsBookingReadModel) OnBookingMade(ctx context.Context, event *entities.BookingMade_v1) error {
 	err := r.createReadModel(ctx, entities.OpsBooking{
-		BookingID:  event.BookingID,
-		BookedAt:   event.Header.PublishedAt,
-		Tickets:    nil,
-		LastUpdate: time.Now(),
+		BookingID:     event.BookingID,
+		BookedAt:      event.Header.PublishedAt,
+		Seats:         event.Seats,
+		PriceCategory: event.PriceCategory,
+		TicketPrice:   event.TicketPrice,
+		Tickets:       nil,
+		LastUpdate:    time.Now(),
 	})
 	if err != nil {
 		return fmt.Errorf("could not create read model: %w", err)
@@ -107,16 +139,21 @@ func (r OpsBookingReadModel) OnTicketReceiptIssued(ctx context.Context, event *e
 }
 
 func (r OpsBookingReadModel) OnTicketBookingConfirmed(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error {
-	return r.updateBookingReadModel(
+	var expectedPrice *entities.Money
+
+	err := r.updateBookingReadModel(
 		ctx,
 		event.BookingID,
 		func(rm entities.OpsBooking) (entities.OpsBooking, error) {
+			// the transaction may be retried, so only the last attempt decides about the mismatch
+			expectedPrice = nil
+
 			ticket, ok := rm.Tickets[event.TicketID]
 			if !ok {
 				// we use the zero-value of OpsTicket
 				log.FromContext(ctx).
 					WithField("ticket_id", event.TicketID).
-					Debug("Creating ticket read model for ticket %s")
+					Debug("Creating ticket read model")
 			}
 
 			ticket.PriceAmount = event.Price.Amount
@@ -124,11 +161,32 @@ func (r OpsBookingReadModel) OnTicketBookingConfirmed(ctx context.Context, event
 			ticket.CustomerEmail = event.CustomerEmail
 			ticket.ConfirmedAt = event.Header.PublishedAt
 
+			if rm.TicketPrice != nil && !event.Price.Equal(*rm.TicketPrice) && !ticket.PriceMismatch {
+				ticket.PriceMismatch = true
+				expectedPrice = rm.TicketPrice
+			}
+
 			rm.Tickets[event.TicketID] = ticket
 
 			return rm, nil
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	// counted after the commit, so retried transactions and redelivered events don't count the ticket twice
+	if expectedPrice != nil {
+		log.FromContext(ctx).WithFields(logrus.Fields{
+			"ticket_id":      event.TicketID,
+			"price":          event.Price,
+			"expected_price": *expectedPrice,
+		}).Warn("Ticket confirmed with a price different from its price category")
+
+		observability.TicketPriceMismatches.Inc()
+	}
+
+	return nil
 }
 
 func (r OpsBookingReadModel) OnTicketPrinted(ctx context.Context, event *entities.TicketPrinted_v1) error {
@@ -164,29 +222,54 @@ func (r OpsBookingReadModel) OnTicketRefunded(ctx context.Context, event *entiti
 	return nil
 }
 
+func (r OpsBookingReadModel) OnBookingCanceled(ctx context.Context, event *entities.BookingCanceled_v1) error {
+	return r.updateBookingReadModel(
+		ctx,
+		event.BookingID.String(),
+		func(rm entities.OpsBooking) (entities.OpsBooking, error) {
+			rm.CanceledAt = &event.Header.PublishedAt
+
+			return rm, nil
+		},
+	)
+}
+
 func (r OpsBookingReadModel) createReadModel(ctx context.Context, booking entities.OpsBooking) error {
 	payload, err := json.Marshal(booking)
 	if err != nil {
 		return err
 	}
 
-	query := `
-		INSERT INTO 
-		    read_model_ops_bookings (payload, booking_id)
-		VALUES
-			($1, $2)
-		ON CONFLICT (booking_id) DO NOTHING; -- read model may be already updated by another event - we don't want to override
-	`
+	return util.UpdateInTx(
+		ctx,
+		r.db,
+		sql.LevelRepeatableRead,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			query := `
+				INSERT INTO 
+					read_model_ops_bookings (payload, booking_id)
+				VALUES
+					($1, $2)
+				ON CONFLICT (booking_id) DO NOTHING; -- read model may be already updated by another event - we don't want to override
+			`
+
+			res, err := tx.ExecContext(ctx, query, payload, booking.BookingID)
+			if err != nil {
+				return fmt.Errorf("could not create read model: %w", err)
+			}
 
-	_, err = r.db.ExecContext(ctx, query, payload, booking.BookingID)
-	if err != nil {
-		return fmt.Errorf("could not create read model: %w", err)
-	}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("could not get rows affected: %w", err)
+			}
+			if rowsAffected == 0 {
+				// nothing changed
+				return nil
+			}
 
-	return r.eventBus.Publish(ctx, &entities.InternalOpsReadModelUpdated{
-		Header:    entities.NewEventHeader(),
-		BookingID: booking.BookingID,
-	})
+			return r.publishReadModelUpdated(ctx, tx, booking.BookingID)
+		},
+	)
 }
 
 func (r OpsBookingReadModel) updateBookingReadModel(
@@ -194,7 +277,7 @@ func (r OpsBookingReadModel) updateBookingReadModel(
 	bookingID string,
 	updateFunc func(ticket entities.OpsBooking) (entities.OpsBooking, error),
 ) error {
-	err := util.UpdateInTx(
+	return util.UpdateInTx(
 		ctx,
 		r.db,
 		sql.LevelRepeatableRead,
@@ -215,14 +298,6 @@ func (r OpsBookingReadModel) updateBookingReadModel(
 			return r.updateReadModel(ctx, tx, updatedRm)
 		},
 	)
-	if err != nil {
-		return err
-	}
-
-	return r.eventBus.Publish(ctx, &entities.InternalOpsReadModelUpdated{
-		Header:    entities.NewEventHeader(),
-		BookingID: uuid.MustParse(bookingID),
-	})
 }
 
 func (r OpsBookingReadModel) updateTicketInBookingReadModel(
@@ -278,6 +353,25 @@ func (r OpsBookingReadModel) updateReadModel(ctx context.Context, tx *sqlx.Tx, r
 		return fmt.Errorf("could not update read model: %w", err)
 	}
 
+	return r.publishReadModelUpdated(ctx, tx, rm.BookingID)
+}
+
+// publishReadModelUpdated publishes the event via the outbox,
+// so it's published if and only if the read model change is committed.
+func (r OpsBookingReadModel) publishReadModelUpdated(ctx context.Context, tx *sqlx.Tx, bookingID uuid.UUID) error {
+	outboxPublisher, err := outbox.NewPublisherForDb(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("could not create outbox publisher: %w", err)
+	}
+
+	err = events.NewEventBus(outboxPublisher).Publish(ctx, &entities.InternalOpsReadModelUpdated{
+		Header:    entities.NewEventHeader(),
+		BookingID: bookingID,
+	})
+	if err != nil {
+		return fmt.Errorf("could not publish InternalOpsReadModelUpdated event: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"tickets/db/util"
+	"tickets/entities"
+	"tickets/observability"
+	"time"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+
+type ScheduledMessageRepository struct {
+	db *sqlx.DB
+}
+
+func NewScheduledMessageRepository(db *sqlx.DB) ScheduledMessageRepository {
+	if db == nil {
+		panic("db is nil")
+	}
+
+	return ScheduledMessageRepository{db: db}
+}
+
+type scheduledMessageRow struct {
+	ScheduledMessageID uuid.UUID  `db:"scheduled_message_id"`
+	MessageUUID        string     `db:"message_uuid"`
+	Topic              string     `db:"topic"`
+	Payload            []byte     `db:"payload"`
+	Metadata           []byte     `db:"metadata"`
+	DeliverAt          time.Time  `db:"deliver_at"`
+	ScheduledAt        time.Time  `db:"scheduled_at"`
+	ClaimedUntil       *time.Time `db:"claimed_until"`
+}
+
+func (r scheduledMessageRow) toEntity() (entities.ScheduledMessage, error) {
+	var metadata map[string]string
+	if err := json.Unmarshal(r.Metadata, &metadata); err != nil {
+		return entities.ScheduledMessage{}, fmt.Errorf("could not unmarshal scheduled message metadata: %w", err)
+	}
+
+	return entities.ScheduledMessage{
+		ScheduledMessageID: r.ScheduledMessageID,
+		MessageUUID:        r.MessageUUID,
+		Topic:              r.Topic,
+		Payload:            r.Payload,
+		Metadata:           metadata,
+		DeliverAt:          r.DeliverAt,
+		ScheduledAt:        r.ScheduledAt,
+		ClaimedUntil:       r.ClaimedUntil,
+	}, nil
+}
+
+// Add stores the message in the transaction carried by ctx (if there is one),
+// so the message is scheduled only when that transaction commits.
+func (s ScheduledMessageRepository) Add(ctx context.Context, scheduledMessage entities.ScheduledMessage) error {
+	metadata, err := json.Marshal(scheduledMessage.Metadata)
+	if err != nil {
+		return fmt.Errorf("could not marshal scheduled message metadata: %w", err)
+	}
+
+	_, err = sqlx.NamedExecContext(
+		ctx,
+		util.Executor(ctx, s.db),
+		`
+		INSERT INTO
+			scheduled_messages (scheduled_message_id, message_uuid, topic, payload, metadata, deliver_at, scheduled_at)
+		VALUES
+			(:scheduled_message_id, :message_uuid, :topic, :payload, :metadata, :deliver_at, :scheduled_at)
+		`,
+		scheduledMessageRow{
+			ScheduledMessageID: scheduledMessage.ScheduledMessageID,
+			MessageUUID:        scheduledMessage.MessageUUID,
+			Topic:              scheduledMessage.Topic,
+			Payload:            scheduledMessage.Payload,
+			Metadata:           metadata,
+			DeliverAt:          scheduledMessage.DeliverAt,
+			ScheduledAt:        scheduledMessage.ScheduledAt,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("could not save scheduled message: %w", err)
+	}
+
+	if _, inTx := util.TxFromContext(ctx); !inTx {
+		s.refreshPendingGauge(ctx)
+	}
+
+	return nil
+}
+
+func (s ScheduledMessageRepository) FindAll(ctx context.Context) ([]entities.ScheduledMessage, error) {
+	var rows []scheduledMessageRow
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT
+		    *
+		FROM
+		    scheduled_messages
+		ORDER BY deliver_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get scheduled messages: %w", err)
+	}
+
+	scheduledMessages := make([]entities.ScheduledMessage, 0, len(rows))
+	for _, row := range rows {
+		scheduledMessage, err := row.toEntity()
+		if err != nil {
+			return nil, err
+		}
+
+		scheduledMessages = append(scheduledMessages, scheduledMessage)
+	}
+
+	return scheduledMessages, nil
+}
+
+func (s ScheduledMessageRepository) Remove(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	res, err := util.Executor(ctx, s.db).ExecContext(
+		ctx,
+		`
+		DELETE FROM
+			scheduled_messages
+		WHERE
+			scheduled_message_id = $1 AND (claimed_until IS NULL OR claimed_until < now())
+		`,
+		scheduledMessageID,
+	)
+	if err != nil {
+		return fmt.Errorf("could not remove scheduled message: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrScheduledMessageNotFound
+	}
+
+	if _, inTx := util.TxFromContext(ctx); !inTx {
+		s.refreshPendingGauge(ctx)
+	}
+
+	return nil
+}
+
+// ClaimDue claims the oldest due message for claimFor, so it's released by only one instance of the service.
+// The claim is stored in a short transaction of its own, no lock is held while the message is published.
+// Messages whose claim expired (for example because the service crashed) are claimed again.
+// It returns false if there was no due message.
+func (s ScheduledMessageRepository) ClaimDue(
+	ctx context.Context,
+	claimFor time.Duration,
+) (entities.ScheduledMessage, bool, error) {
+	var row scheduledMessageRow
+	err := s.db.GetContext(ctx, &row, `
+		UPDATE
+			scheduled_messages
+		SET
+			claimed_until = now() + make_interval(secs => $1)
+		WHERE
+			scheduled_message_id = (
+				SELECT
+					scheduled_message_id
+				FROM
+					scheduled_messages
+				WHERE
+					deliver_at <= now() AND (claimed_until IS NULL OR claimed_until < now())
+				ORDER BY deliver_at ASC
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING *
+	`, claimFor.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.ScheduledMessage{}, false, nil
+	}
+	if err != nil {
+		return entities.ScheduledMessage{}, false, fmt.Errorf("could not claim due scheduled message: %w", err)
+	}
+
+	scheduledMessage, err := row.toEntity()
+	if err != nil {
+		return entities.ScheduledMessage{}, false, err
+	}
+
+	return scheduledMessage, true, nil
+}
+
+// MarkReleased removes the claimed message after it was published.
+func (s ScheduledMessageRepository) MarkReleased(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM scheduled_messages WHERE scheduled_message_id = $1`,
+		scheduledMessageID,
+	)
+	if err != nil {
+		return fmt.Errorf("could not remove released scheduled message: %w", err)
+	}
+
+	s.refreshPendingGauge(ctx)
+
+	return nil
+}
+
+// Unclaim makes the message available for release again, when publishing it failed.
+func (s ScheduledMessageRepository) Unclaim(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE scheduled_messages SET claimed_until = NULL WHERE scheduled_message_id = $1`,
+		scheduledMessageID,
+	)
+	if err != nil {
+		return fmt.Errorf("could not unclaim scheduled message: %w", err)
+	}
+
+	return nil
+}
+
+func (s ScheduledMessageRepository) Count(ctx context.Context) (int, error) {
+	count := 0
+	err := s.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM scheduled_messages`)
+	if err != nil {
+		return 0, fmt.Errorf("could not count scheduled messages: %w", err)
+	}
+
+	return count, nil
+}
+
+func (s ScheduledMessageRepository) refreshPendingGauge(ctx context.Context) {
+	count, err := s.Count(ctx)
+	if err != nil {
+		// metrics are best-effort, the gauge will be refreshed on the next change
+		log.FromContext(ctx).WithError(err).Warn("Could not refresh scheduled messages gauge")
+		return
+	}
+
+	observability.ScheduledMessagesPending.Set(float64(count))
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"tickets/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledMessageRepository_ClaimDue(t *testing.T) {
+	ctx := context.Background()
+
+	dbConn := getDb()
+	err := InitializeDatabaseSchema(dbConn)
+	require.NoError(t, err)
+
+	repo := NewScheduledMessageRepository(dbConn)
+
+	claimAll := func(claimFor time.Duration) map[uuid.UUID]struct{} {
+		claimedIDs := map[uuid.UUID]struct{}{}
+		for {
+			scheduledMessage, claimed, err := repo.ClaimDue(ctx, claimFor)
+			require.NoError(t, err)
+
+			if !claimed {
+				return claimedIDs
+			}
+			claimedIDs[scheduledMessage.ScheduledMessageID] = struct{}{}
+		}
+	}
+
+	dueMessage := newScheduledMessage(time.Now().Add(-time.Minute))
+	futureMessage := newScheduledMessage(time.Now().Add(time.Hour))
+
+	require.NoError(t, repo.Add(ctx, dueMessage))
+	require.NoError(t, repo.Add(ctx, futureMessage))
+
+	claimedIDs := claimAll(time.Second)
+	assert.Contains(t, claimedIDs, dueMessage.ScheduledMessageID)
+	assert.NotContains(t, claimedIDs, futureMessage.ScheduledMessageID)
+
+	t.Run("claimed_message_is_not_claimed_again", func(t *testing.T) {
+		assert.NotContains(t, claimAll(time.Second), dueMessage.ScheduledMessageID)
+	})
+
+	t.Run("claimed_message_cannot_be_canceled", func(t *testing.T) {
+		err := repo.Remove(ctx, dueMessage.ScheduledMessageID)
+		assert.ErrorIs(t, err, ErrScheduledMessageNotFound)
+	})
+
+	t.Run("crash_mid_release", func(t *testing.T) {
+		// the message was never marked as released, it's claimed again after the claim expires
+		time.Sleep(time.Second * 2)
+
+		assert.Contains(t, claimAll(time.Minute), dueMessage.ScheduledMessageID)
+	})
+
+	t.Run("nacked_message_is_claimed_right_away", func(t *testing.T) {
+		require.NoError(t, repo.Unclaim(ctx, dueMessage.ScheduledMessageID))
+
+		assert.Contains(t, claimAll(time.Minute), dueMessage.ScheduledMessageID)
+	})
+
+	t.Run("released_message_is_removed", func(t *testing.T) {
+		require.NoError(t, repo.MarkReleased(ctx, dueMessage.ScheduledMessageID))
+		require.NoError(t, repo.Unclaim(ctx, dueMessage.ScheduledMessageID))
+
+		assert.NotContains(t, claimAll(time.Minute), dueMessage.ScheduledMessageID)
+	})
+
+	err = repo.Remove(ctx, futureMessage.ScheduledMessageID)
+	require.NoError(t, err)
+
+	err = repo.Remove(ctx, futureMessage.ScheduledMessageID)
+	assert.ErrorIs(t, err, ErrScheduledMessageNotFound)
+}
+
+func newScheduledMessage(deliverAt time.Time) entities.ScheduledMessage {
+	return entities.ScheduledMessage{
+		ScheduledMessageID: uuid.New(),
+		MessageUUID:        uuid.NewString(),
+		Topic:              "commands.RefundTicket",
+		Payload:            []byte(`{"ticket_id": "foo"}`),
+		Metadata:           map[string]string{"correlation_id": "bar"},
+		DeliverAt:          deliverAt,
+		ScheduledAt:        time.Now(),
+	}
+}
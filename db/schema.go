@@ -1,7 +1,11 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"tickets/db/util"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -10,12 +14,22 @@ func InitializeDatabaseSchema(db *sqlx.DB) error {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS tickets (
 			ticket_id UUID PRIMARY KEY,
-			price_amount NUMERIC(10, 2) NOT NULL,
+			price_amount NUMERIC NOT NULL,
 			price_currency CHAR(3) NOT NULL,
 			customer_email VARCHAR(255) NOT NULL,
+			booking_id UUID NULL,
+			refunded_at TIMESTAMPTZ NULL,
 			deleted_at TIMESTAMP NULL
 		);
 
+		ALTER TABLE tickets ADD COLUMN IF NOT EXISTS booking_id UUID NULL;
+		ALTER TABLE tickets ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMPTZ NULL;
+
+		-- amounts are stored exactly as sent, they are limited to minor units of their currency by entities.Money
+		ALTER TABLE tickets ALTER COLUMN price_amount TYPE NUMERIC;
+
+		CREATE INDEX IF NOT EXISTS tickets_booking_id_idx ON tickets (booking_id);
+
 		CREATE TABLE IF NOT EXISTS read_model_ops_bookings (
 			booking_id UUID PRIMARY KEY,
 			payload JSONB NOT NULL
@@ -28,18 +42,69 @@ func InitializeDatabaseSchema(db *sqlx.DB) error {
 			start_time TIMESTAMP NOT NULL,
 			title VARCHAR(255) NOT NULL,
 			venue VARCHAR(255) NOT NULL,  
+			price_categories JSONB NOT NULL DEFAULT '[]',
+			canceled_at TIMESTAMPTZ NULL,
 
 			UNIQUE (dead_nation_id)
 		);
 
+		ALTER TABLE shows ADD COLUMN IF NOT EXISTS price_categories JSONB NOT NULL DEFAULT '[]';
+		ALTER TABLE shows ADD COLUMN IF NOT EXISTS canceled_at TIMESTAMPTZ NULL;
+
+		CREATE TABLE IF NOT EXISTS show_seats (
+			show_id UUID NOT NULL,
+			seat_section VARCHAR(255) NOT NULL,
+			seat_row VARCHAR(255) NOT NULL,
+			seat_number VARCHAR(255) NOT NULL,
+			accessible BOOLEAN NOT NULL,
+			position INT NOT NULL,
+			booking_id UUID NULL,
+
+			PRIMARY KEY (show_id, seat_section, seat_row, seat_number),
+			FOREIGN KEY (show_id) REFERENCES shows(show_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS show_seats_booking_id_idx ON show_seats (booking_id);
+
+		ALTER TABLE show_seats ADD COLUMN IF NOT EXISTS ticket_id UUID NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS show_seats_ticket_id_idx ON show_seats (ticket_id);
+
 		CREATE TABLE IF NOT EXISTS bookings (
 			booking_id UUID PRIMARY KEY,
 			show_id UUID NOT NULL,
 			number_of_tickets INT NOT NULL,
 			customer_email VARCHAR(255) NOT NULL,
+			canceled_at TIMESTAMPTZ NULL,
+			refunded_tickets INT NOT NULL DEFAULT 0,
+			price_category VARCHAR(255) NULL,
+			ticket_price_amount NUMERIC NULL,
+			ticket_price_currency CHAR(3) NULL,
 			FOREIGN KEY (show_id) REFERENCES shows(show_id)
 		);
 
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS canceled_at TIMESTAMPTZ NULL;
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS refunded_tickets INT NOT NULL DEFAULT 0;
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS price_category VARCHAR(255) NULL;
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS ticket_price_amount NUMERIC NULL;
+		ALTER TABLE bookings ADD COLUMN IF NOT EXISTS ticket_price_currency CHAR(3) NULL;
+		ALTER TABLE bookings ALTER COLUMN ticket_price_amount TYPE NUMERIC;
+
+		CREATE TABLE IF NOT EXISTS waitlist_entries (
+			entry_id UUID PRIMARY KEY,
+			show_id UUID NOT NULL,
+			customer_email VARCHAR(255) NOT NULL,
+			number_of_tickets INT NOT NULL,
+			price_category VARCHAR(255) NULL,
+			status VARCHAR(16) NOT NULL,
+			position BIGSERIAL NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			offer_expires_at TIMESTAMPTZ NULL,
+			booking_id UUID NULL,
+			FOREIGN KEY (show_id) REFERENCES shows(show_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS waitlist_entries_show_id_idx ON waitlist_entries (show_id, status, position);
+
 		CREATE TABLE IF NOT EXISTS events (
 			event_id UUID PRIMARY KEY,
 			published_at TIMESTAMP NOT NULL,
@@ -51,11 +116,113 @@ func InitializeDatabaseSchema(db *sqlx.DB) error {
 			vip_bundle_id UUID PRIMARY KEY,
 			booking_id UUID NOT NULL UNIQUE,
 			payload JSONB NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS vip_bundles_customer_email_idx ON vip_bundles ((payload->>'customer_email'));
+
+		CREATE TABLE IF NOT EXISTS dead_letters (
+			dead_letter_id UUID PRIMARY KEY,
+			message_uuid VARCHAR(255) NOT NULL,
+			topic VARCHAR(255) NOT NULL,
+			handler_name VARCHAR(255) NOT NULL,
+			payload BYTEA NOT NULL,
+			metadata JSONB NOT NULL,
+			error TEXT NOT NULL,
+			retry_count INT NOT NULL,
+			failed_at TIMESTAMP NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS inbox (
+			handler_name VARCHAR(255) NOT NULL,
+			message_id VARCHAR(255) NOT NULL,
+			handled_at TIMESTAMP NOT NULL,
+
+			PRIMARY KEY (handler_name, message_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS scheduled_messages (
+			scheduled_message_id UUID PRIMARY KEY,
+			message_uuid VARCHAR(255) NOT NULL,
+			topic VARCHAR(255) NOT NULL,
+			payload BYTEA NOT NULL,
+			metadata JSONB NOT NULL,
+			deliver_at TIMESTAMPTZ NOT NULL,
+			scheduled_at TIMESTAMPTZ NOT NULL,
+			claimed_until TIMESTAMPTZ NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS scheduled_messages_deliver_at_idx ON scheduled_messages (deliver_at);
+
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name VARCHAR(255) PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL
 		)
 	`)
 	if err != nil {
 		return fmt.Errorf("could not initialize database schema: %w", err)
 	}
 
+	for _, migration := range oneTimeMigrations {
+		if err := applyOnce(db, migration.name, migration.query); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// oneTimeMigrations change data, so unlike the schema they are applied only once, in the listed order.
+var oneTimeMigrations = []struct {
+	name  string
+	query string
+}{
+	{
+		// tickets stored before booking_id was recorded get it from the ops read model, so they are refunded
+		// when their booking is canceled
+		name: "backfill_tickets_booking_id",
+		query: `
+			UPDATE
+			    tickets
+			SET
+			    booking_id = read_model_ops_bookings.booking_id
+			FROM
+			    read_model_ops_bookings
+			WHERE
+			    tickets.booking_id IS NULL AND read_model_ops_bookings.payload->'tickets' ? tickets.ticket_id::text
+		`,
+	},
+}
+
+// applyOnce runs the query in the transaction recording the migration in schema_migrations.
+// Services starting at the same time wait for the first one to commit and skip the migration.
+func applyOnce(db *sqlx.DB, name string, query string) error {
+	return util.UpdateInTx(
+		context.Background(),
+		db,
+		sql.LevelReadCommitted,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var applied bool
+			err := tx.GetContext(ctx, &applied, `
+				INSERT INTO
+				    schema_migrations (name, applied_at)
+				VALUES
+				    ($1, now())
+				ON CONFLICT DO NOTHING
+				RETURNING
+				    true
+			`, name)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not record migration %s: %w", name, err)
+			}
+
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("could not apply migration %s: %w", name, err)
+			}
+
+			return nil
+		},
+	)
+}
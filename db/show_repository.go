@@ -2,11 +2,17 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"tickets/db/util"
 	"tickets/entities"
+	"tickets/message/events"
+	"tickets/message/events/outbox"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type ShowRepository struct {
@@ -21,33 +27,99 @@ func NewShowRepository(db *sqlx.DB) ShowRepository {
 	return ShowRepository{db: db}
 }
 
+var (
+	ErrShowNotFound           = errors.New("show not found")
+	ErrShowCanceled           = errors.New("show is canceled")
+	ErrCapacityBelowSoldSeats = errors.New("capacity is below seats already sold")
+	ErrCapacityFixedBySeatMap = errors.New("capacity of shows with a seat map can't be changed")
+)
+
+// showColumns are scanned into entities.Show, they are listed so new columns don't break the scan.
+const showColumns = `
+	show_id, dead_nation_id, number_of_tickets, start_time, title, venue, price_categories, canceled_at
+`
+
 func (s ShowRepository) Add(ctx context.Context, show entities.Show) error {
-	_, err := s.db.NamedExecContext(
+	return util.UpdateInTx(
 		ctx,
-		`
+		s.db,
+		sql.LevelReadCommitted,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.NamedExecContext(
+				ctx,
+				`
+				INSERT INTO
+					shows (show_id, dead_nation_id, number_of_tickets, start_time, title, venue, price_categories)
+				VALUES
+					(:show_id, :dead_nation_id, :number_of_tickets, :start_time, :title, :venue, :price_categories)
+				ON CONFLICT DO NOTHING
+				`,
+				show,
+			)
+			if err != nil {
+				return fmt.Errorf("could not save show: %w", err)
+			}
+
+			if show.SeatMap == nil {
+				return nil
+			}
+
+			return s.addSeats(ctx, tx, show.ShowID, show.SeatMap.Seats())
+		},
+	)
+}
+
+func (s ShowRepository) addSeats(ctx context.Context, tx *sqlx.Tx, showID uuid.UUID, seats []entities.Seat) error {
+	var sections, rows, numbers []string
+	var accessible []bool
+	for _, seat := range seats {
+		sections = append(sections, seat.Section)
+		rows = append(rows, seat.Row)
+		numbers = append(numbers, seat.Number)
+		accessible = append(accessible, seat.Accessible)
+	}
+
+	// position keeps the order of the seat map, so seats are allocated front to back
+	_, err := tx.ExecContext(ctx, `
 		INSERT INTO
-    		shows (show_id, dead_nation_id, number_of_tickets, start_time, title, venue)
-		VALUES
-		    (:show_id, :dead_nation_id, :number_of_tickets, :start_time, :title, :venue)
+			show_seats (show_id, seat_section, seat_row, seat_number, accessible, position)
+		SELECT
+			$1, seat.seat_section, seat.seat_row, seat.seat_number, seat.accessible, seat.position
+		FROM
+			unnest($2::text[], $3::text[], $4::text[], $5::boolean[]) WITH ORDINALITY
+				AS seat(seat_section, seat_row, seat_number, accessible, position)
 		ON CONFLICT DO NOTHING
-		`,
-		show,
-	)
+	`, showID, pq.Array(sections), pq.Array(rows), pq.Array(numbers), pq.Array(accessible))
 	if err != nil {
-		return fmt.Errorf("could not save show: %w", err)
+		return fmt.Errorf("could not save show seats: %w", err)
 	}
 
 	return nil
 }
 
+// FindSeats returns seats of the show in the seat map order, the show has no seats if it has general admission.
+func (s ShowRepository) FindSeats(ctx context.Context, showID uuid.UUID) ([]entities.ShowSeat, error) {
+	var seats []entities.ShowSeat
+	err := s.db.SelectContext(ctx, &seats, `
+		SELECT
+		    seat_section, seat_row, seat_number, accessible, booking_id IS NULL AS available
+		FROM
+		    show_seats
+		WHERE
+		    show_id = $1
+		ORDER BY
+		    position
+	`, showID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get show seats: %w", err)
+	}
+
+	return seats, nil
+}
+
 func (s ShowRepository) FindAll(ctx context.Context) ([]entities.Show, error) {
 	var shows []entities.Show
-	err := s.db.SelectContext(ctx, &shows, `
-		SELECT 
-		    * 
-		FROM 
-		    shows
-	`)
+	err := s.db.SelectContext(ctx, &shows, `SELECT `+showColumns+` FROM shows`)
 	if err != nil {
 		return nil, fmt.Errorf("could not get shows: %w", err)
 	}
@@ -57,17 +129,198 @@ func (s ShowRepository) FindAll(ctx context.Context) ([]entities.Show, error) {
 
 func (s ShowRepository) FindByID(ctx context.Context, showID uuid.UUID) (entities.Show, error) {
 	var show entities.Show
-	err := s.db.GetContext(ctx, &show, `
-		SELECT 
-		    * 
-		FROM 
-		    shows
-		WHERE
-		    show_id = $1
-	`, showID)
+	err := s.db.GetContext(ctx, &show, `SELECT `+showColumns+` FROM shows WHERE show_id = $1`, showID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.Show{}, ErrShowNotFound
+	}
 	if err != nil {
 		return entities.Show{}, fmt.Errorf("could not get show: %w", err)
 	}
 
 	return show, nil
 }
+
+// UpdateByID updates title, venue, start time and capacity of the show.
+// The capacity can't be lower than the number of seats booked by not canceled bookings.
+// ShowRescheduled_v1 is published when the start time changes and ShowCapacityIncreased_v1 when the capacity grows.
+func (s ShowRepository) UpdateByID(
+	ctx context.Context,
+	showID uuid.UUID,
+	updateFn func(show entities.Show) (entities.Show, error),
+) (entities.Show, error) {
+	var updatedShow entities.Show
+
+	// serializable, so the capacity can't be lowered while a booking is being added
+	err := util.UpdateInTx(
+		ctx,
+		s.db,
+		sql.LevelSerializable,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var show entities.Show
+			err := tx.GetContext(ctx, &show, `SELECT `+showColumns+` FROM shows WHERE show_id = $1 FOR UPDATE`, showID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrShowNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("could not get show: %w", err)
+			}
+
+			if show.CanceledAt != nil {
+				return ErrShowCanceled
+			}
+
+			updatedShow, err = updateFn(show)
+			if err != nil {
+				return err
+			}
+
+			if updatedShow.NumberOfTickets != show.NumberOfTickets {
+				if err := s.checkCapacity(ctx, tx, updatedShow); err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.NamedExecContext(ctx, `
+				UPDATE
+				    shows
+				SET
+				    title = :title,
+				    venue = :venue,
+				    start_time = :start_time,
+				    number_of_tickets = :number_of_tickets
+				WHERE
+				    show_id = :show_id
+			`, updatedShow)
+			if err != nil {
+				return fmt.Errorf("could not update show: %w", err)
+			}
+
+			var changes []any
+			if !updatedShow.StartTime.Equal(show.StartTime) {
+				changes = append(changes, entities.ShowRescheduled_v1{
+					Header:            entities.NewEventHeader(),
+					ShowID:            updatedShow.ShowID,
+					Title:             updatedShow.Title,
+					Venue:             updatedShow.Venue,
+					PreviousStartTime: show.StartTime,
+					StartTime:         updatedShow.StartTime,
+				})
+			}
+			if updatedShow.NumberOfTickets > show.NumberOfTickets {
+				changes = append(changes, entities.ShowCapacityIncreased_v1{
+					Header:                  entities.NewEventHeader(),
+					ShowID:                  updatedShow.ShowID,
+					PreviousNumberOfTickets: show.NumberOfTickets,
+					NumberOfTickets:         updatedShow.NumberOfTickets,
+				})
+			}
+			if len(changes) == 0 {
+				return nil
+			}
+
+			outboxPublisher, err := outbox.NewPublisherForDb(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("could not create outbox publisher: %w", err)
+			}
+
+			eventBus := events.NewEventBus(outboxPublisher)
+			for _, event := range changes {
+				if err := eventBus.Publish(ctx, event); err != nil {
+					return fmt.Errorf("could not publish event: %w", err)
+				}
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return entities.Show{}, err
+	}
+
+	return updatedShow, nil
+}
+
+func (s ShowRepository) checkCapacity(ctx context.Context, tx *sqlx.Tx, show entities.Show) error {
+	showSeats := 0
+	err := tx.GetContext(ctx, &showSeats, `SELECT count(*) FROM show_seats WHERE show_id = $1`, show.ShowID)
+	if err != nil {
+		return fmt.Errorf("could not count show seats: %w", err)
+	}
+	if showSeats > 0 {
+		return ErrCapacityFixedBySeatMap
+	}
+
+	// seats held for waitlist offers are sold unless the offer expires
+	soldSeats, err := takenSeats(ctx, tx, show.ShowID)
+	if err != nil {
+		return err
+	}
+
+	if show.NumberOfTickets < soldSeats {
+		return fmt.Errorf("%w: %d seats are sold", ErrCapacityBelowSoldSeats, soldSeats)
+	}
+
+	return nil
+}
+
+// Cancel marks the show as canceled, so it can't be booked anymore, expires its waitlist entries
+// and publishes ShowCanceled_v1. Canceling an already canceled show does nothing.
+func (s ShowRepository) Cancel(ctx context.Context, showID uuid.UUID) error {
+	return util.UpdateInTx(
+		ctx,
+		s.db,
+		sql.LevelReadCommitted,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			// bookings lock the show FOR SHARE, so the show is canceled only after bookings being added are committed
+			// and ShowCanceled_v1 handlers cancel them too
+			var show entities.Show
+			err := tx.GetContext(ctx, &show, `SELECT `+showColumns+` FROM shows WHERE show_id = $1 FOR UPDATE`, showID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrShowNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("could not get show: %w", err)
+			}
+
+			if show.CanceledAt != nil {
+				return nil
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE shows SET canceled_at = now() WHERE show_id = $1`, showID)
+			if err != nil {
+				return fmt.Errorf("could not cancel show: %w", err)
+			}
+
+			// customers waiting for the show are not offered seats anymore,
+			// scheduled ExpireWaitlistOffer commands skip entries which are not offered
+			_, err = tx.ExecContext(ctx, `
+				UPDATE
+				    waitlist_entries
+				SET
+				    status = $1
+				WHERE
+				    show_id = $2 AND status IN ($3, $4)
+			`, entities.WaitlistEntryExpired, showID, entities.WaitlistEntryWaiting, entities.WaitlistEntryOffered)
+			if err != nil {
+				return fmt.Errorf("could not expire waitlist entries: %w", err)
+			}
+
+			outboxPublisher, err := outbox.NewPublisherForDb(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("could not create outbox publisher: %w", err)
+			}
+
+			err = events.NewEventBus(outboxPublisher).Publish(ctx, entities.ShowCanceled_v1{
+				Header:    entities.NewEventHeader(),
+				ShowID:    show.ShowID,
+				Title:     show.Title,
+				StartTime: show.StartTime,
+			})
+			if err != nil {
+				return fmt.Errorf("could not publish event: %w", err)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"tickets/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowRepository_UpdateByID_and_Cancel(t *testing.T) {
+	ctx := context.Background()
+
+	db := getDb()
+
+	err := InitializeDatabaseSchema(db)
+	require.NoError(t, err)
+
+	bookingsRepo := NewBookingRepository(db)
+	showsRepo := NewShowRepository(db)
+
+	showID := uuid.New()
+	err = showsRepo.Add(ctx, entities.Show{
+		ShowID:          showID,
+		DeadNationID:    uuid.New(),
+		NumberOfTickets: 5,
+		StartTime:       time.Now().Add(time.Hour).Truncate(time.Second),
+		Title:           "Example title",
+		Venue:           "Example venue",
+	})
+	require.NoError(t, err)
+
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       uuid.New(),
+		ShowID:          showID,
+		NumberOfTickets: 3,
+		CustomerEmail:   "foo@bar.com",
+	})
+	require.NoError(t, err)
+
+	setCapacity := func(capacity int) func(show entities.Show) (entities.Show, error) {
+		return func(show entities.Show) (entities.Show, error) {
+			show.NumberOfTickets = capacity
+			return show, nil
+		}
+	}
+
+	_, err = showsRepo.UpdateByID(ctx, showID, setCapacity(2))
+	require.ErrorIs(t, err, ErrCapacityBelowSoldSeats)
+
+	show, err := showsRepo.UpdateByID(ctx, showID, setCapacity(3))
+	require.NoError(t, err)
+	assert.Equal(t, 3, show.NumberOfTickets)
+
+	err = showsRepo.Cancel(ctx, showID)
+	require.NoError(t, err)
+
+	// canceling is idempotent
+	err = showsRepo.Cancel(ctx, showID)
+	require.NoError(t, err)
+
+	bookings, err := bookingsRepo.FindByShowID(ctx, showID)
+	require.NoError(t, err)
+	assert.Len(t, bookings, 1)
+
+	err = bookingsRepo.Add(ctx, entities.Booking{
+		BookingID:       uuid.New(),
+		ShowID:          showID,
+		NumberOfTickets: 1,
+		CustomerEmail:   "foo@bar.com",
+	})
+	require.ErrorIs(t, err, ErrShowCanceled)
+
+	_, err = showsRepo.UpdateByID(ctx, showID, setCapacity(10))
+	require.ErrorIs(t, err, ErrShowCanceled)
+
+	err = showsRepo.Cancel(ctx, uuid.New())
+	require.ErrorIs(t, err, ErrShowNotFound)
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"tickets/db/util"
 	"tickets/entities"
 
 	"github.com/jmoiron/sqlx"
@@ -45,14 +46,44 @@ func (t TicketRepository) FindAll(ctx context.Context) ([]entities.Ticket, error
 	return tickets, nil
 }
 
+// FindByBookingID returns tickets of the booking which were not canceled or refunded.
+func (t TicketRepository) FindByBookingID(ctx context.Context, bookingID string) ([]entities.Ticket, error) {
+	var tickets []entities.Ticket
+
+	err := sqlx.SelectContext(
+		ctx,
+		util.Executor(ctx, t.db),
+		&tickets,
+		`
+		SELECT 
+		    ticket_id,
+			price_amount AS "price.amount",
+			price_currency AS "price.currency",
+			customer_email,
+			booking_id
+		FROM 
+		    tickets
+		WHERE
+			booking_id = $1 AND deleted_at IS NULL AND refunded_at IS NULL
+		`,
+		bookingID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve tickets of booking: %w", err)
+	}
+
+	return tickets, nil
+}
+
 func (t TicketRepository) Add(ctx context.Context, ticket entities.Ticket) error {
-	_, err := t.db.NamedExecContext(
+	_, err := sqlx.NamedExecContext(
 		ctx,
+		util.Executor(ctx, t.db),
 		`
 		INSERT INTO
-    		tickets (ticket_id, price_amount, price_currency, customer_email)
+    		tickets (ticket_id, price_amount, price_currency, customer_email, booking_id)
 		VALUES
-		    (:ticket_id, :price.amount, :price.currency, :customer_email)
+		    (:ticket_id, :price.amount, :price.currency, :customer_email, NULLIF(:booking_id, '')::uuid)
 		ON CONFLICT DO NOTHING
 		`,
 		ticket,
@@ -65,7 +96,7 @@ func (t TicketRepository) Add(ctx context.Context, ticket entities.Ticket) error
 }
 
 func (t TicketRepository) Remove(ctx context.Context, ticketID string) error {
-	res, err := t.db.ExecContext(
+	res, err := util.Executor(ctx, t.db).ExecContext(
 		ctx,
 		`UPDATE tickets SET deleted_at = now() WHERE ticket_id = $1`,
 		ticketID,
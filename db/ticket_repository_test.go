@@ -40,11 +40,8 @@ func TestTicketRepository_Add_idempotency(t *testing.T) {
 	repo := NewTicketRepository(dbConn)
 
 	ticketToAdd := entities.Ticket{
-		TicketID: uuid.NewString(),
-		Price: entities.Money{
-			Amount:   "30.00",
-			Currency: "EUR",
-		},
+		TicketID:      uuid.NewString(),
+		Price:         entities.MustNewMoney("30.00", "EUR"),
 		CustomerEmail: "foo@bar.com",
 	}
 
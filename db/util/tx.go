@@ -9,12 +9,54 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+type txContextKey struct{}
+
+type txInContext struct {
+	tx        *sqlx.Tx
+	isolation sql.IsolationLevel
+}
+
+// ContextWithTx stores tx with the isolation level it was started with,
+// so functions joining the transaction can check that it's strong enough for them.
+func ContextWithTx(ctx context.Context, tx *sqlx.Tx, isolation sql.IsolationLevel) context.Context {
+	return context.WithValue(ctx, txContextKey{}, txInContext{tx: tx, isolation: isolation})
+}
+
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	t, ok := ctx.Value(txContextKey{}).(txInContext)
+	return t.tx, ok
+}
+
+// Executor returns the transaction carried by ctx, so repositories can take part in it.
+// Without a transaction, db is returned.
+func Executor(ctx context.Context, db *sqlx.DB) sqlx.ExtContext {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// UpdateInTx runs fn in a new transaction.
+// If ctx already carries a transaction, fn joins it. Joining a transaction with a weaker isolation level
+// than requested returns an error, as fn would silently lose the guarantees it relies on.
 func UpdateInTx(
 	ctx context.Context,
 	db *sqlx.DB,
 	isolation sql.IsolationLevel,
 	fn func(ctx context.Context, tx *sqlx.Tx) error,
 ) (err error) {
+	if t, ok := ctx.Value(txContextKey{}).(txInContext); ok {
+		if effectiveIsolation(isolation) > effectiveIsolation(t.isolation) {
+			return fmt.Errorf(
+				"could not join %s transaction, %s isolation is required",
+				effectiveIsolation(t.isolation), effectiveIsolation(isolation),
+			)
+		}
+
+		return fn(ctx, t.tx)
+	}
+
 	tx, err := db.BeginTxx(ctx, &sql.TxOptions{Isolation: isolation})
 	if err != nil {
 		return fmt.Errorf("could not begin transaction: %w", err)
@@ -31,5 +73,14 @@ func UpdateInTx(
 		err = tx.Commit()
 	}()
 
-	return fn(ctx, tx)
+	return fn(ContextWithTx(ctx, tx, isolation), tx)
+}
+
+// effectiveIsolation returns the level used by Postgres, which uses READ COMMITTED by default.
+func effectiveIsolation(isolation sql.IsolationLevel) sql.IsolationLevel {
+	if isolation == sql.LevelDefault {
+		return sql.LevelReadCommitted
+	}
+
+	return isolation
 }
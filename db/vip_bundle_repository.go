@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"tickets/db/util"
 	"tickets/entities"
@@ -14,6 +15,8 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+var ErrVipBundleNotFound = errors.New("vip bundle not found")
+
 type VipBundleRepository struct {
 	db *sqlx.DB
 }
@@ -42,7 +45,7 @@ func (v VipBundleRepository) Add(ctx context.Context, vipBundle entities.VipBund
 		v.db,
 		sql.LevelRepeatableRead,
 		func(ctx context.Context, tx *sqlx.Tx) error {
-			_, err = v.db.ExecContext(ctx, `
+			_, err = tx.ExecContext(ctx, `
 				INSERT INTO vip_bundles (vip_bundle_id, booking_id, payload)
 				VALUES ($1, $2, $3)
 			`, vipBundle.VipBundleID, vipBundle.BookingID, payload)
@@ -59,6 +62,7 @@ func (v VipBundleRepository) Add(ctx context.Context, vipBundle entities.VipBund
 			err = events.NewEventBus(outboxPublisher).Publish(ctx, entities.VipBundleInitialized_v1{
 				Header:      entities.NewEventHeader(),
 				VipBundleID: vipBundle.VipBundleID,
+				BookingID:   vipBundle.BookingID,
 			})
 			if err != nil {
 				return fmt.Errorf("could not publish event: %w", err)
@@ -75,10 +79,13 @@ func (v VipBundleRepository) Get(ctx context.Context, vipBundleID uuid.UUID) (en
 
 func (v VipBundleRepository) vipBundleByID(ctx context.Context, vipBundleID uuid.UUID, db Executor) (entities.VipBundle, error) {
 	var payload []byte
-	err := v.db.QueryRowContext(ctx, `
+	err := db.QueryRowContext(ctx, `
 		SELECT payload FROM vip_bundles WHERE vip_bundle_id = $1
 	`, vipBundleID).Scan(&payload)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.VipBundle{}, ErrVipBundleNotFound
+	}
 	if err != nil {
 		return entities.VipBundle{}, fmt.Errorf("could not get vip bundle: %w", err)
 	}
@@ -96,12 +103,28 @@ func (v VipBundleRepository) GetByBookingID(ctx context.Context, bookingID uuid.
 	return v.getByBookingID(ctx, bookingID, v.db)
 }
 
+// FindByBookingID returns false if the booking isn't a part of any VIP bundle.
+func (v VipBundleRepository) FindByBookingID(ctx context.Context, bookingID uuid.UUID) (entities.VipBundle, bool, error) {
+	vb, err := v.getByBookingID(ctx, bookingID, v.db)
+	if errors.Is(err, ErrVipBundleNotFound) {
+		return entities.VipBundle{}, false, nil
+	}
+	if err != nil {
+		return entities.VipBundle{}, false, err
+	}
+
+	return vb, true, nil
+}
+
 func (v VipBundleRepository) getByBookingID(ctx context.Context, bookingID uuid.UUID, db Executor) (entities.VipBundle, error) {
 	var payload []byte
 	err := db.QueryRowContext(ctx, `
 		SELECT payload FROM vip_bundles WHERE booking_id = $1
 	`, bookingID).Scan(&payload)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.VipBundle{}, ErrVipBundleNotFound
+	}
 	if err != nil {
 		return entities.VipBundle{}, fmt.Errorf("could not get vip bundle: %w", err)
 	}
@@ -115,7 +138,49 @@ func (v VipBundleRepository) getByBookingID(ctx context.Context, bookingID uuid.
 	return vipBundle, nil
 }
 
-func (v VipBundleRepository) UpdateByID(ctx context.Context, bookingID uuid.UUID, updateFn func(vipBundle entities.VipBundle) (entities.VipBundle, error)) (entities.VipBundle, error) {
+func (v VipBundleRepository) FindByCustomerEmail(ctx context.Context, customerEmail string) ([]entities.VipBundle, error) {
+	var payloads [][]byte
+	err := v.db.SelectContext(ctx, &payloads, `
+		SELECT payload FROM vip_bundles WHERE payload->>'customer_email' = $1
+	`, customerEmail)
+	if err != nil {
+		return nil, fmt.Errorf("could not get vip bundles: %w", err)
+	}
+
+	return unmarshalVipBundles(payloads)
+}
+
+// FindWithIncompleteRollback returns rolled back bundles with compensations which are pending or failed.
+func (v VipBundleRepository) FindWithIncompleteRollback(ctx context.Context) ([]entities.VipBundle, error) {
+	var payloads [][]byte
+	err := v.db.SelectContext(ctx, &payloads, `
+		SELECT payload FROM vip_bundles
+		WHERE jsonb_path_exists(payload, '$.compensations[*] ? (@.status == "pending" || @.status == "failed")')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get vip bundles: %w", err)
+	}
+
+	return unmarshalVipBundles(payloads)
+}
+
+func unmarshalVipBundles(payloads [][]byte) ([]entities.VipBundle, error) {
+	vipBundles := make([]entities.VipBundle, 0, len(payloads))
+	for _, payload := range payloads {
+		var vipBundle entities.VipBundle
+		if err := json.Unmarshal(payload, &vipBundle); err != nil {
+			return nil, fmt.Errorf("could not unmarshal vip bundle: %w", err)
+		}
+
+		vipBundles = append(vipBundles, vipBundle)
+	}
+
+	return vipBundles, nil
+}
+
+// UpdateByID updates the bundle in a serializable transaction.
+// updateFn gets ctx carrying the transaction, so messages it sends through the outbox are stored with the bundle.
+func (v VipBundleRepository) UpdateByID(ctx context.Context, bookingID uuid.UUID, updateFn func(ctx context.Context, vipBundle entities.VipBundle) (entities.VipBundle, error)) (entities.VipBundle, error) {
 	var vb entities.VipBundle
 
 	err := util.UpdateInTx(ctx, v.db, sql.LevelSerializable, func(ctx context.Context, tx *sqlx.Tx) error {
@@ -125,7 +190,7 @@ func (v VipBundleRepository) UpdateByID(ctx context.Context, bookingID uuid.UUID
 			return err
 		}
 
-		vb, err = updateFn(vb)
+		vb, err = updateFn(ctx, vb)
 		if err != nil {
 			return err
 		}
@@ -152,7 +217,7 @@ func (v VipBundleRepository) UpdateByID(ctx context.Context, bookingID uuid.UUID
 	return vb, nil
 }
 
-func (v VipBundleRepository) UpdateByBookingID(ctx context.Context, bookingID uuid.UUID, updateFn func(vipBundle entities.VipBundle) (entities.VipBundle, error)) (entities.VipBundle, error) {
+func (v VipBundleRepository) UpdateByBookingID(ctx context.Context, bookingID uuid.UUID, updateFn func(ctx context.Context, vipBundle entities.VipBundle) (entities.VipBundle, error)) (entities.VipBundle, error) {
 	var vb entities.VipBundle
 
 	err := util.UpdateInTx(ctx, v.db, sql.LevelSerializable, func(ctx context.Context, tx *sqlx.Tx) error {
@@ -162,7 +227,7 @@ func (v VipBundleRepository) UpdateByBookingID(ctx context.Context, bookingID uu
 			return err
 		}
 
-		vb, err = updateFn(vb)
+		vb, err = updateFn(ctx, vb)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,357 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"tickets/db/util"
+	"tickets/entities"
+	"tickets/message/events"
+	"tickets/message/events/outbox"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultWaitlistOfferTTL = 30 * time.Minute
+
+var (
+	ErrWaitlistEntryNotFound  = errors.New("waitlist entry not found")
+	ErrWaitlistOfferNotActive = errors.New("waitlist entry has no active offer")
+	ErrWaitlistOfferExpired   = errors.New("waitlist offer expired")
+)
+
+type WaitlistRepository struct {
+	db *sqlx.DB
+
+	// offerTTL is how long seats offered to a customer from the waitlist are held
+	offerTTL time.Duration
+}
+
+func NewWaitlistRepository(db *sqlx.DB, offerTTL time.Duration) WaitlistRepository {
+	if db == nil {
+		panic("db is nil")
+	}
+	if offerTTL <= 0 {
+		offerTTL = DefaultWaitlistOfferTTL
+	}
+
+	return WaitlistRepository{db: db, offerTTL: offerTTL}
+}
+
+// inTx runs fn in a serializable transaction.
+// Free seats are counted the same way by bookings, so only serializable transactions prevent overbooking.
+func (w WaitlistRepository) inTx(ctx context.Context, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	return util.UpdateInTx(ctx, w.db, sql.LevelSerializable, fn)
+}
+
+const waitlistEntryColumns = `
+	entry_id,
+	show_id,
+	customer_email,
+	number_of_tickets,
+	coalesce(price_category, '') AS price_category,
+	status,
+	created_at,
+	offer_expires_at,
+	booking_id
+`
+
+// Add puts the customer at the end of the show's waitlist.
+// If seats are free already, they are offered right away.
+func (w WaitlistRepository) Add(ctx context.Context, entry entities.WaitlistEntry) error {
+	return w.inTx(
+		ctx,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var show struct {
+				PriceCategories entities.PriceCategories `db:"price_categories"`
+				Canceled        bool                     `db:"canceled"`
+			}
+			err := tx.GetContext(ctx, &show, `
+				SELECT
+				    price_categories,
+				    canceled_at IS NOT NULL AS canceled
+				FROM
+				    shows
+				WHERE
+				    show_id = $1
+			`, entry.ShowID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrShowNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("could not get show: %w", err)
+			}
+			if show.Canceled {
+				return ErrShowCanceled
+			}
+
+			// the category is checked now, so the customer doesn't wait for an offer which can't be claimed
+			if len(show.PriceCategories) > 0 {
+				if _, ok := show.PriceCategories.Find(entry.PriceCategory); !ok {
+					return ErrUnknownPriceCategory
+				}
+			} else if entry.PriceCategory != "" {
+				return ErrUnknownPriceCategory
+			}
+
+			entry.Status = entities.WaitlistEntryWaiting
+			entry.CreatedAt = time.Now()
+
+			_, err = tx.NamedExecContext(ctx, `
+				INSERT INTO
+				    waitlist_entries (entry_id, show_id, customer_email, number_of_tickets, price_category, status, created_at)
+				VALUES
+				    (:entry_id, :show_id, :customer_email, :number_of_tickets, NULLIF(:price_category, ''), :status, :created_at)
+				ON CONFLICT DO NOTHING
+			`, entry)
+			if err != nil {
+				return fmt.Errorf("could not add waitlist entry: %w", err)
+			}
+
+			return w.offerFreedSeats(ctx, tx, entry.ShowID)
+		},
+	)
+}
+
+func (w WaitlistRepository) FindByID(ctx context.Context, entryID uuid.UUID) (entities.WaitlistEntry, error) {
+	var entry entities.WaitlistEntry
+	err := sqlx.GetContext(ctx, util.Executor(ctx, w.db), &entry, `
+		SELECT
+		    `+waitlistEntryColumns+`
+		FROM
+		    waitlist_entries
+		WHERE
+		    entry_id = $1
+	`, entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.WaitlistEntry{}, ErrWaitlistEntryNotFound
+	}
+	if err != nil {
+		return entities.WaitlistEntry{}, fmt.Errorf("could not get waitlist entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// OfferFreedSeats offers seats which are not booked nor held to the customers waiting for the show.
+func (w WaitlistRepository) OfferFreedSeats(ctx context.Context, showID uuid.UUID) error {
+	return w.inTx(
+		ctx,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			return w.offerFreedSeats(ctx, tx, showID)
+		},
+	)
+}
+
+// offerFreedSeats offers free seats in FIFO order.
+// When the first waiting customer wants more seats than are free, nobody behind them gets an offer,
+// so customers asking for more tickets aren't skipped forever.
+func (w WaitlistRepository) offerFreedSeats(ctx context.Context, tx *sqlx.Tx, showID uuid.UUID) error {
+	var show struct {
+		NumberOfTickets int  `db:"number_of_tickets"`
+		Canceled        bool `db:"canceled"`
+	}
+	// locking the show, so seats are not offered twice by handlers running in parallel
+	err := tx.GetContext(ctx, &show, `
+		SELECT
+		    number_of_tickets,
+		    canceled_at IS NOT NULL AS canceled
+		FROM
+		    shows
+		WHERE
+		    show_id = $1
+		FOR UPDATE
+	`, showID)
+	if err != nil {
+		return fmt.Errorf("could not get show: %w", err)
+	}
+	if show.Canceled {
+		return nil
+	}
+
+	taken, err := takenSeats(ctx, tx, showID)
+	if err != nil {
+		return err
+	}
+	freeSeats := show.NumberOfTickets - taken
+
+	var waiting []entities.WaitlistEntry
+	err = tx.SelectContext(ctx, &waiting, `
+		SELECT
+		    `+waitlistEntryColumns+`
+		FROM
+		    waitlist_entries
+		WHERE
+		    show_id = $1 AND status = $2
+		ORDER BY
+		    position
+	`, showID, entities.WaitlistEntryWaiting)
+	if err != nil {
+		return fmt.Errorf("could not get waitlist entries: %w", err)
+	}
+
+	var offers []any
+	for _, entry := range waiting {
+		if entry.NumberOfTickets > freeSeats {
+			break
+		}
+
+		expiresAt := time.Now().Add(w.offerTTL)
+		_, err := tx.ExecContext(ctx, `
+			UPDATE
+			    waitlist_entries
+			SET
+			    status = $1, offer_expires_at = $2
+			WHERE
+			    entry_id = $3
+		`, entities.WaitlistEntryOffered, expiresAt, entry.EntryID)
+		if err != nil {
+			return fmt.Errorf("could not offer seats to waitlist entry: %w", err)
+		}
+		freeSeats -= entry.NumberOfTickets
+
+		offers = append(offers, entities.WaitlistSeatOffered_v1{
+			Header:          entities.NewEventHeader(),
+			EntryID:         entry.EntryID,
+			ShowID:          entry.ShowID,
+			CustomerEmail:   entry.CustomerEmail,
+			NumberOfTickets: entry.NumberOfTickets,
+			ExpiresAt:       expiresAt,
+		})
+	}
+	if len(offers) == 0 {
+		return nil
+	}
+
+	outboxPublisher, err := outbox.NewPublisherForDb(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("could not create outbox publisher: %w", err)
+	}
+
+	eventBus := events.NewEventBus(outboxPublisher)
+	for _, offer := range offers {
+		if err := eventBus.Publish(ctx, offer); err != nil {
+			return fmt.Errorf("could not publish event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Claim books the seats held by the offer. Claiming an already claimed offer returns the same booking.
+func (w WaitlistRepository) Claim(ctx context.Context, entryID uuid.UUID) (uuid.UUID, error) {
+	var bookingID uuid.UUID
+
+	err := w.inTx(
+		ctx,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var entry entities.WaitlistEntry
+			err := tx.GetContext(ctx, &entry, `
+				SELECT
+				    `+waitlistEntryColumns+`
+				FROM
+				    waitlist_entries
+				WHERE
+				    entry_id = $1
+				FOR UPDATE
+			`, entryID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrWaitlistEntryNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("could not get waitlist entry: %w", err)
+			}
+
+			switch {
+			case entry.Status == entities.WaitlistEntryClaimed && entry.BookingID != nil:
+				bookingID = *entry.BookingID
+				return nil
+			case entry.Status == entities.WaitlistEntryExpired:
+				return ErrWaitlistOfferExpired
+			case entry.Status != entities.WaitlistEntryOffered:
+				return ErrWaitlistOfferNotActive
+			case entry.OfferExpiresAt == nil || !entry.OfferExpiresAt.After(time.Now()):
+				return ErrWaitlistOfferExpired
+			}
+
+			bookingID = uuid.New()
+
+			// the seats are not held after the entry is claimed, so the booking can take them
+			_, err = tx.ExecContext(ctx, `
+				UPDATE
+				    waitlist_entries
+				SET
+				    status = $1, booking_id = $2
+				WHERE
+				    entry_id = $3
+			`, entities.WaitlistEntryClaimed, bookingID, entryID)
+			if err != nil {
+				return fmt.Errorf("could not claim waitlist entry: %w", err)
+			}
+
+			return BookingRepository{db: w.db}.add(ctx, tx, entities.Booking{
+				BookingID:       bookingID,
+				ShowID:          entry.ShowID,
+				NumberOfTickets: entry.NumberOfTickets,
+				CustomerEmail:   entry.CustomerEmail,
+				PriceCategory:   entry.PriceCategory,
+			})
+		},
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return bookingID, nil
+}
+
+// ExpireOffer releases seats held by an offer which wasn't claimed in time and offers them to the next customers.
+// It does nothing if the offer was claimed or already expired.
+func (w WaitlistRepository) ExpireOffer(ctx context.Context, entryID uuid.UUID) error {
+	return w.inTx(
+		ctx,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var entry entities.WaitlistEntry
+			err := tx.GetContext(ctx, &entry, `
+				SELECT
+				    `+waitlistEntryColumns+`
+				FROM
+				    waitlist_entries
+				WHERE
+				    entry_id = $1
+				FOR UPDATE
+			`, entryID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrWaitlistEntryNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("could not get waitlist entry: %w", err)
+			}
+
+			if entry.Status != entities.WaitlistEntryOffered {
+				return nil
+			}
+			if entry.OfferExpiresAt != nil && entry.OfferExpiresAt.After(time.Now()) {
+				// the message was released too early, it's retried
+				return fmt.Errorf("offer of waitlist entry %s expires at %s", entryID, entry.OfferExpiresAt)
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				UPDATE
+				    waitlist_entries
+				SET
+				    status = $1
+				WHERE
+				    entry_id = $2
+			`, entities.WaitlistEntryExpired, entryID)
+			if err != nil {
+				return fmt.Errorf("could not expire waitlist offer: %w", err)
+			}
+
+			return w.offerFreedSeats(ctx, tx, entry.ShowID)
+		},
+	)
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"tickets/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitlistRepository(t *testing.T) {
+	ctx := context.Background()
+
+	db := getDb()
+
+	err := InitializeDatabaseSchema(db)
+	require.NoError(t, err)
+
+	bookingsRepo := NewBookingRepository(db)
+	showsRepo := NewShowRepository(db)
+
+	addSoldOutShow := func(t *testing.T) (uuid.UUID, uuid.UUID) {
+		showID := uuid.New()
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 2,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Example venue",
+		})
+		require.NoError(t, err)
+
+		bookingID := uuid.New()
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       bookingID,
+			ShowID:          showID,
+			NumberOfTickets: 2,
+			CustomerEmail:   "foo@bar.com",
+		})
+		require.NoError(t, err)
+
+		return showID, bookingID
+	}
+
+	joinWaitlist := func(t *testing.T, repo WaitlistRepository, showID uuid.UUID, tickets int) uuid.UUID {
+		entryID := uuid.New()
+		err := repo.Add(ctx, entities.WaitlistEntry{
+			EntryID:         entryID,
+			ShowID:          showID,
+			CustomerEmail:   "waiting@bar.com",
+			NumberOfTickets: tickets,
+		})
+		require.NoError(t, err)
+
+		return entryID
+	}
+
+	requireStatus := func(t *testing.T, repo WaitlistRepository, entryID uuid.UUID, status entities.WaitlistEntryStatus) {
+		entry, err := repo.FindByID(ctx, entryID)
+		require.NoError(t, err)
+		require.Equal(t, status, entry.Status)
+	}
+
+	t.Run("offer_and_claim", func(t *testing.T) {
+		waitlistRepo := NewWaitlistRepository(db, time.Hour)
+		showID, bookingID := addSoldOutShow(t)
+
+		firstEntryID := joinWaitlist(t, waitlistRepo, showID, 2)
+		secondEntryID := joinWaitlist(t, waitlistRepo, showID, 1)
+		requireStatus(t, waitlistRepo, firstEntryID, entities.WaitlistEntryWaiting)
+
+		_, err := waitlistRepo.Claim(ctx, firstEntryID)
+		require.ErrorIs(t, err, ErrWaitlistOfferNotActive)
+
+		err = bookingsRepo.Cancel(ctx, bookingID)
+		require.NoError(t, err)
+
+		err = waitlistRepo.OfferFreedSeats(ctx, showID)
+		require.NoError(t, err)
+
+		requireStatus(t, waitlistRepo, firstEntryID, entities.WaitlistEntryOffered)
+		// FIFO, the first entry takes all freed seats
+		requireStatus(t, waitlistRepo, secondEntryID, entities.WaitlistEntryWaiting)
+
+		// held seats can't be booked by other customers
+		err = bookingsRepo.Add(ctx, entities.Booking{
+			BookingID:       uuid.New(),
+			ShowID:          showID,
+			NumberOfTickets: 1,
+			CustomerEmail:   "foo@bar.com",
+		})
+		require.ErrorIs(t, err, ErrNoPlacesLeft)
+
+		claimedBookingID, err := waitlistRepo.Claim(ctx, firstEntryID)
+		require.NoError(t, err)
+
+		booking, err := bookingsRepo.FindByID(ctx, claimedBookingID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, booking.NumberOfTickets)
+
+		// claiming is idempotent
+		claimedAgainBookingID, err := waitlistRepo.Claim(ctx, firstEntryID)
+		require.NoError(t, err)
+		assert.Equal(t, claimedBookingID, claimedAgainBookingID)
+	})
+
+	t.Run("expired_offer_goes_to_next_entry", func(t *testing.T) {
+		waitlistRepo := NewWaitlistRepository(db, 10*time.Millisecond)
+		showID, bookingID := addSoldOutShow(t)
+
+		firstEntryID := joinWaitlist(t, waitlistRepo, showID, 2)
+		secondEntryID := joinWaitlist(t, waitlistRepo, showID, 2)
+
+		err = bookingsRepo.Cancel(ctx, bookingID)
+		require.NoError(t, err)
+
+		err = waitlistRepo.OfferFreedSeats(ctx, showID)
+		require.NoError(t, err)
+		requireStatus(t, waitlistRepo, firstEntryID, entities.WaitlistEntryOffered)
+
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = waitlistRepo.Claim(ctx, firstEntryID)
+		require.ErrorIs(t, err, ErrWaitlistOfferExpired)
+
+		err = waitlistRepo.ExpireOffer(ctx, firstEntryID)
+		require.NoError(t, err)
+
+		requireStatus(t, waitlistRepo, firstEntryID, entities.WaitlistEntryExpired)
+		requireStatus(t, waitlistRepo, secondEntryID, entities.WaitlistEntryOffered)
+	})
+
+	t.Run("unknown_price_category", func(t *testing.T) {
+		waitlistRepo := NewWaitlistRepository(db, time.Hour)
+
+		showID := uuid.New()
+		err := showsRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 2,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Example venue",
+			PriceCategories: entities.PriceCategories{
+				{Name: "standard", Price: entities.MustNewMoney("50.00", "EUR")},
+			},
+		})
+		require.NoError(t, err)
+
+		err = waitlistRepo.Add(ctx, entities.WaitlistEntry{
+			EntryID:         uuid.New(),
+			ShowID:          showID,
+			CustomerEmail:   "waiting@bar.com",
+			NumberOfTickets: 1,
+			PriceCategory:   "vip",
+		})
+		require.ErrorIs(t, err, ErrUnknownPriceCategory)
+
+		err = waitlistRepo.Add(ctx, entities.WaitlistEntry{
+			EntryID:         uuid.New(),
+			ShowID:          showID,
+			CustomerEmail:   "waiting@bar.com",
+			NumberOfTickets: 1,
+			PriceCategory:   "standard",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("show_canceled_expires_entries", func(t *testing.T) {
+		waitlistRepo := NewWaitlistRepository(db, time.Hour)
+		showID, bookingID := addSoldOutShow(t)
+
+		offeredEntryID := joinWaitlist(t, waitlistRepo, showID, 2)
+		waitingEntryID := joinWaitlist(t, waitlistRepo, showID, 1)
+
+		err = bookingsRepo.Cancel(ctx, bookingID)
+		require.NoError(t, err)
+
+		err = waitlistRepo.OfferFreedSeats(ctx, showID)
+		require.NoError(t, err)
+		requireStatus(t, waitlistRepo, offeredEntryID, entities.WaitlistEntryOffered)
+
+		err = showsRepo.Cancel(ctx, showID)
+		require.NoError(t, err)
+
+		requireStatus(t, waitlistRepo, offeredEntryID, entities.WaitlistEntryExpired)
+		requireStatus(t, waitlistRepo, waitingEntryID, entities.WaitlistEntryExpired)
+
+		_, err = waitlistRepo.Claim(ctx, offeredEntryID)
+		require.ErrorIs(t, err, ErrWaitlistOfferExpired)
+	})
+}
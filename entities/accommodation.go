@@ -0,0 +1,29 @@
+package entities
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoHotelRoomsAvailable   = errors.New("no hotel rooms available")
+	ErrHotelBookingUnavailable = errors.New("hotel booking is not available")
+)
+
+type BookHotelRoomRequest struct {
+	CustomerEmail  string
+	HotelID        uuid.UUID
+	NumberOfGuests int
+	Nights         int
+	ReferenceId    string
+	IdempotencyKey string
+}
+
+type BookHotelRoomResponse struct {
+	HotelBookingID uuid.UUID `json:"hotel_booking_id"`
+}
+
+type CancelHotelRoomBookingRequest struct {
+	HotelBookingID uuid.UUID `json:"hotel_booking_id"`
+}
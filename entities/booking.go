@@ -1,12 +1,33 @@
 package entities
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrDeadNationBookingRejected is returned when Dead Nation refuses the booking, retrying it won't help.
+var ErrDeadNationBookingRejected = errors.New("booking rejected by Dead Nation")
+
+// ErrDeadNationCancellationNotSupported is returned while the Dead Nation API has no endpoint canceling bookings.
+var ErrDeadNationCancellationNotSupported = errors.New("Dead Nation API doesn't support canceling bookings")
 
 type Booking struct {
-	BookingID       uuid.UUID `json:"booking_id" db:"booking_id"`
-	ShowID          uuid.UUID `json:"show_id" db:"show_id"`
-	NumberOfTickets int       `json:"number_of_tickets" db:"number_of_tickets"`
-	CustomerEmail   string    `json:"customer_email" db:"customer_email"`
+	BookingID       uuid.UUID  `json:"booking_id" db:"booking_id"`
+	ShowID          uuid.UUID  `json:"show_id" db:"show_id"`
+	NumberOfTickets int        `json:"number_of_tickets" db:"number_of_tickets"`
+	CustomerEmail   string     `json:"customer_email" db:"customer_email"`
+	CanceledAt      *time.Time `json:"canceled_at,omitempty" db:"canceled_at"`
+	RefundedTickets int        `json:"refunded_tickets" db:"refunded_tickets"`
+
+	// PriceCategory is chosen by the customer, TicketPrice is the price of one ticket in this category.
+	// Both are empty for shows without price categories.
+	PriceCategory string `json:"price_category,omitempty" db:"price_category"`
+	TicketPrice   Money  `json:"ticket_price" db:"ticket_price"`
+
+	// Seats are chosen by the customer or allocated when the booking is added, if the show has a seat map
+	Seats []Seat `json:"seats,omitempty" db:"-"`
 }
 
 type DeadNationBooking struct {
@@ -1,11 +1,37 @@
 package entities
 
-import "github.com/google/uuid"
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+type Command interface {
+	// DeduplicationKey is used by the inbox to skip commands which were already handled.
+	DeduplicationKey() string
+}
 
 type RefundTicket struct {
 	Header EventHeader `json:"header"`
 
 	TicketID string `json:"ticket_id"`
+
+	// ReferenceID is set when the refund compensates a process, like a VIP bundle
+	ReferenceID string `json:"reference_id,omitempty"`
+}
+
+func (c RefundTicket) DeduplicationKey() string {
+	return c.Header.DeduplicationKey()
+}
+
+// NewRefundTicketOfCanceledBooking returns the refund of a ticket of a canceled booking.
+// The key is the same whether the ticket was confirmed before or after the cancellation, so it's refunded once.
+func NewRefundTicketOfCanceledBooking(bookingID uuid.UUID, ticketID string) RefundTicket {
+	return RefundTicket{
+		Header:   NewEventHeaderWithIdempotencyKey(bookingID.String() + "-refund-" + ticketID),
+		TicketID: ticketID,
+	}
 }
 
 type BookShowTickets struct {
@@ -16,22 +42,111 @@ type BookShowTickets struct {
 	ShowId          uuid.UUID `json:"show_id"`
 }
 
+func (c BookShowTickets) DeduplicationKey() string {
+	return c.BookingID.String()
+}
+
+type CancelBooking struct {
+	BookingID uuid.UUID `json:"booking_id"`
+}
+
+func (c CancelBooking) DeduplicationKey() string {
+	return c.BookingID.String()
+}
+
+// ExpireWaitlistOffer is scheduled for the expiration time of the waitlist offer.
+type ExpireWaitlistOffer struct {
+	EntryID uuid.UUID `json:"entry_id"`
+}
+
+func (c ExpireWaitlistOffer) DeduplicationKey() string {
+	return c.EntryID.String()
+}
+
 type BookFlight struct {
 	CustomerEmail  string    `json:"customer_email"`
 	FlightID       uuid.UUID `json:"to_flight_id"`
+	Leg            FlightLeg `json:"leg,omitempty"`
 	Passengers     []string  `json:"passengers"`
 	ReferenceID    string    `json:"reference_id"`
 	IdempotencyKey string    `json:"idempotency_key"`
 }
 
+func (c BookFlight) DeduplicationKey() string {
+	return c.IdempotencyKey
+}
+
 type BookTaxi struct {
 	CustomerEmail      string `json:"customer_email"`
 	CustomerName       string `json:"customer_name"`
 	NumberOfPassengers int    `json:"number_of_passengers"`
 	ReferenceID        string `json:"reference_id"`
 	IdempotencyKey     string `json:"idempotency_key"`
+
+	// TaxiIndex identifies the taxi when passengers of a booking don't fit in one
+	TaxiIndex int `json:"taxi_index"`
+}
+
+func (c BookTaxi) DeduplicationKey() string {
+	return c.IdempotencyKey
 }
 
 type CancelFlightTickets struct {
 	FlightTicketIDs []uuid.UUID `json:"flight_ticket_id"`
+
+	FlightID       uuid.UUID `json:"flight_id"`
+	ReferenceID    string    `json:"reference_id"`
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+}
+
+func (c CancelFlightTickets) DeduplicationKey() string {
+	if c.IdempotencyKey != "" {
+		return c.IdempotencyKey
+	}
+
+	// canceling the same tickets twice has no effect, so tickets are the natural key
+	return strings.Join(lo.Map(c.FlightTicketIDs, func(id uuid.UUID, _ int) string {
+		return id.String()
+	}), ",")
+}
+
+type CancelTaxi struct {
+	TaxiBookingID  uuid.UUID `json:"taxi_booking_id"`
+	ReferenceID    string    `json:"reference_id"`
+	IdempotencyKey string    `json:"idempotency_key"`
+}
+
+func (c CancelTaxi) DeduplicationKey() string {
+	if c.IdempotencyKey != "" {
+		return c.IdempotencyKey
+	}
+
+	return c.TaxiBookingID.String()
+}
+
+type BookHotel struct {
+	CustomerEmail  string    `json:"customer_email"`
+	HotelID        uuid.UUID `json:"hotel_id"`
+	NumberOfGuests int       `json:"number_of_guests"`
+	Nights         int       `json:"nights"`
+	ReferenceID    string    `json:"reference_id"`
+	IdempotencyKey string    `json:"idempotency_key"`
+}
+
+func (c BookHotel) DeduplicationKey() string {
+	return c.IdempotencyKey
+}
+
+type CancelHotel struct {
+	HotelBookingID uuid.UUID `json:"hotel_booking_id"`
+	ReferenceID    string    `json:"reference_id"`
+	IdempotencyKey string    `json:"idempotency_key"`
+}
+
+func (c CancelHotel) DeduplicationKey() string {
+	if c.IdempotencyKey != "" {
+		return c.IdempotencyKey
+	}
+
+	return c.HotelBookingID.String()
 }
@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeadLetter struct {
+	DeadLetterID uuid.UUID `json:"dead_letter_id"`
+
+	MessageUUID string            `json:"message_uuid"`
+	Topic       string            `json:"topic"`
+	HandlerName string            `json:"handler_name"`
+	Payload     []byte            `json:"payload"`
+	Metadata    map[string]string `json:"metadata"`
+
+	Error      string    `json:"error"`
+	RetryCount int       `json:"retry_count"`
+	FailedAt   time.Time `json:"failed_at"`
+}
@@ -28,8 +28,25 @@ func NewEventHeaderWithIdempotencyKey(idempotencyKey string) EventHeader {
 	}
 }
 
+// DeduplicationKey is used by the inbox to skip messages which were already handled.
+// IdempotencyKey is preferred, so the same event re-published (e.g. by a webhook retry) is also skipped.
+func (h EventHeader) DeduplicationKey() string {
+	if h.IdempotencyKey != "" {
+		return h.IdempotencyKey
+	}
+
+	return h.ID
+}
+
 type Event interface {
 	IsInternal() bool
+	GetHeader() EventHeader
+}
+
+// OrderedEvent is implemented by events which start or belong to the flow of a single booking.
+// Events with the same ordering key are handled by ordered consumers in the publish order.
+type OrderedEvent interface {
+	OrderingKey() string
 }
 
 type TicketBookingConfirmed_v1 struct {
@@ -46,6 +63,14 @@ func (e TicketBookingConfirmed_v1) IsInternal() bool {
 	return false
 }
 
+func (e TicketBookingConfirmed_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e TicketBookingConfirmed_v1) OrderingKey() string {
+	return e.BookingID
+}
+
 type TicketBookingCanceled_v1 struct {
 	Header EventHeader `json:"header"`
 
@@ -58,16 +83,26 @@ func (e TicketBookingCanceled_v1) IsInternal() bool {
 	return false
 }
 
+func (e TicketBookingCanceled_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
 type TicketRefunded_v1 struct {
 	Header EventHeader `json:"header"`
 
 	TicketID string `json:"ticket_id"`
+
+	ReferenceID string `json:"reference_id,omitempty"`
 }
 
 func (e TicketRefunded_v1) IsInternal() bool {
 	return false
 }
 
+func (e TicketRefunded_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
 type TicketPrinted_v1 struct {
 	Header EventHeader `json:"header"`
 
@@ -79,6 +114,10 @@ func (e TicketPrinted_v1) IsInternal() bool {
 	return false
 }
 
+func (e TicketPrinted_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
 type TicketReceiptIssued_v1 struct {
 	Header EventHeader `json:"header"`
 
@@ -92,6 +131,10 @@ func (e TicketReceiptIssued_v1) IsInternal() bool {
 	return false
 }
 
+func (e TicketReceiptIssued_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
 type BookingMade_v1 struct {
 	Header EventHeader `json:"header"`
 
@@ -102,12 +145,156 @@ type BookingMade_v1 struct {
 	CustomerEmail     string    `json:"customer_email"`
 	ShowId            uuid.UUID `json:"show_id"`
 	DeadNationEventID uuid.UUID `json:"dead_nation_id"`
+
+	Seats []Seat `json:"seats,omitempty"`
+
+	PriceCategory string `json:"price_category,omitempty"`
+	TicketPrice   *Money `json:"ticket_price,omitempty"`
 }
 
 func (e BookingMade_v1) IsInternal() bool {
 	return false
 }
 
+func (e BookingMade_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e BookingMade_v1) OrderingKey() string {
+	return e.BookingID.String()
+}
+
+type BookingCanceled_v1 struct {
+	Header EventHeader `json:"header"`
+
+	BookingID uuid.UUID `json:"booking_id"`
+
+	// NumberOfTickets is the number of seats released for the show
+	NumberOfTickets int       `json:"number_of_tickets"`
+	CustomerEmail   string    `json:"customer_email"`
+	ShowId          uuid.UUID `json:"show_id"`
+}
+
+func (e BookingCanceled_v1) IsInternal() bool {
+	return false
+}
+
+func (e BookingCanceled_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e BookingCanceled_v1) OrderingKey() string {
+	return e.BookingID.String()
+}
+
+type DeadNationBookingFailed_v1 struct {
+	Header EventHeader `json:"header"`
+
+	BookingID     uuid.UUID `json:"booking_id"`
+	FailureReason string    `json:"failure_reason"`
+}
+
+func (e DeadNationBookingFailed_v1) IsInternal() bool {
+	return false
+}
+
+func (e DeadNationBookingFailed_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e DeadNationBookingFailed_v1) OrderingKey() string {
+	return e.BookingID.String()
+}
+
+// ShowRescheduled_v1 is published when the start time of the show changes, so customers can be notified.
+type ShowRescheduled_v1 struct {
+	Header EventHeader `json:"header"`
+
+	ShowID            uuid.UUID `json:"show_id"`
+	Title             string    `json:"title"`
+	Venue             string    `json:"venue"`
+	PreviousStartTime time.Time `json:"previous_start_time"`
+	StartTime         time.Time `json:"start_time"`
+}
+
+func (e ShowRescheduled_v1) IsInternal() bool {
+	return false
+}
+
+func (e ShowRescheduled_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e ShowRescheduled_v1) OrderingKey() string {
+	return e.ShowID.String()
+}
+
+// ShowCanceled_v1 is published when the show is canceled, all its bookings are canceled and refunded then.
+type ShowCanceled_v1 struct {
+	Header EventHeader `json:"header"`
+
+	ShowID    uuid.UUID `json:"show_id"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+}
+
+func (e ShowCanceled_v1) IsInternal() bool {
+	return false
+}
+
+func (e ShowCanceled_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e ShowCanceled_v1) OrderingKey() string {
+	return e.ShowID.String()
+}
+
+// ShowCapacityIncreased_v1 is published when more tickets of the show can be sold.
+type ShowCapacityIncreased_v1 struct {
+	Header EventHeader `json:"header"`
+
+	ShowID                  uuid.UUID `json:"show_id"`
+	PreviousNumberOfTickets int       `json:"previous_number_of_tickets"`
+	NumberOfTickets         int       `json:"number_of_tickets"`
+}
+
+func (e ShowCapacityIncreased_v1) IsInternal() bool {
+	return false
+}
+
+func (e ShowCapacityIncreased_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e ShowCapacityIncreased_v1) OrderingKey() string {
+	return e.ShowID.String()
+}
+
+// WaitlistSeatOffered_v1 is published when freed seats are held for a customer from the waitlist.
+// The customer has to claim them before ExpiresAt, otherwise they are offered to the next customer.
+type WaitlistSeatOffered_v1 struct {
+	Header EventHeader `json:"header"`
+
+	EntryID         uuid.UUID `json:"entry_id"`
+	ShowID          uuid.UUID `json:"show_id"`
+	CustomerEmail   string    `json:"customer_email"`
+	NumberOfTickets int       `json:"number_of_tickets"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+func (e WaitlistSeatOffered_v1) IsInternal() bool {
+	return false
+}
+
+func (e WaitlistSeatOffered_v1) GetHeader() EventHeader {
+	return e.Header
+}
+
+func (e WaitlistSeatOffered_v1) OrderingKey() string {
+	return e.ShowID.String()
+}
+
 type InternalOpsReadModelUpdated struct {
 	Header EventHeader `json:"header"`
 
@@ -118,16 +305,29 @@ func (e InternalOpsReadModelUpdated) IsInternal() bool {
 	return true
 }
 
+func (e InternalOpsReadModelUpdated) GetHeader() EventHeader {
+	return e.Header
+}
+
 type VipBundleInitialized_v1 struct {
 	Header EventHeader `json:"header"`
 
 	VipBundleID uuid.UUID `json:"vip_bundle_id"`
+	BookingID   uuid.UUID `json:"booking_id"`
 }
 
 func (v VipBundleInitialized_v1) IsInternal() bool {
 	return false
 }
 
+func (v VipBundleInitialized_v1) GetHeader() EventHeader {
+	return v.Header
+}
+
+func (v VipBundleInitialized_v1) OrderingKey() string {
+	return v.BookingID.String()
+}
+
 type BookingFailed_v1 struct {
 	Header EventHeader `json:"header"`
 
@@ -139,10 +339,19 @@ func (b BookingFailed_v1) IsInternal() bool {
 	return false
 }
 
+func (b BookingFailed_v1) GetHeader() EventHeader {
+	return b.Header
+}
+
+func (b BookingFailed_v1) OrderingKey() string {
+	return b.BookingID.String()
+}
+
 type FlightBooked_v1 struct {
 	Header EventHeader `json:"header"`
 
 	FlightID  uuid.UUID   `json:"flight_id"`
+	Leg       FlightLeg   `json:"leg,omitempty"`
 	TicketIDs []uuid.UUID `json:"flight_tickets_ids"`
 
 	ReferenceID string `json:"reference_id"`
@@ -152,10 +361,15 @@ func (f FlightBooked_v1) IsInternal() bool {
 	return false
 }
 
+func (f FlightBooked_v1) GetHeader() EventHeader {
+	return f.Header
+}
+
 type FlightBookingFailed_v1 struct {
 	Header EventHeader `json:"header"`
 
 	FlightID      uuid.UUID `json:"flight_id"`
+	Leg           FlightLeg `json:"leg,omitempty"`
 	FailureReason string    `json:"failure_reason"`
 
 	ReferenceID string `json:"reference_id"`
@@ -165,10 +379,15 @@ func (f FlightBookingFailed_v1) IsInternal() bool {
 	return false
 }
 
+func (f FlightBookingFailed_v1) GetHeader() EventHeader {
+	return f.Header
+}
+
 type TaxiBooked_v1 struct {
 	Header EventHeader `json:"header"`
 
 	TaxiBookingID uuid.UUID `json:"taxi_booking_id"`
+	TaxiIndex     int       `json:"taxi_index"`
 
 	ReferenceID string `json:"reference_id"`
 }
@@ -177,6 +396,10 @@ func (t TaxiBooked_v1) IsInternal() bool {
 	return false
 }
 
+func (t TaxiBooked_v1) GetHeader() EventHeader {
+	return t.Header
+}
+
 type VipBundleFinalized_v1 struct {
 	Header EventHeader `json:"header"`
 
@@ -187,10 +410,161 @@ func (v VipBundleFinalized_v1) IsInternal() bool {
 	return false
 }
 
+func (v VipBundleFinalized_v1) GetHeader() EventHeader {
+	return v.Header
+}
+
+type FlightTicketsCanceled_v1 struct {
+	Header EventHeader `json:"header"`
+
+	FlightID        uuid.UUID   `json:"flight_id"`
+	FlightTicketIDs []uuid.UUID `json:"flight_ticket_ids"`
+	ReferenceID     string      `json:"reference_id"`
+}
+
+func (f FlightTicketsCanceled_v1) IsInternal() bool {
+	return false
+}
+
+func (f FlightTicketsCanceled_v1) GetHeader() EventHeader {
+	return f.Header
+}
+
+type TaxiBookingCanceled_v1 struct {
+	Header EventHeader `json:"header"`
+
+	TaxiBookingID uuid.UUID `json:"taxi_booking_id"`
+	ReferenceID   string    `json:"reference_id"`
+}
+
+func (t TaxiBookingCanceled_v1) IsInternal() bool {
+	return false
+}
+
+func (t TaxiBookingCanceled_v1) GetHeader() EventHeader {
+	return t.Header
+}
+
+type HotelBooked_v1 struct {
+	Header EventHeader `json:"header"`
+
+	HotelID        uuid.UUID `json:"hotel_id"`
+	HotelBookingID uuid.UUID `json:"hotel_booking_id"`
+
+	ReferenceID string `json:"reference_id"`
+}
+
+func (h HotelBooked_v1) IsInternal() bool {
+	return false
+}
+
+func (h HotelBooked_v1) GetHeader() EventHeader {
+	return h.Header
+}
+
+type HotelBookingFailed_v1 struct {
+	Header EventHeader `json:"header"`
+
+	HotelID       uuid.UUID `json:"hotel_id"`
+	FailureReason string    `json:"failure_reason"`
+
+	ReferenceID string `json:"reference_id"`
+}
+
+func (h HotelBookingFailed_v1) IsInternal() bool {
+	return false
+}
+
+func (h HotelBookingFailed_v1) GetHeader() EventHeader {
+	return h.Header
+}
+
+type HotelBookingCanceled_v1 struct {
+	Header EventHeader `json:"header"`
+
+	HotelBookingID uuid.UUID `json:"hotel_booking_id"`
+	ReferenceID    string    `json:"reference_id"`
+}
+
+func (h HotelBookingCanceled_v1) IsInternal() bool {
+	return false
+}
+
+func (h HotelBookingCanceled_v1) GetHeader() EventHeader {
+	return h.Header
+}
+
+type VipBundleFailed_v1 struct {
+	Header EventHeader `json:"header"`
+
+	VipBundleID   uuid.UUID     `json:"vip_bundle_id"`
+	BookingID     uuid.UUID     `json:"booking_id"`
+	FailureReason string        `json:"failure_reason"`
+	FailedStep    VipBundleStep `json:"failed_step"`
+}
+
+func (v VipBundleFailed_v1) IsInternal() bool {
+	return false
+}
+
+func (v VipBundleFailed_v1) GetHeader() EventHeader {
+	return v.Header
+}
+
+func (v VipBundleFailed_v1) OrderingKey() string {
+	return v.BookingID.String()
+}
+
+// VipBundleCancellationRequested_v1 is published when the customer cancels the bundle.
+type VipBundleCancellationRequested_v1 struct {
+	Header EventHeader `json:"header"`
+
+	VipBundleID uuid.UUID `json:"vip_bundle_id"`
+	BookingID   uuid.UUID `json:"booking_id"`
+}
+
+func (v VipBundleCancellationRequested_v1) IsInternal() bool {
+	return false
+}
+
+func (v VipBundleCancellationRequested_v1) GetHeader() EventHeader {
+	return v.Header
+}
+
+func (v VipBundleCancellationRequested_v1) OrderingKey() string {
+	return v.BookingID.String()
+}
+
+// VipBundleStepTimedOut_v1 is scheduled when a step of the VIP bundle starts.
+// It's ignored if the step has completed before the deadline.
+type VipBundleStepTimedOut_v1 struct {
+	Header EventHeader `json:"header"`
+
+	VipBundleID uuid.UUID     `json:"vip_bundle_id"`
+	BookingID   uuid.UUID     `json:"booking_id"`
+	Step        VipBundleStep `json:"step"`
+
+	// FlightID is set for flight steps, the deadline is ignored once an alternative flight is being booked
+	FlightID uuid.UUID `json:"flight_id,omitempty"`
+}
+
+func (v VipBundleStepTimedOut_v1) IsInternal() bool {
+	return false
+}
+
+func (v VipBundleStepTimedOut_v1) GetHeader() EventHeader {
+	return v.Header
+}
+
+func (v VipBundleStepTimedOut_v1) OrderingKey() string {
+	return v.BookingID.String()
+}
+
 type TaxiBookingFailed_v1 struct {
 	Header EventHeader `json:"header"`
 
 	FailureReason string `json:"failure_reason"`
+	TaxiIndex     int    `json:"taxi_index"`
 
 	ReferenceID string `json:"reference_id"`
 }
@@ -198,3 +572,7 @@ type TaxiBookingFailed_v1 struct {
 func (t TaxiBookingFailed_v1) IsInternal() bool {
 	return false
 }
+
+func (t TaxiBookingFailed_v1) GetHeader() EventHeader {
+	return t.Header
+}
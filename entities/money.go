@@ -1,6 +1,173 @@
 package entities
 
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var ErrCurrencyMismatch = errors.New("currencies don't match")
+
+// Money is an exact amount in an ISO 4217 currency.
+// It's sent as {"amount": "10.50", "currency": "EUR"} and stored in NUMERIC and CHAR(3) columns.
 type Money struct {
-	Amount   string `json:"amount"`
-	Currency string `json:"currency"`
+	Amount   Decimal `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func NewMoney(amount string, currency string) (Money, error) {
+	parsed, err := ParseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+
+	money := Money{Amount: parsed, Currency: currency}
+	if err := money.Validate(); err != nil {
+		return Money{}, err
+	}
+
+	return money, nil
+}
+
+// MustNewMoney is NewMoney for constants, it panics if the amount or currency is invalid.
+func MustNewMoney(amount string, currency string) Money {
+	money, err := NewMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+
+	return money
+}
+
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+// Normalized returns the money with an upper case currency code.
+// Currency codes are compared exactly, so prices received from other services are normalized first.
+func (m Money) Normalized() Money {
+	m.Currency = strings.ToUpper(strings.TrimSpace(m.Currency))
+	return m
+}
+
+// Equal returns true if both amounts have the same value in the same currency, so "10.0" equals "10.00".
+func (m Money) Equal(other Money) bool {
+	return m.Currency == other.Currency && m.Amount.Equal(other.Amount)
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	return Money{Amount: m.Amount.Add(other.Amount), Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(Money{Amount: other.Amount.Neg(), Currency: other.Currency})
+}
+
+// Multiply returns the amount multiplied by n.
+func (m Money) Multiply(n int) Money {
+	return Money{Amount: m.Amount.Mul(NewDecimal(int64(n), 0)), Currency: m.Currency}
+}
+
+// Round rounds the amount half away from zero to the minor units of the currency, like cents.
+// Amounts in unknown currencies are rounded to two digits.
+func (m Money) Round() Money {
+	return Money{Amount: m.Amount.Round(m.minorUnits()), Currency: m.Currency}
+}
+
+// Convert returns the amount in the other currency using the exchange rate, rounded to its minor units.
+func (m Money) Convert(currency string, rate Decimal) Money {
+	return Money{Amount: m.Amount.Mul(rate), Currency: currency}.Round()
+}
+
+// Allocate splits the amount by the ratios without losing the minor units which can't be split evenly,
+// they are given one by one to the first parts. For example, 100.00 allocated 1:1:1 is 33.34, 33.33 and 33.33.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	total := big.NewInt(0)
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("ratio %d is negative", ratio)
+		}
+		total.Add(total, big.NewInt(int64(ratio)))
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("no ratios to allocate %s by", m)
+	}
+
+	scale := m.minorUnits()
+	amount := m.Amount.Round(scale)
+	remainder := new(big.Int).Abs(amount.int())
+
+	shares := make([]*big.Int, len(ratios))
+	for i, ratio := range ratios {
+		shares[i] = new(big.Int).Mul(new(big.Int).Abs(amount.int()), big.NewInt(int64(ratio)))
+		shares[i].Quo(shares[i], total)
+		remainder.Sub(remainder, shares[i])
+	}
+
+	for i := 0; remainder.Sign() > 0; i = (i + 1) % len(shares) {
+		if ratios[i] == 0 {
+			continue
+		}
+		shares[i].Add(shares[i], big.NewInt(1))
+		remainder.Sub(remainder, big.NewInt(1))
+	}
+
+	parts := make([]Money, len(shares))
+	for i, share := range shares {
+		if amount.Sign() < 0 {
+			share.Neg(share)
+		}
+		parts[i] = Money{Amount: Decimal{unscaled: share, scale: scale}, Currency: m.Currency}
+	}
+
+	return parts, nil
+}
+
+// Validate checks that the amount is set and not negative, the currency is an ISO 4217 code
+// and the amount has no more digits than the minor units of the currency.
+func (m Money) Validate() error {
+	if m.Amount.unscaled == nil {
+		return errors.New("amount is missing")
+	}
+	if m.Amount.Sign() < 0 {
+		return fmt.Errorf("amount %s is negative", m.Amount)
+	}
+
+	minorUnits, ok := CurrencyMinorUnits(m.Currency)
+	if !ok {
+		return fmt.Errorf("invalid currency %q", m.Currency)
+	}
+
+	if !m.Amount.Round(minorUnits).Equal(m.Amount) {
+		return fmt.Errorf("amount %s has more than %d decimal places of %s", m.Amount, minorUnits, m.Currency)
+	}
+
+	return nil
+}
+
+func (m Money) minorUnits() int32 {
+	if minorUnits, ok := CurrencyMinorUnits(m.Currency); ok {
+		return minorUnits
+	}
+
+	return 2
+}
+
+// SumMoney adds up the amounts, all of them must be in the currency.
+func SumMoney(currency string, amounts ...Money) (Money, error) {
+	sum := Money{Amount: NewDecimal(0, 0), Currency: currency}
+	for _, amount := range amounts {
+		var err error
+		sum, err = sum.Add(amount)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+
+	return sum, nil
 }
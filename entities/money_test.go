@@ -0,0 +1,78 @@
+package entities_test
+
+import (
+	"encoding/json"
+	"testing"
+	"tickets/entities"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal_Round(t *testing.T) {
+	testCases := map[string]string{
+		"10.005":  "10.01",
+		"10.004":  "10.00",
+		"-10.005": "-10.01",
+		"0.5":     "0.50",
+		"7":       "7.00",
+	}
+
+	for value, expected := range testCases {
+		assert.Equal(t, expected, entities.MustParseDecimal(value).Round(2).String(), value)
+	}
+}
+
+func TestMoney_Allocate(t *testing.T) {
+	parts, err := entities.MustNewMoney("100.00", "EUR").Allocate(1, 1, 1)
+	require.NoError(t, err)
+
+	var amounts []string
+	for _, part := range parts {
+		amounts = append(amounts, part.Amount.String())
+	}
+	assert.Equal(t, []string{"33.34", "33.33", "33.33"}, amounts)
+
+	parts, err = entities.MustNewMoney("5", "JPY").Allocate(0, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "0", parts[0].Amount.String())
+	assert.Equal(t, "3", parts[1].Amount.String())
+	assert.Equal(t, "2", parts[2].Amount.String())
+}
+
+func TestMoney_Validate(t *testing.T) {
+	assert.NoError(t, entities.MustNewMoney("50.30", "USD").Validate())
+	assert.Error(t, entities.Money{Amount: entities.MustParseDecimal("50.305"), Currency: "USD"}.Validate())
+	assert.Error(t, entities.Money{Amount: entities.MustParseDecimal("1.5"), Currency: "JPY"}.Validate())
+	assert.Error(t, entities.Money{Amount: entities.MustParseDecimal("-1"), Currency: "USD"}.Validate())
+	assert.Error(t, entities.Money{Amount: entities.MustParseDecimal("1"), Currency: "XYZ"}.Validate())
+	assert.Error(t, entities.Money{Currency: "USD"}.Validate(), "amount is missing")
+	assert.Error(t, entities.Money{Amount: entities.MustParseDecimal("1"), Currency: "usd"}.Validate())
+	assert.NoError(t, entities.Money{Amount: entities.MustParseDecimal("1"), Currency: " usd"}.Normalized().Validate())
+}
+
+func TestMoney_Add(t *testing.T) {
+	sum, err := entities.MustNewMoney("10.5", "EUR").Add(entities.MustNewMoney("0.25", "EUR"))
+	require.NoError(t, err)
+	assert.Equal(t, "10.75 EUR", sum.String())
+
+	assert.False(t, entities.MustNewMoney("10", "EUR").Equal(entities.Money{Amount: entities.MustParseDecimal("10"), Currency: "eur"}))
+
+	_, err = entities.MustNewMoney("10", "EUR").Add(entities.MustNewMoney("10", "USD"))
+	assert.ErrorIs(t, err, entities.ErrCurrencyMismatch)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var money entities.Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"50.30","currency":"USD"}`), &money))
+	assert.True(t, entities.MustNewMoney("50.3", "USD").Equal(money))
+
+	payload, err := json.Marshal(money)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"50.30","currency":"USD"}`, string(payload))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":12.5,"currency":"EUR"}`), &money))
+	assert.Equal(t, "12.5 EUR", money.String())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"12,5","currency":"EUR"}`), &money))
+}
@@ -10,19 +10,29 @@ type OpsBooking struct {
 	BookingID uuid.UUID `json:"booking_id"`
 	BookedAt  time.Time `json:"booked_at"`
 
+	CanceledAt *time.Time `json:"canceled_at,omitempty"`
+
+	Seats []Seat `json:"seats,omitempty"`
+
+	// TicketPrice is the price of the booked category, tickets confirmed with a different price are flagged
+	PriceCategory string `json:"price_category,omitempty"`
+	TicketPrice   *Money `json:"ticket_price,omitempty"`
+
 	Tickets map[string]OpsTicket `json:"tickets"`
 
 	LastUpdate time.Time `json:"last_update"`
 }
 
 type OpsTicket struct {
-	PriceAmount   string `json:"price_amount"`
-	PriceCurrency string `json:"price_currency"`
-	CustomerEmail string `json:"customer_email"`
+	PriceAmount   Decimal `json:"price_amount"`
+	PriceCurrency string  `json:"price_currency"`
+	CustomerEmail string  `json:"customer_email"`
 
 	ConfirmedAt time.Time `json:"confirmed_at"`
 	RefundedAt  time.Time `json:"refunded_at"`
 
+	PriceMismatch bool `json:"price_mismatch"`
+
 	PrintedAt       time.Time `json:"printed_at"`
 	PrintedFileName string    `json:"printed_file_name"`
 
@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// PriceCategory is the price of a single ticket of the show, like standard, VIP or student.
+type PriceCategory struct {
+	Name  string `json:"name"`
+	Price Money  `json:"price"`
+}
+
+// PriceCategories are stored as JSON in the shows table.
+type PriceCategories []PriceCategory
+
+// Find returns the category with the name, an empty name selects the first (default) category.
+func (c PriceCategories) Find(name string) (PriceCategory, bool) {
+	for _, category := range c {
+		if name == "" || category.Name == name {
+			return category, true
+		}
+	}
+
+	return PriceCategory{}, false
+}
+
+func (c PriceCategories) Validate() error {
+	seen := map[string]bool{}
+	for _, category := range c {
+		if category.Name == "" {
+			return fmt.Errorf("price category has no name")
+		}
+		if seen[category.Name] {
+			return fmt.Errorf("price category %s is listed twice", category.Name)
+		}
+		seen[category.Name] = true
+
+		if err := category.Price.Validate(); err != nil {
+			return fmt.Errorf("invalid price of category %s: %w", category.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c PriceCategories) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(c)
+}
+
+func (c *PriceCategories) Scan(src any) error {
+	var payload []byte
+	switch src := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		payload = src
+	case string:
+		payload = []byte(src)
+	default:
+		return fmt.Errorf("unsupported price categories type %T", src)
+	}
+
+	var categories PriceCategories
+	if err := json.Unmarshal(payload, &categories); err != nil {
+		return fmt.Errorf("could not unmarshal price categories: %w", err)
+	}
+
+	*c = categories
+
+	return nil
+}
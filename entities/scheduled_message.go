@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScheduledMessage struct {
+	ScheduledMessageID uuid.UUID `json:"scheduled_message_id"`
+
+	MessageUUID string            `json:"message_uuid"`
+	Topic       string            `json:"topic"`
+	Payload     []byte            `json:"payload"`
+	Metadata    map[string]string `json:"metadata"`
+
+	DeliverAt   time.Time `json:"deliver_at"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+
+	// ClaimedUntil is set while the message is being released.
+	// If the service crashes during the release, the message is released again after this time.
+	ClaimedUntil *time.Time `json:"claimed_until,omitempty"`
+}
@@ -0,0 +1,82 @@
+package entities
+
+import "fmt"
+
+// SeatMap describes numbered seats of the show's venue.
+// Shows without a seat map have general admission, limited only by the number of tickets.
+type SeatMap struct {
+	Sections []SeatMapSection `json:"sections"`
+}
+
+type SeatMapSection struct {
+	Name string       `json:"name"`
+	Rows []SeatMapRow `json:"rows"`
+}
+
+type SeatMapRow struct {
+	Name  string        `json:"name"`
+	Seats []SeatMapSeat `json:"seats"`
+}
+
+type SeatMapSeat struct {
+	Number     string `json:"number"`
+	Accessible bool   `json:"accessible"`
+}
+
+// Seats returns all seats of the map in the order in which they are allocated when the customer doesn't choose.
+func (m SeatMap) Seats() []Seat {
+	var seats []Seat
+	for _, section := range m.Sections {
+		for _, row := range section.Rows {
+			for _, seat := range row.Seats {
+				seats = append(seats, Seat{
+					Section:    section.Name,
+					Row:        row.Name,
+					Number:     seat.Number,
+					Accessible: seat.Accessible,
+				})
+			}
+		}
+	}
+
+	return seats
+}
+
+// Validate checks that every seat has a section, row and number, and that no seat is listed twice.
+func (m SeatMap) Validate() error {
+	seen := map[Seat]bool{}
+	for _, seat := range m.Seats() {
+		if seat.Section == "" || seat.Row == "" || seat.Number == "" {
+			return fmt.Errorf("seat %s has no section, row or number", seat)
+		}
+
+		seat.Accessible = false
+		if seen[seat] {
+			return fmt.Errorf("seat %s is listed twice", seat)
+		}
+		seen[seat] = true
+	}
+
+	if len(seen) == 0 {
+		return fmt.Errorf("seat map has no seats")
+	}
+
+	return nil
+}
+
+type Seat struct {
+	Section    string `json:"section" db:"seat_section"`
+	Row        string `json:"row" db:"seat_row"`
+	Number     string `json:"number" db:"seat_number"`
+	Accessible bool   `json:"accessible" db:"accessible"`
+}
+
+func (s Seat) String() string {
+	return fmt.Sprintf("section %s, row %s, seat %s", s.Section, s.Row, s.Number)
+}
+
+// ShowSeat is a seat of the show with its availability.
+type ShowSeat struct {
+	Seat
+	Available bool `json:"available" db:"available"`
+}
@@ -13,4 +13,11 @@ type Show struct {
 	StartTime       time.Time `json:"start_time" db:"start_time"`
 	Title           string    `json:"title" db:"title"`
 	Venue           string    `json:"venue" db:"venue"`
+
+	PriceCategories PriceCategories `json:"price_categories,omitempty" db:"price_categories"`
+
+	CanceledAt *time.Time `json:"canceled_at,omitempty" db:"canceled_at"`
+
+	// SeatMap is stored in show seats, it's set only when the show is created
+	SeatMap *SeatMap `json:"seat_map,omitempty" db:"-"`
 }
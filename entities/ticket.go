@@ -4,4 +4,7 @@ type Ticket struct {
 	TicketID      string `json:"ticket_id" db:"ticket_id"`
 	Price         Money  `json:"price" db:"price"`
 	CustomerEmail string `json:"customer_email" db:"customer_email"`
+
+	// BookingID is empty for tickets stored before it was recorded
+	BookingID string `json:"-" db:"booking_id"`
 }
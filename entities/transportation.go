@@ -9,6 +9,14 @@ import (
 var ErrNoFlightTicketsAvailable = errors.New("no flight tickets available")
 var ErrNoTaxiAvailable = errors.New("no taxi available")
 
+// FlightLeg tells which flight of a trip is booked, so both can be booked at once.
+type FlightLeg string
+
+const (
+	FlightLegInbound FlightLeg = "inbound"
+	FlightLegReturn  FlightLeg = "return"
+)
+
 type BookFlightTicketRequest struct {
 	CustomerEmail  string
 	FlightID       uuid.UUID
@@ -25,6 +33,10 @@ type CancelFlightTicketsRequest struct {
 	TicketIds []uuid.UUID `json:"ticket_ids"`
 }
 
+type CancelTaxiBookingRequest struct {
+	TaxiBookingID uuid.UUID `json:"taxi_booking_id"`
+}
+
 type BookTaxiRequest struct {
 	CustomerEmail      string
 	NumberOfPassengers int
@@ -27,9 +27,347 @@ type VipBundle struct {
 	ReturnFlightBookedAt   *time.Time  `json:"return_flight_booked_at"`
 	ReturnFlightTicketsIDs []uuid.UUID `json:"return_flight_tickets_ids"`
 
-	TaxiBookedAt  *time.Time `json:"taxi_booked_at"`
-	TaxiBookingID *uuid.UUID `json:"taxi_booking_id"`
+	// InboundFlightAlternatives and ReturnFlightAlternatives are tried in order when the current flight is full
+	InboundFlightAlternatives []uuid.UUID              `json:"inbound_flight_alternatives,omitempty"`
+	ReturnFlightAlternatives  []uuid.UUID              `json:"return_flight_alternatives,omitempty"`
+	FlightAttempts            []VipBundleFlightAttempt `json:"flight_attempts,omitempty"`
 
-	IsFinalized bool `json:"finalized"`
-	Failed      bool `json:"failed"`
+	// AttemptedInboundFlightID and AttemptedReturnFlightID are set when an alternative flight is being booked,
+	// InboundFlightID and ReturnFlightID keep the flights chosen by the customer
+	AttemptedInboundFlightID *uuid.UUID `json:"attempted_inbound_flight_id,omitempty"`
+	AttemptedReturnFlightID  *uuid.UUID `json:"attempted_return_flight_id,omitempty"`
+
+	// HotelID is set when the customer booked accommodation, it's booked after flights and before the taxi
+	HotelID        *uuid.UUID `json:"hotel_id,omitempty"`
+	Nights         int        `json:"nights,omitempty"`
+	HotelBookedAt  *time.Time `json:"hotel_booked_at,omitempty"`
+	HotelBookingID *uuid.UUID `json:"hotel_booking_id,omitempty"`
+
+	// TaxiBookedAt and TaxiBookingID are set when all taxis are booked, TaxiBookingID is the first of them
+	TaxiBookedAt  *time.Time      `json:"taxi_booked_at"`
+	TaxiBookingID *uuid.UUID      `json:"taxi_booking_id"`
+	Taxis         []VipBundleTaxi `json:"taxis,omitempty"`
+
+	IsFinalized   bool          `json:"finalized"`
+	Failed        bool          `json:"failed"`
+	FailureReason string        `json:"failure_reason,omitempty"`
+	FailedStep    VipBundleStep `json:"failed_step,omitempty"`
+	Canceled      bool          `json:"canceled"`
+
+	Compensations []VipBundleCompensation `json:"compensations"`
+
+	// State and History are the progress of the bundle process
+	State   VipBundleState        `json:"state"`
+	History []VipBundleTransition `json:"history"`
+}
+
+type VipBundleState string
+
+const (
+	VipBundleStateInitialized         VipBundleState = "initialized"
+	VipBundleStateBookingShow         VipBundleState = "booking_show"
+	VipBundleStateShowBooked          VipBundleState = "show_booked"
+	VipBundleStateInboundFlightBooked VipBundleState = "inbound_flight_booked"
+	VipBundleStateReturnFlightBooked  VipBundleState = "return_flight_booked"
+	VipBundleStateFlightsBooked       VipBundleState = "flights_booked"
+	VipBundleStateHotelBooked         VipBundleState = "hotel_booked"
+	VipBundleStateFinalized           VipBundleState = "finalized"
+	VipBundleStateFailed              VipBundleState = "failed"
+	VipBundleStateCanceled            VipBundleState = "canceled"
+)
+
+// VipBundleTransition is an entry of the bundle process history.
+type VipBundleTransition struct {
+	From  VipBundleState `json:"from"`
+	To    VipBundleState `json:"to"`
+	Event string         `json:"event"`
+	At    time.Time      `json:"at"`
+}
+
+// CurrentState returns the state of the bundle process.
+// Bundles stored before the process had states get the state derived from their progress.
+func (v VipBundle) CurrentState() VipBundleState {
+	if v.State != "" {
+		return v.State
+	}
+
+	switch {
+	case v.Canceled:
+		return VipBundleStateCanceled
+	case v.Failed:
+		return VipBundleStateFailed
+	case v.IsFinalized:
+		return VipBundleStateFinalized
+	case v.InboundFlightBookedAt != nil && v.ReturnFlightBookedAt != nil:
+		return VipBundleStateFlightsBooked
+	case v.ReturnFlightBookedAt != nil:
+		return VipBundleStateReturnFlightBooked
+	case v.InboundFlightBookedAt != nil:
+		return VipBundleStateInboundFlightBooked
+	case v.BookingMadeAt != nil:
+		return VipBundleStateShowBooked
+	default:
+		// new bundles are stored as initialized, so the show booking was already requested
+		return VipBundleStateBookingShow
+	}
+}
+
+type VipBundleCompensationAction string
+
+const (
+	VipBundleCompensationRefundTicket VipBundleCompensationAction = "refund_ticket"
+	VipBundleCompensationCancelFlight VipBundleCompensationAction = "cancel_flight"
+	VipBundleCompensationCancelTaxi   VipBundleCompensationAction = "cancel_taxi"
+	VipBundleCompensationCancelHotel  VipBundleCompensationAction = "cancel_hotel"
+)
+
+type VipBundleCompensationStatus string
+
+const (
+	VipBundleCompensationPending VipBundleCompensationStatus = "pending"
+	VipBundleCompensationDone    VipBundleCompensationStatus = "done"
+	VipBundleCompensationFailed  VipBundleCompensationStatus = "failed"
+)
+
+// VipBundleCompensation tracks an action undoing a completed step of a rolled back bundle.
+// ReferenceID is the ID of the compensated ticket or flight.
+type VipBundleCompensation struct {
+	Action      VipBundleCompensationAction `json:"action"`
+	ReferenceID uuid.UUID                   `json:"reference_id"`
+	Status      VipBundleCompensationStatus `json:"status"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+// StartCompensation adds a pending compensation, compensations which were already started are kept.
+func (v *VipBundle) StartCompensation(action VipBundleCompensationAction, referenceID uuid.UUID) {
+	for _, c := range v.Compensations {
+		if c.Action == action && c.ReferenceID == referenceID {
+			return
+		}
+	}
+
+	v.Compensations = append(v.Compensations, VipBundleCompensation{
+		Action:      action,
+		ReferenceID: referenceID,
+		Status:      VipBundleCompensationPending,
+		UpdatedAt:   time.Now().UTC(),
+	})
+}
+
+// SetCompensationStatus updates the status of a started compensation, returns false if it wasn't started.
+func (v *VipBundle) SetCompensationStatus(
+	action VipBundleCompensationAction,
+	referenceID uuid.UUID,
+	status VipBundleCompensationStatus,
+) bool {
+	for i, c := range v.Compensations {
+		if c.Action == action && c.ReferenceID == referenceID {
+			v.Compensations[i].Status = status
+			v.Compensations[i].UpdatedAt = time.Now().UTC()
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasIncompleteRollback returns true if any compensation is not done.
+func (v VipBundle) HasIncompleteRollback() bool {
+	for _, c := range v.Compensations {
+		if c.Status != VipBundleCompensationDone {
+			return true
+		}
+	}
+
+	return false
+}
+
+type VipBundleFlightAttemptStatus string
+
+const (
+	VipBundleFlightAttemptBooking VipBundleFlightAttemptStatus = "booking"
+	VipBundleFlightAttemptBooked  VipBundleFlightAttemptStatus = "booked"
+	VipBundleFlightAttemptFailed  VipBundleFlightAttemptStatus = "failed"
+)
+
+// VipBundleFlightAttempt records booking of one of the flight candidates of a leg.
+type VipBundleFlightAttempt struct {
+	Leg           FlightLeg                    `json:"leg"`
+	FlightID      uuid.UUID                    `json:"flight_id"`
+	Status        VipBundleFlightAttemptStatus `json:"status"`
+	FailureReason string                       `json:"failure_reason,omitempty"`
+	UpdatedAt     time.Time                    `json:"updated_at"`
+}
+
+// SetFlightAttempt adds or updates the attempt to book the flight for the leg.
+func (v *VipBundle) SetFlightAttempt(
+	leg FlightLeg,
+	flightID uuid.UUID,
+	status VipBundleFlightAttemptStatus,
+	failureReason string,
+) {
+	attempt := VipBundleFlightAttempt{
+		Leg:           leg,
+		FlightID:      flightID,
+		Status:        status,
+		FailureReason: failureReason,
+		UpdatedAt:     time.Now().UTC(),
+	}
+
+	for i, a := range v.FlightAttempts {
+		if a.Leg == leg && a.FlightID == flightID {
+			v.FlightAttempts[i] = attempt
+			return
+		}
+	}
+
+	v.FlightAttempts = append(v.FlightAttempts, attempt)
+}
+
+// AttemptedFlightID returns the flight being booked or booked for the leg.
+// It's the flight chosen by the customer until an alternative is tried.
+func (v VipBundle) AttemptedFlightID(leg FlightLeg) uuid.UUID {
+	if leg == FlightLegReturn {
+		if v.AttemptedReturnFlightID != nil {
+			return *v.AttemptedReturnFlightID
+		}
+
+		return v.ReturnFlightID
+	}
+
+	if v.AttemptedInboundFlightID != nil {
+		return *v.AttemptedInboundFlightID
+	}
+
+	return v.InboundFlightID
+}
+
+// SetAttemptedFlightID records the alternative flight which is being booked for the leg.
+func (v *VipBundle) SetAttemptedFlightID(leg FlightLeg, flightID uuid.UUID) {
+	if leg == FlightLegReturn {
+		v.AttemptedReturnFlightID = &flightID
+		return
+	}
+
+	v.AttemptedInboundFlightID = &flightID
+}
+
+// NextFlightAlternative returns the first alternative flight of the leg which wasn't attempted yet.
+func (v VipBundle) NextFlightAlternative(leg FlightLeg) (uuid.UUID, bool) {
+	alternatives := v.InboundFlightAlternatives
+	if leg == FlightLegReturn {
+		alternatives = v.ReturnFlightAlternatives
+	}
+
+	for _, flightID := range alternatives {
+		attempted := false
+		for _, a := range v.FlightAttempts {
+			if a.Leg == leg && a.FlightID == flightID {
+				attempted = true
+				break
+			}
+		}
+
+		if !attempted {
+			return flightID, true
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+// HasHotel returns true if the bundle includes accommodation.
+func (v VipBundle) HasHotel() bool {
+	return v.HotelID != nil
+}
+
+// VipBundleTaxi is one of the taxis allocated for passengers of the bundle.
+type VipBundleTaxi struct {
+	Index              int        `json:"index"`
+	CustomerName       string     `json:"customer_name"`
+	NumberOfPassengers int        `json:"number_of_passengers"`
+	TaxiBookingID      *uuid.UUID `json:"taxi_booking_id"`
+	BookedAt           *time.Time `json:"booked_at"`
+}
+
+// SetTaxiBooked records the booking of the allocated taxi.
+// Bundles which booked a single taxi before allocation have no taxis, so the taxi is added.
+func (v *VipBundle) SetTaxiBooked(index int, taxiBookingID uuid.UUID, bookedAt time.Time) {
+	for i, t := range v.Taxis {
+		if t.Index == index {
+			v.Taxis[i].TaxiBookingID = &taxiBookingID
+			v.Taxis[i].BookedAt = &bookedAt
+			return
+		}
+	}
+
+	v.Taxis = append(v.Taxis, VipBundleTaxi{
+		Index:         index,
+		TaxiBookingID: &taxiBookingID,
+		BookedAt:      &bookedAt,
+	})
+}
+
+// AllTaxisBooked returns true if every allocated taxi is booked.
+func (v VipBundle) AllTaxisBooked() bool {
+	if len(v.Taxis) == 0 {
+		return false
+	}
+
+	for _, t := range v.Taxis {
+		if t.TaxiBookingID == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// BookedTaxiIDs returns booking IDs of all booked taxis.
+func (v VipBundle) BookedTaxiIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, t := range v.Taxis {
+		if t.TaxiBookingID != nil {
+			ids = append(ids, *t.TaxiBookingID)
+		}
+	}
+
+	if len(ids) == 0 && v.TaxiBookingID != nil {
+		ids = append(ids, *v.TaxiBookingID)
+	}
+
+	return ids
+}
+
+type VipBundleStep string
+
+const (
+	VipBundleStepShowBooking   VipBundleStep = "show_booking"
+	VipBundleStepInboundFlight VipBundleStep = "inbound_flight"
+	VipBundleStepReturnFlight  VipBundleStep = "return_flight"
+	VipBundleStepHotel         VipBundleStep = "hotel"
+	VipBundleStepTaxi          VipBundleStep = "taxi"
+
+	// VipBundleStepCompensation is the rollback of a failed or canceled bundle
+	VipBundleStepCompensation VipBundleStep = "compensation"
+)
+
+// IsStepCompleted returns true if the step doesn't wait for any response anymore.
+func (v VipBundle) IsStepCompleted(step VipBundleStep) bool {
+	switch step {
+	case VipBundleStepShowBooking:
+		return v.BookingMadeAt != nil && len(v.TicketIDs) >= v.NumberOfTickets
+	case VipBundleStepInboundFlight:
+		return v.InboundFlightBookedAt != nil
+	case VipBundleStepReturnFlight:
+		return v.ReturnFlightBookedAt != nil
+	case VipBundleStepHotel:
+		return v.HotelBookedAt != nil || !v.HasHotel()
+	case VipBundleStepTaxi:
+		return v.TaxiBookedAt != nil
+	case VipBundleStepCompensation:
+		return !v.HasIncompleteRollback()
+	default:
+		return false
+	}
 }
@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WaitlistEntryStatus string
+
+const (
+	// WaitlistEntryWaiting entries are offered freed seats in the order in which customers joined the waitlist
+	WaitlistEntryWaiting WaitlistEntryStatus = "waiting"
+	// WaitlistEntryOffered entries hold seats until the offer expires, other customers can't book them
+	WaitlistEntryOffered WaitlistEntryStatus = "offered"
+	WaitlistEntryClaimed WaitlistEntryStatus = "claimed"
+	WaitlistEntryExpired WaitlistEntryStatus = "expired"
+)
+
+type WaitlistEntry struct {
+	EntryID         uuid.UUID `json:"entry_id" db:"entry_id"`
+	ShowID          uuid.UUID `json:"show_id" db:"show_id"`
+	CustomerEmail   string    `json:"customer_email" db:"customer_email"`
+	NumberOfTickets int       `json:"number_of_tickets" db:"number_of_tickets"`
+	PriceCategory   string    `json:"price_category,omitempty" db:"price_category"`
+
+	Status    WaitlistEntryStatus `json:"status" db:"status"`
+	CreatedAt time.Time           `json:"created_at" db:"created_at"`
+
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty" db:"offer_expires_at"`
+
+	// BookingID is the booking made when the offer was claimed
+	BookingID *uuid.UUID `json:"booking_id,omitempty" db:"booking_id"`
+}
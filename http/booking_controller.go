@@ -8,27 +8,47 @@ import (
 	"tickets/entities"
 	"tickets/message/contracts"
 
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 )
 
 type bookTicketRequest struct {
 	ShowID          uuid.UUID `json:"show_id"`
 	NumberOfTickets int       `json:"number_of_tickets"`
 	CustomerEmail   string    `json:"customer_email"`
+
+	// Seats can be chosen for shows with a seat map, number_of_tickets can be omitted then
+	Seats []bookTicketSeat `json:"seats"`
+
+	// PriceCategory is one of the show's categories, the first one is used when it's empty
+	PriceCategory string `json:"price_category"`
+}
+
+type bookTicketSeat struct {
+	Section string `json:"section"`
+	Row     string `json:"row"`
+	Number  string `json:"number"`
 }
 
 type bookTicketResponse struct {
-	BookingId uuid.UUID   `json:"booking_id"`
-	TicketIds []uuid.UUID `json:"ticket_ids"`
+	BookingId uuid.UUID       `json:"booking_id"`
+	TicketIds []uuid.UUID     `json:"ticket_ids"`
+	Seats     []entities.Seat `json:"seats,omitempty"`
+
+	PriceCategory string          `json:"price_category,omitempty"`
+	TicketPrice   *entities.Money `json:"ticket_price,omitempty"`
+	TotalPrice    *entities.Money `json:"total_price,omitempty"`
 }
 
 type BookingController struct {
-	repo contracts.BookingRepository
+	repo       contracts.BookingRepository
+	commandBus *cqrs.CommandBus
 }
 
-func NewBookingController(repo contracts.BookingRepository) BookingController {
-	return BookingController{repo: repo}
+func NewBookingController(repo contracts.BookingRepository, commandBus *cqrs.CommandBus) BookingController {
+	return BookingController{repo: repo, commandBus: commandBus}
 }
 
 func (ctrl BookingController) Store(c echo.Context) error {
@@ -39,6 +59,19 @@ func (ctrl BookingController) Store(c echo.Context) error {
 
 	bookingID := uuid.New()
 
+	seats := lo.Map(request.Seats, func(s bookTicketSeat, _ int) entities.Seat {
+		return entities.Seat{Section: s.Section, Row: s.Row, Number: s.Number}
+	})
+	if len(lo.Uniq(seats)) != len(seats) {
+		return echo.NewHTTPError(http.StatusBadRequest, "seats must be unique")
+	}
+	if len(seats) > 0 && request.NumberOfTickets == 0 {
+		request.NumberOfTickets = len(seats)
+	}
+	if len(seats) > 0 && request.NumberOfTickets != len(seats) {
+		return echo.NewHTTPError(http.StatusBadRequest, "number of tickets must match the number of seats")
+	}
+
 	if request.NumberOfTickets < 1 {
 		return echo.NewHTTPError(http.StatusBadRequest, "number of tickets must be greater than 0")
 	}
@@ -48,15 +81,70 @@ func (ctrl BookingController) Store(c echo.Context) error {
 		ShowID:          request.ShowID,
 		NumberOfTickets: request.NumberOfTickets,
 		CustomerEmail:   request.CustomerEmail,
+		Seats:           seats,
+		PriceCategory:   request.PriceCategory,
 	})
 	if errors.Is(err, db.ErrNoPlacesLeft) {
-		return echo.NewHTTPError(http.StatusBadRequest, "not enough seats available")
+		return echo.NewHTTPError(http.StatusBadRequest, "not enough seats available, join the waitlist with POST /shows/"+request.ShowID.String()+"/waitlist")
+	}
+	if errors.Is(err, db.ErrSeatsNotAvailable) {
+		return echo.NewHTTPError(http.StatusConflict, "chosen seats are not available")
+	}
+	if errors.Is(err, db.ErrShowHasNoSeatMap) {
+		return echo.NewHTTPError(http.StatusBadRequest, "show has no assigned seating")
+	}
+	if errors.Is(err, db.ErrShowCanceled) {
+		return echo.NewHTTPError(http.StatusConflict, "show is canceled")
+	}
+	if errors.Is(err, db.ErrUnknownPriceCategory) {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown price category")
 	}
 	if err != nil {
 		return fmt.Errorf("failed to store booking: %w", err)
 	}
 
-	return c.JSON(http.StatusCreated, bookTicketResponse{
-		BookingId: bookingID,
+	booking, err := ctrl.repo.FindByID(c.Request().Context(), bookingID)
+	if err != nil {
+		return fmt.Errorf("failed to find stored booking: %w", err)
+	}
+
+	response := bookTicketResponse{
+		BookingId:     bookingID,
+		Seats:         booking.Seats,
+		PriceCategory: booking.PriceCategory,
+	}
+
+	if booking.PriceCategory != "" {
+		totalPrice := booking.TicketPrice.Multiply(booking.NumberOfTickets)
+
+		response.TicketPrice = &booking.TicketPrice
+		response.TotalPrice = &totalPrice
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+// Cancel releases seats of the booking and refunds its tickets.
+func (ctrl BookingController) Cancel(c echo.Context) error {
+	bookingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid booking id")
+	}
+
+	_, err = ctrl.repo.FindByID(c.Request().Context(), bookingID)
+	if errors.Is(err, db.ErrBookingNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "booking not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find booking: %w", err)
+	}
+
+	err = ctrl.commandBus.Send(c.Request().Context(), entities.CancelBooking{
+		BookingID: bookingID,
 	})
+	if err != nil {
+		return fmt.Errorf("failed to send CancelBooking command: %w", err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
 }
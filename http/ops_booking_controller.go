@@ -4,17 +4,32 @@ import (
 	"fmt"
 	"net/http"
 	"tickets/db/read_model"
+	"tickets/entities"
+	"tickets/message/contracts"
 	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
 type OpsBookingController struct {
-	opsReadModel read_model.OpsBookingReadModel
+	opsReadModel  read_model.OpsBookingReadModel
+	exchangeRates contracts.ExchangeRates
 }
 
-func NewOpsBookingController(opsReadModel read_model.OpsBookingReadModel) OpsBookingController {
-	return OpsBookingController{opsReadModel: opsReadModel}
+func NewOpsBookingController(
+	opsReadModel read_model.OpsBookingReadModel,
+	exchangeRates contracts.ExchangeRates,
+) OpsBookingController {
+	return OpsBookingController{
+		opsReadModel:  opsReadModel,
+		exchangeRates: exchangeRates,
+	}
+}
+
+type opsRevenueResponse struct {
+	BaseCurrency string           `json:"base_currency"`
+	Total        entities.Money   `json:"total"`
+	ByCurrency   []entities.Money `json:"by_currency"`
 }
 
 func (ctrl OpsBookingController) FindAll(c echo.Context) error {
@@ -42,3 +57,36 @@ func (ctrl OpsBookingController) FindByID(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, reservation)
 }
+
+// Revenue sums prices of confirmed tickets which weren't refunded, converted to the base currency.
+func (ctrl OpsBookingController) Revenue(c echo.Context) error {
+	baseCurrency := c.QueryParam("base_currency")
+	if _, ok := entities.CurrencyMinorUnits(baseCurrency); !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "base_currency must be an ISO 4217 currency code")
+	}
+
+	byCurrency, err := ctrl.opsReadModel.RevenueByCurrency(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to sum revenue: %w", err)
+	}
+
+	response := opsRevenueResponse{
+		BaseCurrency: baseCurrency,
+		Total:        entities.Money{Amount: entities.NewDecimal(0, 0), Currency: baseCurrency}.Round(),
+	}
+
+	for _, revenue := range byCurrency {
+		rate, err := ctrl.exchangeRates.ExchangeRate(c.Request().Context(), revenue.Currency, baseCurrency)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("can't convert revenue to %s: %s", baseCurrency, err))
+		}
+
+		response.Total, err = response.Total.Add(revenue.Convert(baseCurrency, rate))
+		if err != nil {
+			return fmt.Errorf("failed to sum revenue: %w", err)
+		}
+		response.ByCurrency = append(response.ByCurrency, revenue)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
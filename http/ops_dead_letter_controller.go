@@ -0,0 +1,102 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tickets/db"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type OpsDeadLetterController struct {
+	repo      contracts.DeadLetterRepository
+	publisher message.Publisher
+}
+
+func NewOpsDeadLetterController(repo contracts.DeadLetterRepository, publisher message.Publisher) OpsDeadLetterController {
+	return OpsDeadLetterController{
+		repo:      repo,
+		publisher: publisher,
+	}
+}
+
+func (ctrl OpsDeadLetterController) FindAll(c echo.Context) error {
+	deadLetters, err := ctrl.repo.FindAll(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to find dead letters: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, deadLetters)
+}
+
+func (ctrl OpsDeadLetterController) FindByID(c echo.Context) error {
+	deadLetterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid dead letter id")
+	}
+
+	deadLetter, err := ctrl.repo.FindByID(c.Request().Context(), deadLetterID)
+	if errors.Is(err, db.ErrDeadLetterNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find dead letter: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, deadLetter)
+}
+
+func (ctrl OpsDeadLetterController) Replay(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	deadLetterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid dead letter id")
+	}
+
+	deadLetter, err := ctrl.repo.FindByID(ctx, deadLetterID)
+	if errors.Is(err, db.ErrDeadLetterNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find dead letter: %w", err)
+	}
+
+	msg := message.NewMessage(deadLetter.MessageUUID, deadLetter.Payload)
+	for k, v := range deadLetter.Metadata {
+		msg.Metadata.Set(k, v)
+	}
+	msg.SetContext(ctx)
+
+	if err := ctrl.publisher.Publish(deadLetter.Topic, msg); err != nil {
+		return fmt.Errorf("failed to replay dead letter to %s: %w", deadLetter.Topic, err)
+	}
+
+	// if removing fails, the message may be replayed again - handlers are taking into account at-least-once delivery
+	if err := ctrl.repo.Remove(ctx, deadLetterID); err != nil {
+		return fmt.Errorf("failed to remove replayed dead letter: %w", err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (ctrl OpsDeadLetterController) Discard(c echo.Context) error {
+	deadLetterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid dead letter id")
+	}
+
+	err = ctrl.repo.Remove(c.Request().Context(), deadLetterID)
+	if errors.Is(err, db.ErrDeadLetterNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to discard dead letter: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
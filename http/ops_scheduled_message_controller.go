@@ -0,0 +1,53 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tickets/db"
+	"tickets/message/contracts"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type OpsScheduledMessageController struct {
+	repo      contracts.ScheduledMessageRepository
+	scheduler contracts.Scheduler
+}
+
+func NewOpsScheduledMessageController(
+	repo contracts.ScheduledMessageRepository,
+	scheduler contracts.Scheduler,
+) OpsScheduledMessageController {
+	return OpsScheduledMessageController{
+		repo:      repo,
+		scheduler: scheduler,
+	}
+}
+
+func (ctrl OpsScheduledMessageController) FindAll(c echo.Context) error {
+	scheduledMessages, err := ctrl.repo.FindAll(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to find scheduled messages: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, scheduledMessages)
+}
+
+func (ctrl OpsScheduledMessageController) Cancel(c echo.Context) error {
+	scheduledMessageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid scheduled message id")
+	}
+
+	err = ctrl.scheduler.Cancel(c.Request().Context(), scheduledMessageID)
+	if errors.Is(err, db.ErrScheduledMessageNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "scheduled message not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled message: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
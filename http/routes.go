@@ -9,6 +9,7 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/labstack/echo/v4"
 )
 
@@ -19,14 +20,24 @@ func NewHttpRouter(
 	ticketRepo contracts.TicketRepository,
 	showRepo contracts.ShowRepository,
 	bookingRepo contracts.BookingRepository,
+	waitlistRepo contracts.WaitlistRepository,
 	vipBundleRepo contracts.VipBundleRepository,
 	opsReadModel read_model.OpsBookingReadModel,
+	deadLetterRepo contracts.DeadLetterRepository,
+	publisher message.Publisher,
+	scheduledMessageRepo contracts.ScheduledMessageRepository,
+	scheduler contracts.Scheduler,
+	exchangeRates contracts.ExchangeRates,
+	hotelBookingEnabled bool,
 ) *echo.Echo {
 	ticketCtrl := NewTicketController(eventBus, commandBus, ticketRepo)
 	showCtrl := NewShowController(showRepo)
-	bookingCtrl := NewBookingController(bookingRepo)
-	vipBundleCtrl := NewVipBundleController(vipBundleRepo)
-	opsBookingCtrl := NewOpsBookingController(opsReadModel)
+	bookingCtrl := NewBookingController(bookingRepo, commandBus)
+	waitlistCtrl := NewWaitlistController(waitlistRepo)
+	vipBundleCtrl := NewVipBundleController(vipBundleRepo, showRepo, eventBus, hotelBookingEnabled)
+	opsBookingCtrl := NewOpsBookingController(opsReadModel, exchangeRates)
+	opsDeadLetterCtrl := NewOpsDeadLetterController(deadLetterRepo, publisher)
+	opsScheduledMessageCtrl := NewOpsScheduledMessageController(scheduledMessageRepo, scheduler)
 
 	e := libHttp.NewEcho()
 
@@ -38,15 +49,37 @@ func NewHttpRouter(
 	e.GET("/tickets", ticketCtrl.FindAll)
 	e.POST("/tickets-status", ticketCtrl.Status)
 	e.POST("/book-tickets", bookingCtrl.Store)
+	e.DELETE("/bookings/:id", bookingCtrl.Cancel)
 	e.PUT("/ticket-refund/:ticket_id", ticketCtrl.Refund)
 
 	e.POST("/book-vip-bundle", vipBundleCtrl.Book)
+	e.GET("/vip-bundles", vipBundleCtrl.FindByCustomerEmail)
+	e.GET("/vip-bundles/:id", vipBundleCtrl.FindByID)
+	e.POST("/vip-bundles/:id/cancel", vipBundleCtrl.Cancel)
 
 	e.GET("/shows", showCtrl.FindAll)
 	e.POST("/shows", showCtrl.Store)
+	e.PUT("/shows/:id", showCtrl.Update)
+	e.POST("/shows/:id/cancel", showCtrl.Cancel)
+	e.GET("/shows/:id/seats", showCtrl.FindSeats)
+	e.POST("/shows/:id/waitlist", waitlistCtrl.Join)
+
+	e.GET("/waitlist/:id", waitlistCtrl.FindByID)
+	e.POST("/waitlist/:id/claim", waitlistCtrl.Claim)
 
 	e.GET("/ops/bookings", opsBookingCtrl.FindAll)
 	e.GET("/ops/bookings/:id", opsBookingCtrl.FindByID)
+	e.GET("/ops/revenue", opsBookingCtrl.Revenue)
+
+	e.GET("/ops/dead-letters", opsDeadLetterCtrl.FindAll)
+	e.GET("/ops/dead-letters/:id", opsDeadLetterCtrl.FindByID)
+	e.POST("/ops/dead-letters/:id/replay", opsDeadLetterCtrl.Replay)
+	e.DELETE("/ops/dead-letters/:id", opsDeadLetterCtrl.Discard)
+
+	e.GET("/ops/vip-bundles/incomplete-rollbacks", vipBundleCtrl.FindWithIncompleteRollback)
+
+	e.GET("/ops/scheduled-messages", opsScheduledMessageCtrl.FindAll)
+	e.DELETE("/ops/scheduled-messages/:id", opsScheduledMessageCtrl.Cancel)
 
 	return e
 }
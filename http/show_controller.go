@@ -1,8 +1,10 @@
 package http
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"tickets/db"
 	"tickets/entities"
 	"tickets/message/contracts"
 	"time"
@@ -17,6 +19,20 @@ type showCreateRequest struct {
 	StartTime       time.Time `json:"start_time"`
 	Title           string    `json:"title"`
 	Venue           string    `json:"venue"`
+
+	// SeatMap is optional, number_of_tickets is the number of its seats then
+	SeatMap *entities.SeatMap `json:"seat_map"`
+
+	// PriceCategories are optional, the first one is the default
+	PriceCategories entities.PriceCategories `json:"price_categories"`
+}
+
+// showUpdateRequest changes only the fields which are set.
+type showUpdateRequest struct {
+	NumberOfTickets *int       `json:"number_of_tickets"`
+	StartTime       *time.Time `json:"start_time"`
+	Title           *string    `json:"title"`
+	Venue           *string    `json:"venue"`
 }
 
 type ShowController struct {
@@ -42,6 +58,22 @@ func (ctrl ShowController) Store(c echo.Context) error {
 		return err
 	}
 
+	if request.SeatMap != nil {
+		if err := request.SeatMap.Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid seat map: "+err.Error())
+		}
+
+		seats := len(request.SeatMap.Seats())
+		if request.NumberOfTickets != 0 && request.NumberOfTickets != seats {
+			return echo.NewHTTPError(http.StatusBadRequest, "number of tickets must match the number of seats")
+		}
+		request.NumberOfTickets = seats
+	}
+
+	if err := request.PriceCategories.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid price categories: "+err.Error())
+	}
+
 	show := entities.Show{
 		ShowID:          uuid.New(),
 		DeadNationID:    request.DeadNationID,
@@ -49,6 +81,8 @@ func (ctrl ShowController) Store(c echo.Context) error {
 		StartTime:       request.StartTime,
 		Title:           request.Title,
 		Venue:           request.Venue,
+		SeatMap:         request.SeatMap,
+		PriceCategories: request.PriceCategories,
 	}
 
 	if err := ctrl.repo.Add(c.Request().Context(), show); err != nil {
@@ -57,3 +91,91 @@ func (ctrl ShowController) Store(c echo.Context) error {
 
 	return c.JSON(http.StatusCreated, show)
 }
+
+func (ctrl ShowController) FindSeats(c echo.Context) error {
+	showID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid show id")
+	}
+
+	seats, err := ctrl.repo.FindSeats(c.Request().Context(), showID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch show seats: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, seats)
+}
+
+func (ctrl ShowController) Update(c echo.Context) error {
+	showID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid show id")
+	}
+
+	var request showUpdateRequest
+	if err := c.Bind(&request); err != nil {
+		return err
+	}
+
+	if request.NumberOfTickets != nil && *request.NumberOfTickets < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "number of tickets must be greater than 0")
+	}
+	if request.StartTime != nil && request.StartTime.IsZero() {
+		return echo.NewHTTPError(http.StatusBadRequest, "start time is required")
+	}
+	if request.Title != nil && *request.Title == "" || request.Venue != nil && *request.Venue == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "title and venue can't be empty")
+	}
+
+	show, err := ctrl.repo.UpdateByID(c.Request().Context(), showID, func(show entities.Show) (entities.Show, error) {
+		if request.NumberOfTickets != nil {
+			show.NumberOfTickets = *request.NumberOfTickets
+		}
+		if request.StartTime != nil {
+			show.StartTime = *request.StartTime
+		}
+		if request.Title != nil {
+			show.Title = *request.Title
+		}
+		if request.Venue != nil {
+			show.Venue = *request.Venue
+		}
+
+		return show, nil
+	})
+	if errors.Is(err, db.ErrShowNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "show not found")
+	}
+	if errors.Is(err, db.ErrShowCanceled) {
+		return echo.NewHTTPError(http.StatusConflict, "show is canceled")
+	}
+	if errors.Is(err, db.ErrCapacityBelowSoldSeats) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	if errors.Is(err, db.ErrCapacityFixedBySeatMap) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update show: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, show)
+}
+
+// Cancel cancels the show, its bookings are canceled and refunded asynchronously.
+func (ctrl ShowController) Cancel(c echo.Context) error {
+	showID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid show id")
+	}
+
+	err = ctrl.repo.Cancel(c.Request().Context(), showID)
+	if errors.Is(err, db.ErrShowNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "show not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel show: %w", err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
@@ -77,6 +77,14 @@ func (ctrl TicketController) Status(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Idempotency-Key header is required")
 	}
 
+	// tickets are validated before any of them is published, so the request is rejected as a whole
+	for i, ticket := range request.Tickets {
+		request.Tickets[i].Price = ticket.Price.Normalized()
+		if err := request.Tickets[i].Price.Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid price of ticket %s: %s", ticket.TicketID, err))
+		}
+	}
+
 	for _, ticket := range request.Tickets {
 		if ticket.Status == "confirmed" {
 			event := entities.TicketBookingConfirmed_v1{
@@ -1,12 +1,18 @@
 package http
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"tickets/db"
 	"tickets/entities"
 	"tickets/message/contracts"
+	"time"
 
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
 )
 
 type vipBundleRequest struct {
@@ -16,6 +22,14 @@ type vipBundleRequest struct {
 	Passengers      []string  `json:"passengers"`
 	ReturnFlightId  uuid.UUID `json:"return_flight_id"`
 	ShowId          uuid.UUID `json:"show_id"`
+
+	// alternative flights are booked in the given order when the preferred flight is full
+	InboundFlightAlternatives []uuid.UUID `json:"inbound_flight_alternatives"`
+	ReturnFlightAlternatives  []uuid.UUID `json:"return_flight_alternatives"`
+
+	// hotel is optional, it's booked after flights
+	HotelId *uuid.UUID `json:"hotel_id"`
+	Nights  int        `json:"nights"`
 }
 
 type vipBundleResponse struct {
@@ -23,12 +37,114 @@ type vipBundleResponse struct {
 	VipBundleId uuid.UUID `json:"vip_bundle_id"`
 }
 
+type vipBundleStatusResponse struct {
+	VipBundleID   uuid.UUID               `json:"vip_bundle_id"`
+	BookingID     uuid.UUID               `json:"booking_id"`
+	CustomerEmail string                  `json:"customer_email"`
+	State         entities.VipBundleState `json:"state"`
+
+	ShowBooked          bool `json:"show_booked"`
+	InboundFlightBooked bool `json:"inbound_flight_booked"`
+	ReturnFlightBooked  bool `json:"return_flight_booked"`
+	HotelBooked         bool `json:"hotel_booked"`
+	TaxiBooked          bool `json:"taxi_booked"`
+	Finalized           bool `json:"finalized"`
+	Failed              bool `json:"failed"`
+	Canceled            bool `json:"canceled"`
+
+	TicketIDs               []uuid.UUID `json:"ticket_ids"`
+	InboundFlightTicketsIDs []uuid.UUID `json:"inbound_flight_tickets_ids"`
+	ReturnFlightTicketsIDs  []uuid.UUID `json:"return_flight_tickets_ids"`
+	TaxiBookingID           *uuid.UUID  `json:"taxi_booking_id"`
+	HotelBookingID          *uuid.UUID  `json:"hotel_booking_id"`
+
+	HotelID *uuid.UUID `json:"hotel_id"`
+	Nights  int        `json:"nights"`
+
+	InboundFlightID uuid.UUID                         `json:"inbound_flight_id"`
+	ReturnFlightID  uuid.UUID                         `json:"return_flight_id"`
+	FlightAttempts  []entities.VipBundleFlightAttempt `json:"flight_attempts"`
+	Taxis           []entities.VipBundleTaxi          `json:"taxis"`
+
+	FailureReason string                           `json:"failure_reason,omitempty"`
+	FailedStep    entities.VipBundleStep           `json:"failed_step,omitempty"`
+	Compensations []entities.VipBundleCompensation `json:"compensations"`
+	Steps         []entities.VipBundleTransition   `json:"steps"`
+}
+
+func newVipBundleStatusResponse(vb entities.VipBundle) vipBundleStatusResponse {
+	steps := vb.History
+	if steps == nil {
+		steps = []entities.VipBundleTransition{}
+	}
+
+	compensations := vb.Compensations
+	if compensations == nil {
+		compensations = []entities.VipBundleCompensation{}
+	}
+
+	flightAttempts := vb.FlightAttempts
+	if flightAttempts == nil {
+		flightAttempts = []entities.VipBundleFlightAttempt{}
+	}
+
+	taxis := vb.Taxis
+	if taxis == nil {
+		taxis = []entities.VipBundleTaxi{}
+	}
+
+	return vipBundleStatusResponse{
+		VipBundleID:             vb.VipBundleID,
+		BookingID:               vb.BookingID,
+		CustomerEmail:           vb.CustomerEmail,
+		State:                   vb.CurrentState(),
+		ShowBooked:              vb.BookingMadeAt != nil,
+		InboundFlightBooked:     vb.InboundFlightBookedAt != nil,
+		ReturnFlightBooked:      vb.ReturnFlightBookedAt != nil,
+		HotelBooked:             vb.HotelBookedAt != nil,
+		TaxiBooked:              vb.TaxiBookedAt != nil,
+		Finalized:               vb.IsFinalized,
+		Failed:                  vb.Failed,
+		Canceled:                vb.Canceled,
+		TicketIDs:               vb.TicketIDs,
+		InboundFlightTicketsIDs: vb.InboundFlightTicketsIDs,
+		ReturnFlightTicketsIDs:  vb.ReturnFlightTicketsIDs,
+		TaxiBookingID:           vb.TaxiBookingID,
+		HotelBookingID:          vb.HotelBookingID,
+		HotelID:                 vb.HotelID,
+		Nights:                  vb.Nights,
+		InboundFlightID:         vb.InboundFlightID,
+		ReturnFlightID:          vb.ReturnFlightID,
+		FlightAttempts:          flightAttempts,
+		Taxis:                   taxis,
+		FailureReason:           vb.FailureReason,
+		FailedStep:              vb.FailedStep,
+		Compensations:           compensations,
+		Steps:                   steps,
+	}
+}
+
 type VipBundleController struct {
-	repo contracts.VipBundleRepository
+	repo     contracts.VipBundleRepository
+	showRepo contracts.ShowRepository
+	eventBus *cqrs.EventBus
+
+	// hotelBookingEnabled is false when no accommodation service is configured
+	hotelBookingEnabled bool
 }
 
-func NewVipBundleController(repo contracts.VipBundleRepository) VipBundleController {
-	return VipBundleController{repo: repo}
+func NewVipBundleController(
+	repo contracts.VipBundleRepository,
+	showRepo contracts.ShowRepository,
+	eventBus *cqrs.EventBus,
+	hotelBookingEnabled bool,
+) VipBundleController {
+	return VipBundleController{
+		repo:                repo,
+		showRepo:            showRepo,
+		eventBus:            eventBus,
+		hotelBookingEnabled: hotelBookingEnabled,
+	}
 }
 
 func (ctrl VipBundleController) Book(c echo.Context) error {
@@ -41,18 +157,29 @@ func (ctrl VipBundleController) Book(c echo.Context) error {
 	if request.NumberOfTickets < 1 {
 		return echo.NewHTTPError(http.StatusBadRequest, "number of tickets must be greater than 0")
 	}
+	if request.HotelId != nil && !ctrl.hotelBookingEnabled {
+		return echo.NewHTTPError(http.StatusNotImplemented, entities.ErrHotelBookingUnavailable.Error())
+	}
+	if request.HotelId != nil && request.Nights < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "number of nights must be greater than 0")
+	}
 
 	vb := entities.VipBundle{
-		VipBundleID:     uuid.New(),
-		BookingID:       uuid.New(),
-		CustomerEmail:   request.CustomerEmail,
-		NumberOfTickets: request.NumberOfTickets,
-		ShowId:          request.ShowId,
-		Passengers:      request.Passengers,
-		InboundFlightID: request.InboundFlightId,
-		ReturnFlightID:  request.ReturnFlightId,
-		IsFinalized:     false,
-		Failed:          false,
+		VipBundleID:               uuid.New(),
+		BookingID:                 uuid.New(),
+		CustomerEmail:             request.CustomerEmail,
+		NumberOfTickets:           request.NumberOfTickets,
+		ShowId:                    request.ShowId,
+		Passengers:                request.Passengers,
+		InboundFlightID:           request.InboundFlightId,
+		ReturnFlightID:            request.ReturnFlightId,
+		InboundFlightAlternatives: flightAlternatives(request.InboundFlightId, request.InboundFlightAlternatives),
+		ReturnFlightAlternatives:  flightAlternatives(request.ReturnFlightId, request.ReturnFlightAlternatives),
+		HotelID:                   request.HotelId,
+		Nights:                    request.Nights,
+		IsFinalized:               false,
+		Failed:                    false,
+		State:                     entities.VipBundleStateInitialized,
 	}
 
 	if err := ctrl.repo.Add(c.Request().Context(), vb); err != nil {
@@ -64,3 +191,103 @@ func (ctrl VipBundleController) Book(c echo.Context) error {
 		BookingId:   vb.BookingID,
 	})
 }
+
+// flightAlternatives drops repeated flights, so each flight is attempted once.
+func flightAlternatives(preferred uuid.UUID, alternatives []uuid.UUID) []uuid.UUID {
+	return lo.Without(lo.Uniq(alternatives), preferred)
+}
+
+func (ctrl VipBundleController) FindByID(c echo.Context) error {
+	vipBundleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid vip bundle id")
+	}
+
+	vb, err := ctrl.repo.Get(c.Request().Context(), vipBundleID)
+	if errors.Is(err, db.ErrVipBundleNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "vip bundle not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find vip bundle: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, newVipBundleStatusResponse(vb))
+}
+
+func (ctrl VipBundleController) FindByCustomerEmail(c echo.Context) error {
+	customerEmail := c.QueryParam("customer_email")
+	if customerEmail == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "customer_email is required")
+	}
+
+	vipBundles, err := ctrl.repo.FindByCustomerEmail(c.Request().Context(), customerEmail)
+	if err != nil {
+		return fmt.Errorf("failed to find vip bundles: %w", err)
+	}
+
+	response := make([]vipBundleStatusResponse, 0, len(vipBundles))
+	for _, vb := range vipBundles {
+		response = append(response, newVipBundleStatusResponse(vb))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// FindWithIncompleteRollback lists rolled back bundles with compensations which were not confirmed yet,
+// failed compensations have to be resolved manually.
+func (ctrl VipBundleController) FindWithIncompleteRollback(c echo.Context) error {
+	vipBundles, err := ctrl.repo.FindWithIncompleteRollback(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to find vip bundles: %w", err)
+	}
+
+	response := make([]vipBundleStatusResponse, 0, len(vipBundles))
+	for _, vb := range vipBundles {
+		response = append(response, newVipBundleStatusResponse(vb))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Cancel requests cancellation of the bundle, which is possible only before the show starts.
+// Completed steps are compensated by the process manager.
+func (ctrl VipBundleController) Cancel(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	vipBundleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid vip bundle id")
+	}
+
+	vb, err := ctrl.repo.Get(ctx, vipBundleID)
+	if errors.Is(err, db.ErrVipBundleNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "vip bundle not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find vip bundle: %w", err)
+	}
+
+	if vb.Failed || vb.Canceled {
+		return echo.NewHTTPError(http.StatusConflict, "vip bundle is already rolled back")
+	}
+
+	show, err := ctrl.showRepo.FindByID(ctx, vb.ShowId)
+	if err != nil {
+		return fmt.Errorf("failed to find show: %w", err)
+	}
+
+	if !time.Now().Before(show.StartTime) {
+		return echo.NewHTTPError(http.StatusConflict, "vip bundle can be canceled only before the show starts")
+	}
+
+	err = ctrl.eventBus.Publish(ctx, entities.VipBundleCancellationRequested_v1{
+		Header:      entities.NewEventHeader(),
+		VipBundleID: vb.VipBundleID,
+		BookingID:   vb.BookingID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish cancellation request: %w", err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tickets/db"
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type waitlistJoinRequest struct {
+	CustomerEmail   string `json:"customer_email"`
+	NumberOfTickets int    `json:"number_of_tickets"`
+	PriceCategory   string `json:"price_category"`
+}
+
+type waitlistClaimResponse struct {
+	BookingID uuid.UUID `json:"booking_id"`
+}
+
+type WaitlistController struct {
+	repo contracts.WaitlistRepository
+}
+
+func NewWaitlistController(repo contracts.WaitlistRepository) WaitlistController {
+	return WaitlistController{repo: repo}
+}
+
+// Join queues the customer for seats of a sold-out show.
+// Freed seats are offered with WaitlistSeatOffered_v1 and have to be claimed before the offer expires.
+func (ctrl WaitlistController) Join(c echo.Context) error {
+	showID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid show id")
+	}
+
+	var request waitlistJoinRequest
+	if err := c.Bind(&request); err != nil {
+		return err
+	}
+
+	if request.NumberOfTickets < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "number of tickets must be greater than 0")
+	}
+	if request.CustomerEmail == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "customer email is required")
+	}
+
+	entryID := uuid.New()
+
+	err = ctrl.repo.Add(c.Request().Context(), entities.WaitlistEntry{
+		EntryID:         entryID,
+		ShowID:          showID,
+		CustomerEmail:   request.CustomerEmail,
+		NumberOfTickets: request.NumberOfTickets,
+		PriceCategory:   request.PriceCategory,
+	})
+	if errors.Is(err, db.ErrShowNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "show not found")
+	}
+	if errors.Is(err, db.ErrShowCanceled) {
+		return echo.NewHTTPError(http.StatusConflict, "show is canceled")
+	}
+	if errors.Is(err, db.ErrUnknownPriceCategory) {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown price category")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to join waitlist: %w", err)
+	}
+
+	// the entry could get an offer right away when seats are free
+	entry, err := ctrl.repo.FindByID(c.Request().Context(), entryID)
+	if err != nil {
+		return fmt.Errorf("failed to find waitlist entry: %w", err)
+	}
+
+	return c.JSON(http.StatusCreated, entry)
+}
+
+func (ctrl WaitlistController) FindByID(c echo.Context) error {
+	entryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid waitlist entry id")
+	}
+
+	entry, err := ctrl.repo.FindByID(c.Request().Context(), entryID)
+	if errors.Is(err, db.ErrWaitlistEntryNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "waitlist entry not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find waitlist entry: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, entry)
+}
+
+// Claim books the seats offered to the waitlist entry.
+func (ctrl WaitlistController) Claim(c echo.Context) error {
+	entryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid waitlist entry id")
+	}
+
+	bookingID, err := ctrl.repo.Claim(c.Request().Context(), entryID)
+	if errors.Is(err, db.ErrWaitlistEntryNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "waitlist entry not found")
+	}
+	if errors.Is(err, db.ErrWaitlistOfferExpired) {
+		return echo.NewHTTPError(http.StatusGone, "waitlist offer expired")
+	}
+	if errors.Is(err, db.ErrWaitlistOfferNotActive) {
+		return echo.NewHTTPError(http.StatusConflict, "waitlist entry has no offer yet")
+	}
+	if errors.Is(err, db.ErrShowCanceled) {
+		return echo.NewHTTPError(http.StatusConflict, "show is canceled")
+	}
+	if errors.Is(err, db.ErrUnknownPriceCategory) {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown price category")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim waitlist offer: %w", err)
+	}
+
+	return c.JSON(http.StatusCreated, waitlistClaimResponse{BookingID: bookingID})
+}
@@ -6,14 +6,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"tickets/api"
+	"tickets/db"
 	"tickets/message"
+	"tickets/process_manager"
 	"tickets/service"
+	"time"
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/clients"
 	"github.com/ThreeDotsLabs/go-event-driven/common/log"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"github.com/uptrace/opentelemetry-go-extra/otelsql"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
@@ -32,11 +37,7 @@ func main() {
 
 	apiClients, err := clients.NewClientsWithHttpClient(
 		os.Getenv("GATEWAY_ADDR"),
-		func(ctx context.Context, req *http.Request) error {
-			req.Header.Set("Correlation-ID", log.CorrelationIDFromContext(ctx))
-
-			return nil
-		},
+		correlationIDRequestEditor,
 		traceHttpClient,
 	)
 	if err != nil {
@@ -53,27 +54,117 @@ func main() {
 	dbConn := sqlx.NewDb(traceDB, "postgres")
 	defer dbConn.Close()
 
-	redisClient := message.NewRedisClient(os.Getenv("REDIS_ADDR"))
-	defer redisClient.Close()
+	brokerConfig := message.BrokerConfig{
+		Type: message.BrokerType(os.Getenv("MESSAGE_BROKER")),
+		DB:   dbConn.DB,
+	}
+
+	var redisClient *redis.Client
+	if brokerConfig.Type == "" || brokerConfig.Type == message.BrokerTypeRedis {
+		redisClient = message.NewRedisClient(os.Getenv("REDIS_ADDR"))
+		defer redisClient.Close()
+
+		brokerConfig.RedisClient = redisClient
+	}
 
 	spreadsheetsService := api.NewSpreadsheetsAPIClient(apiClients)
 	receiptsService := api.NewReceiptsServiceClient(apiClients)
 	filesAPI := api.NewFilesApiClient(apiClients)
-	deadNationAPI := api.NewDeadNationClient(apiClients)
 	paymentsService := api.NewPaymentServiceClient(apiClients)
 	transportationService := api.NewTransportationClient(apiClients)
 
+	deadNationAPI := api.NewDeadNationClient(apiClients)
+
 	err = service.New(
 		dbConn,
-		redisClient,
+		brokerConfig,
 		spreadsheetsService,
 		receiptsService,
 		filesAPI,
 		deadNationAPI,
 		paymentsService,
 		transportationService,
+		// the common clients have no accommodation API, VIP bundles with a hotel are rejected until it's added
+		nil,
+		vipBundleDeadlinesFromEnv(),
+		vipBundleTaxiCapacityFromEnv(),
+		exchangeRatesFromEnv(),
+		waitlistOfferTTLFromEnv(),
 	).Run(ctx)
 	if err != nil {
 		panic(err)
 	}
 }
+
+func correlationIDRequestEditor(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Correlation-ID", log.CorrelationIDFromContext(ctx))
+
+	return nil
+}
+
+// vipBundleDeadlinesFromEnv overrides default deadlines with durations like "30m" from the environment.
+func vipBundleDeadlinesFromEnv() process_manager.VipBundleDeadlines {
+	deadlines := process_manager.DefaultVipBundleDeadlines()
+
+	for env, deadline := range map[string]*time.Duration{
+		"VIP_BUNDLE_SHOW_BOOKING_DEADLINE":   &deadlines.ShowBooking,
+		"VIP_BUNDLE_INBOUND_FLIGHT_DEADLINE": &deadlines.InboundFlight,
+		"VIP_BUNDLE_RETURN_FLIGHT_DEADLINE":  &deadlines.ReturnFlight,
+		"VIP_BUNDLE_HOTEL_DEADLINE":          &deadlines.Hotel,
+		"VIP_BUNDLE_TAXI_DEADLINE":           &deadlines.Taxi,
+		"VIP_BUNDLE_COMPENSATION_DEADLINE":   &deadlines.Compensation,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			panic(fmt.Errorf("invalid %s: %w", env, err))
+		}
+		*deadline = duration
+	}
+
+	return deadlines
+}
+
+// vipBundleTaxiCapacityFromEnv returns the number of passengers fitting in one taxi booked for a VIP bundle.
+func vipBundleTaxiCapacityFromEnv() int {
+	value := os.Getenv("VIP_BUNDLE_TAXI_CAPACITY")
+	if value == "" {
+		return process_manager.DefaultTaxiCapacity
+	}
+
+	capacity, err := strconv.Atoi(value)
+	if err != nil || capacity < 1 {
+		panic(fmt.Errorf("invalid VIP_BUNDLE_TAXI_CAPACITY: %q", value))
+	}
+
+	return capacity
+}
+
+// exchangeRatesFromEnv returns rates like "EUR/USD=1.08,GBP/USD=1.27" used to convert revenue reports.
+func exchangeRatesFromEnv() api.FixedExchangeRates {
+	rates, err := api.ParseFixedExchangeRates(os.Getenv("EXCHANGE_RATES"))
+	if err != nil {
+		panic(fmt.Errorf("invalid EXCHANGE_RATES: %w", err))
+	}
+
+	return rates
+}
+
+// waitlistOfferTTLFromEnv returns how long seats offered to the waitlist are held, like "15m".
+func waitlistOfferTTLFromEnv() time.Duration {
+	value := os.Getenv("WAITLIST_OFFER_TTL")
+	if value == "" {
+		return db.DefaultWaitlistOfferTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		panic(fmt.Errorf("invalid WAITLIST_OFFER_TTL: %q", value))
+	}
+
+	return ttl
+}
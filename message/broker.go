@@ -0,0 +1,129 @@
+package message
+
+import (
+	"database/sql"
+	"fmt"
+	"tickets/observability"
+	"time"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill"
+	watermillSQL "github.com/ThreeDotsLabs/watermill-sql/v2/pkg/sql"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/redis/go-redis/v9"
+)
+
+type BrokerType string
+
+const (
+	// BrokerTypeRedis uses Redis Streams, it's the default for production.
+	BrokerTypeRedis BrokerType = "redis"
+	// BrokerTypePostgres uses watermill-sql, so small deployments don't need Redis.
+	BrokerTypePostgres BrokerType = "postgres"
+	// BrokerTypeGoChannel keeps messages in memory, it's meant for hermetic tests.
+	// It doesn't keep the order of messages, so ordered handlers may see events of a booking out of order.
+	BrokerTypeGoChannel BrokerType = "gochannel"
+)
+
+type BrokerConfig struct {
+	Type BrokerType
+
+	// RedisClient is required for BrokerTypeRedis.
+	RedisClient *redis.Client
+	// DB is required for BrokerTypePostgres.
+	DB *sql.DB
+}
+
+// Broker hides which transport is used, so handlers and processors work the same way on every backend.
+type Broker struct {
+	publisher     message.Publisher
+	newSubscriber func(consumerGroup string) (message.Subscriber, error)
+}
+
+func NewBroker(config BrokerConfig, logger watermill.LoggerAdapter) Broker {
+	var broker Broker
+
+	switch config.Type {
+	case BrokerTypeRedis, "":
+		if config.RedisClient == nil {
+			panic("redis client is required for redis broker")
+		}
+
+		broker = Broker{
+			publisher: NewRedisPublisher(config.RedisClient, logger),
+			newSubscriber: func(consumerGroup string) (message.Subscriber, error) {
+				return NewRedisSubscriber(config.RedisClient, consumerGroup, logger), nil
+			},
+		}
+	case BrokerTypePostgres:
+		if config.DB == nil {
+			panic("db is required for postgres broker")
+		}
+
+		broker = Broker{
+			publisher: newPostgresPublisher(config.DB, logger),
+			newSubscriber: func(consumerGroup string) (message.Subscriber, error) {
+				return newPostgresSubscriber(config.DB, consumerGroup, logger)
+			},
+		}
+	case BrokerTypeGoChannel:
+		// GoChannel has no global state, the same instance has to be used for publishing and subscribing
+		pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+		broker = Broker{
+			publisher: pubSub,
+			newSubscriber: func(_ string) (message.Subscriber, error) {
+				// every subscription receives all messages, it's the same as a separate consumer group
+				return pubSub, nil
+			},
+		}
+	default:
+		panic(fmt.Sprintf("unknown message broker type: %s", config.Type))
+	}
+
+	broker.publisher = log.CorrelationPublisherDecorator{Publisher: broker.publisher}
+	broker.publisher = observability.TracingPublisherDecorator{Publisher: broker.publisher}
+
+	return broker
+}
+
+func (b Broker) Publisher() message.Publisher {
+	return b.publisher
+}
+
+// NewSubscriber returns a subscriber for the consumer group.
+// Each consumer group receives all messages from the topic, within the group they are load-balanced.
+func (b Broker) NewSubscriber(consumerGroup string) (message.Subscriber, error) {
+	return b.newSubscriber(consumerGroup)
+}
+
+func newPostgresPublisher(db *sql.DB, logger watermill.LoggerAdapter) message.Publisher {
+	pub, err := watermillSQL.NewPublisher(
+		db,
+		watermillSQL.PublisherConfig{
+			SchemaAdapter:        watermillSQL.DefaultPostgreSQLSchema{},
+			AutoInitializeSchema: true,
+		},
+		logger,
+	)
+	if err != nil {
+		panic(fmt.Errorf("failed to create new watermill sql publisher: %w", err))
+	}
+
+	return pub
+}
+
+func newPostgresSubscriber(db *sql.DB, consumerGroup string, logger watermill.LoggerAdapter) (message.Subscriber, error) {
+	return watermillSQL.NewSubscriber(
+		db,
+		watermillSQL.SubscriberConfig{
+			ConsumerGroup:    consumerGroup,
+			PollInterval:     time.Millisecond * 100,
+			InitializeSchema: true,
+			SchemaAdapter:    watermillSQL.DefaultPostgreSQLSchema{},
+			OffsetsAdapter:   watermillSQL.DefaultPostgreSQLOffsetsAdapter{},
+		},
+		logger,
+	)
+}
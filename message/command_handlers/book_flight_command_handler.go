@@ -35,6 +35,7 @@ func (h BookFlightCommandHandler) Handle(ctx context.Context, command *entities.
 			Header:        entities.NewEventHeader(),
 			FailureReason: err.Error(),
 			FlightID:      command.FlightID,
+			Leg:           command.Leg,
 			ReferenceID:   command.ReferenceID,
 		})
 		if err != nil {
@@ -50,6 +51,7 @@ func (h BookFlightCommandHandler) Handle(ctx context.Context, command *entities.
 	err = h.eventBus.Publish(ctx, entities.FlightBooked_v1{
 		Header:      entities.NewEventHeader(),
 		FlightID:    command.FlightID,
+		Leg:         command.Leg,
 		TicketIDs:   resp.TicketIds,
 		ReferenceID: command.ReferenceID,
 	})
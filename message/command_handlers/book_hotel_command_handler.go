@@ -0,0 +1,62 @@
+package command_handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type BookHotelCommandHandler struct {
+	accommodationClient contracts.AccommodationService
+	eventBus            *cqrs.EventBus
+}
+
+func NewBookHotelCommandHandler(accommodationService contracts.AccommodationService, eventBus *cqrs.EventBus) BookHotelCommandHandler {
+	return BookHotelCommandHandler{
+		accommodationClient: accommodationService,
+		eventBus:            eventBus,
+	}
+}
+
+func (h BookHotelCommandHandler) Handle(ctx context.Context, command *entities.BookHotel) error {
+	resp, err := h.accommodationClient.BookHotelRoom(ctx, entities.BookHotelRoomRequest{
+		CustomerEmail:  command.CustomerEmail,
+		HotelID:        command.HotelID,
+		NumberOfGuests: command.NumberOfGuests,
+		Nights:         command.Nights,
+		ReferenceId:    command.ReferenceID,
+		IdempotencyKey: command.IdempotencyKey,
+	})
+	if errors.Is(err, entities.ErrNoHotelRoomsAvailable) || errors.Is(err, entities.ErrHotelBookingUnavailable) {
+		err = h.eventBus.Publish(ctx, entities.HotelBookingFailed_v1{
+			Header:        entities.NewEventHeader(),
+			HotelID:       command.HotelID,
+			FailureReason: err.Error(),
+			ReferenceID:   command.ReferenceID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish HotelBookingFailed_v1 event: %w", err)
+		}
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to book hotel room: %w", err)
+	}
+
+	err = h.eventBus.Publish(ctx, entities.HotelBooked_v1{
+		Header:         entities.NewEventHeader(),
+		HotelID:        command.HotelID,
+		HotelBookingID: resp.HotelBookingID,
+		ReferenceID:    command.ReferenceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish HotelBooked_v1 event: %w", err)
+	}
+
+	return nil
+}
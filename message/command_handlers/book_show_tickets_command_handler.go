@@ -35,7 +35,7 @@ func (h BookShowTicketsCommandHandler) Handle(ctx context.Context, command *enti
 		return nil
 	}
 
-	if errors.Is(err, db.ErrNoPlacesLeft) {
+	if errors.Is(err, db.ErrNoPlacesLeft) || errors.Is(err, db.ErrShowCanceled) {
 		publishErr := h.eventBus.Publish(ctx, entities.BookingFailed_v1{
 			Header:        entities.NewEventHeader(),
 			BookingID:     command.BookingID,
@@ -34,6 +34,7 @@ func (h BookTaxiCommandHandler) Handle(ctx context.Context, command *entities.Bo
 		err = h.eventBus.Publish(ctx, entities.TaxiBookingFailed_v1{
 			Header:        entities.NewEventHeader(),
 			FailureReason: err.Error(),
+			TaxiIndex:     command.TaxiIndex,
 			ReferenceID:   command.ReferenceID,
 		})
 		if err != nil {
@@ -49,6 +50,7 @@ func (h BookTaxiCommandHandler) Handle(ctx context.Context, command *entities.Bo
 	err = h.eventBus.Publish(ctx, entities.TaxiBooked_v1{
 		Header:        entities.NewEventHeader(),
 		TaxiBookingID: resp.TaxiBookingId,
+		TaxiIndex:     command.TaxiIndex,
 		ReferenceID:   command.ReferenceID,
 	})
 	if err != nil {
@@ -0,0 +1,50 @@
+package command_handlers
+
+import (
+	"context"
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type CancelBookingCommandHandler struct {
+	bookingRepo contracts.BookingRepository
+	ticketRepo  contracts.TicketRepository
+	commandBus  *cqrs.CommandBus
+}
+
+func NewCancelBookingCommandHandler(
+	bookingRepo contracts.BookingRepository,
+	ticketRepo contracts.TicketRepository,
+	commandBus *cqrs.CommandBus,
+) CancelBookingCommandHandler {
+	return CancelBookingCommandHandler{
+		bookingRepo: bookingRepo,
+		ticketRepo:  ticketRepo,
+		commandBus:  commandBus,
+	}
+}
+
+func (h CancelBookingCommandHandler) Handle(ctx context.Context, command *entities.CancelBooking) error {
+	err := h.bookingRepo.Cancel(ctx, command.BookingID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel booking: %w", err)
+	}
+
+	tickets, err := h.ticketRepo.FindByBookingID(ctx, command.BookingID.String())
+	if err != nil {
+		return fmt.Errorf("failed to find tickets of booking: %w", err)
+	}
+
+	// tickets confirmed after the cancellation are refunded when they are stored
+	for _, ticket := range tickets {
+		err := h.commandBus.Send(ctx, entities.NewRefundTicketOfCanceledBooking(command.BookingID, ticket.TicketID))
+		if err != nil {
+			return fmt.Errorf("failed to send RefundTicket command: %w", err)
+		}
+	}
+
+	return nil
+}
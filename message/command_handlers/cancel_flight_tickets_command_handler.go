@@ -2,23 +2,48 @@ package command_handlers
 
 import (
 	"context"
+	"fmt"
 	"tickets/entities"
 	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 )
 
 type CancelFlightTicketsCommandHandler struct {
 	transportationClient contracts.TransportationService
+	eventBus             *cqrs.EventBus
 }
 
-func NewCancelFlightTicketsCommandHandler(transportationService contracts.TransportationService) CancelFlightTicketsCommandHandler {
-	return CancelFlightTicketsCommandHandler{transportationClient: transportationService}
+func NewCancelFlightTicketsCommandHandler(
+	transportationService contracts.TransportationService,
+	eventBus *cqrs.EventBus,
+) CancelFlightTicketsCommandHandler {
+	return CancelFlightTicketsCommandHandler{
+		transportationClient: transportationService,
+		eventBus:             eventBus,
+	}
 }
 
 func (h CancelFlightTicketsCommandHandler) Handle(ctx context.Context, command *entities.CancelFlightTickets) error {
-	return h.transportationClient.CancelFlightTickets(
+	err := h.transportationClient.CancelFlightTickets(
 		ctx,
 		entities.CancelFlightTicketsRequest{
 			TicketIds: command.FlightTicketIDs,
 		},
 	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel flight tickets: %w", err)
+	}
+
+	err = h.eventBus.Publish(ctx, entities.FlightTicketsCanceled_v1{
+		Header:          entities.NewEventHeader(),
+		FlightID:        command.FlightID,
+		FlightTicketIDs: command.FlightTicketIDs,
+		ReferenceID:     command.ReferenceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish FlightTicketsCanceled_v1 event: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,45 @@
+package command_handlers
+
+import (
+	"context"
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type CancelHotelCommandHandler struct {
+	accommodationClient contracts.AccommodationService
+	eventBus            *cqrs.EventBus
+}
+
+func NewCancelHotelCommandHandler(
+	accommodationService contracts.AccommodationService,
+	eventBus *cqrs.EventBus,
+) CancelHotelCommandHandler {
+	return CancelHotelCommandHandler{
+		accommodationClient: accommodationService,
+		eventBus:            eventBus,
+	}
+}
+
+func (h CancelHotelCommandHandler) Handle(ctx context.Context, command *entities.CancelHotel) error {
+	err := h.accommodationClient.CancelHotelRoomBooking(ctx, entities.CancelHotelRoomBookingRequest{
+		HotelBookingID: command.HotelBookingID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel hotel room booking: %w", err)
+	}
+
+	err = h.eventBus.Publish(ctx, entities.HotelBookingCanceled_v1{
+		Header:         entities.NewEventHeader(),
+		HotelBookingID: command.HotelBookingID,
+		ReferenceID:    command.ReferenceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish HotelBookingCanceled_v1 event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package command_handlers
+
+import (
+	"context"
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type CancelTaxiCommandHandler struct {
+	transportationClient contracts.TransportationService
+	eventBus             *cqrs.EventBus
+}
+
+func NewCancelTaxiCommandHandler(
+	transportationService contracts.TransportationService,
+	eventBus *cqrs.EventBus,
+) CancelTaxiCommandHandler {
+	return CancelTaxiCommandHandler{
+		transportationClient: transportationService,
+		eventBus:             eventBus,
+	}
+}
+
+func (h CancelTaxiCommandHandler) Handle(ctx context.Context, command *entities.CancelTaxi) error {
+	err := h.transportationClient.CancelTaxiBooking(ctx, entities.CancelTaxiBookingRequest{
+		TaxiBookingID: command.TaxiBookingID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel taxi booking: %w", err)
+	}
+
+	err = h.eventBus.Publish(ctx, entities.TaxiBookingCanceled_v1{
+		Header:        entities.NewEventHeader(),
+		TaxiBookingID: command.TaxiBookingID,
+		ReferenceID:   command.ReferenceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish TaxiBookingCanceled_v1 event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package command_handlers
+
+import (
+	"context"
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+)
+
+type ExpireWaitlistOfferCommandHandler struct {
+	waitlistRepo contracts.WaitlistRepository
+}
+
+func NewExpireWaitlistOfferCommandHandler(waitlistRepo contracts.WaitlistRepository) ExpireWaitlistOfferCommandHandler {
+	return ExpireWaitlistOfferCommandHandler{waitlistRepo: waitlistRepo}
+}
+
+func (h ExpireWaitlistOfferCommandHandler) Handle(ctx context.Context, command *entities.ExpireWaitlistOffer) error {
+	if err := h.waitlistRepo.ExpireOffer(ctx, command.EntryID); err != nil {
+		return fmt.Errorf("failed to expire waitlist offer: %w", err)
+	}
+
+	return nil
+}
@@ -53,8 +53,9 @@ func (h RefundTicketHandler) Handle(ctx context.Context, command *entities.Refun
 	}
 
 	err = h.eventBus.Publish(ctx, entities.TicketRefunded_v1{
-		Header:   entities.NewEventHeader(),
-		TicketID: command.TicketID,
+		Header:      entities.NewEventHeader(),
+		TicketID:    command.TicketID,
+		ReferenceID: command.ReferenceID,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to publish TicketRefunded event: %w", err)
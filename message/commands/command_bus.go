@@ -2,6 +2,8 @@ package commands
 
 import (
 	"fmt"
+	"tickets/message/delay"
+	"tickets/message/ordering"
 
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -14,6 +16,12 @@ func NewCommandBus(pub message.Publisher) *cqrs.CommandBus {
 			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
 				return fmt.Sprintf("commands.%s", params.CommandName), nil
 			},
+			OnSend: func(params cqrs.CommandBusOnSendParams) error {
+				// commands don't start new flows, events published by their handlers inherit the key
+				ordering.SetMessageKey(params.Message, params.Command)
+				delay.SetMessageMetadata(params.Message)
+				return nil
+			},
 			Marshaler: cqrs.JSONMarshaler{
 				GenerateName: cqrs.StructName,
 			},
@@ -4,6 +4,7 @@ import (
 	"tickets/message/command_handlers"
 	"tickets/message/command_handlers/contract"
 	"tickets/message/contracts"
+	"tickets/message/inbox"
 
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 )
@@ -11,12 +12,17 @@ import (
 func AddCommandProcessorHandlers(
 	cp *cqrs.CommandProcessor,
 	eventBus *cqrs.EventBus,
+	commandBus *cqrs.CommandBus,
 	bookingRepo contracts.BookingRepository,
+	ticketRepo contracts.TicketRepository,
+	waitlistRepo contracts.WaitlistRepository,
 	transportationService contracts.TransportationService,
+	accommodationService contracts.AccommodationService,
 	receiptsServiceClient contract.ReceiptsService,
 	paymentsServiceClient contract.PaymentsService,
+	handlersInbox inbox.Inbox,
 ) {
-	cp.AddHandlers(
+	handlers := []cqrs.CommandHandler{
 		cqrs.NewCommandHandler(
 			"TicketRefund",
 			command_handlers.NewRefundTicketHandler(eventBus, receiptsServiceClient, paymentsServiceClient).Handle,
@@ -25,6 +31,10 @@ func AddCommandProcessorHandlers(
 			"BookShowTickets",
 			command_handlers.NewBookShowTicketsCommandHandler(bookingRepo, eventBus).Handle,
 		),
+		cqrs.NewCommandHandler(
+			"CancelBooking",
+			command_handlers.NewCancelBookingCommandHandler(bookingRepo, ticketRepo, commandBus).Handle,
+		),
 		cqrs.NewCommandHandler(
 			"BookFlight",
 			command_handlers.NewBookFlightCommandHandler(transportationService, eventBus).Handle,
@@ -35,7 +45,29 @@ func AddCommandProcessorHandlers(
 		),
 		cqrs.NewCommandHandler(
 			"CancelFlightTickets",
-			command_handlers.NewCancelFlightTicketsCommandHandler(transportationService).Handle,
+			command_handlers.NewCancelFlightTicketsCommandHandler(transportationService, eventBus).Handle,
+		),
+		cqrs.NewCommandHandler(
+			"CancelTaxi",
+			command_handlers.NewCancelTaxiCommandHandler(transportationService, eventBus).Handle,
+		),
+		cqrs.NewCommandHandler(
+			"BookHotel",
+			command_handlers.NewBookHotelCommandHandler(accommodationService, eventBus).Handle,
+		),
+		cqrs.NewCommandHandler(
+			"CancelHotel",
+			command_handlers.NewCancelHotelCommandHandler(accommodationService, eventBus).Handle,
 		),
-	)
+	}
+
+	for _, handler := range handlers {
+		cp.AddHandlers(handlersInbox.CommandHandler(handler))
+	}
+
+	// free seats are counted by the waitlist only in serializable transactions
+	cp.AddHandlers(handlersInbox.Serializable().CommandHandler(cqrs.NewCommandHandler(
+		"ExpireWaitlistOffer",
+		command_handlers.NewExpireWaitlistOfferCommandHandler(waitlistRepo).Handle,
+	)))
 }
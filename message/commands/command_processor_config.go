@@ -4,26 +4,21 @@ import (
 	"fmt"
 
 	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/redis/go-redis/v9"
 )
 
 var marshaler = cqrs.JSONMarshaler{
 	GenerateName: cqrs.StructName,
 }
 
-func NewCommandProcessorConfig(rdb *redis.Client, logger watermill.LoggerAdapter) cqrs.CommandProcessorConfig {
+func NewCommandProcessorConfig(
+	newSubscriber func(consumerGroup string) (message.Subscriber, error),
+	logger watermill.LoggerAdapter,
+) cqrs.CommandProcessorConfig {
 	return cqrs.CommandProcessorConfig{
 		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
-			return redisstream.NewSubscriber(
-				redisstream.SubscriberConfig{
-					Client:        rdb,
-					ConsumerGroup: "svc-tickets.commands." + params.HandlerName,
-				},
-				logger,
-			)
+			return newSubscriber("svc-tickets.commands." + params.HandlerName)
 		},
 		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
 			return fmt.Sprintf("commands.%s", params.CommandName), nil
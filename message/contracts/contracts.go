@@ -3,6 +3,7 @@ package contracts
 import (
 	"context"
 	"tickets/entities"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -17,6 +18,7 @@ type ReceiptsService interface {
 
 type TicketRepository interface {
 	FindAll(ctx context.Context) ([]entities.Ticket, error)
+	FindByBookingID(ctx context.Context, bookingID string) ([]entities.Ticket, error)
 	Add(ctx context.Context, ticket entities.Ticket) error
 	Remove(ctx context.Context, ticketID string) error
 }
@@ -25,45 +27,118 @@ type ShowRepository interface {
 	Add(ctx context.Context, show entities.Show) error
 	FindAll(ctx context.Context) ([]entities.Show, error)
 	FindByID(ctx context.Context, showID uuid.UUID) (entities.Show, error)
+	FindSeats(ctx context.Context, showID uuid.UUID) ([]entities.ShowSeat, error)
+	UpdateByID(
+		ctx context.Context,
+		showID uuid.UUID,
+		updateFn func(show entities.Show) (entities.Show, error),
+	) (entities.Show, error)
+	Cancel(ctx context.Context, showID uuid.UUID) error
 }
 
 type BookingRepository interface {
 	Add(ctx context.Context, booking entities.Booking) error
+	FindByID(ctx context.Context, bookingID uuid.UUID) (entities.Booking, error)
+	FindByShowID(ctx context.Context, showID uuid.UUID) ([]entities.Booking, error)
+	IsCanceled(ctx context.Context, bookingID uuid.UUID) (bool, error)
+	AssignTicketSeat(ctx context.Context, bookingID uuid.UUID, ticketID uuid.UUID) (entities.Seat, bool, error)
+	Cancel(ctx context.Context, bookingID uuid.UUID) error
+	AddRefundedTicket(ctx context.Context, ticketID string) (entities.Booking, bool, error)
+}
+
+type WaitlistRepository interface {
+	Add(ctx context.Context, entry entities.WaitlistEntry) error
+	FindByID(ctx context.Context, entryID uuid.UUID) (entities.WaitlistEntry, error)
+	OfferFreedSeats(ctx context.Context, showID uuid.UUID) error
+	Claim(ctx context.Context, entryID uuid.UUID) (uuid.UUID, error)
+	ExpireOffer(ctx context.Context, entryID uuid.UUID) error
 }
 
 type VipBundleRepository interface {
 	Add(ctx context.Context, vipBundle entities.VipBundle) error
 	Get(ctx context.Context, vipBundleID uuid.UUID) (entities.VipBundle, error)
 	GetByBookingID(ctx context.Context, bookingID uuid.UUID) (entities.VipBundle, error)
+	FindByBookingID(ctx context.Context, bookingID uuid.UUID) (entities.VipBundle, bool, error)
+	FindByCustomerEmail(ctx context.Context, customerEmail string) ([]entities.VipBundle, error)
+	FindWithIncompleteRollback(ctx context.Context) ([]entities.VipBundle, error)
 
 	UpdateByID(
 		ctx context.Context,
 		bookingID uuid.UUID,
-		updateFn func(vipBundle entities.VipBundle) (entities.VipBundle, error),
+		updateFn func(ctx context.Context, vipBundle entities.VipBundle) (entities.VipBundle, error),
 	) (entities.VipBundle, error)
 
 	UpdateByBookingID(
 		ctx context.Context,
 		bookingID uuid.UUID,
-		updateFn func(vipBundle entities.VipBundle) (entities.VipBundle, error),
+		updateFn func(ctx context.Context, vipBundle entities.VipBundle) (entities.VipBundle, error),
 	) (entities.VipBundle, error)
 }
 
+type OpsBookingReadModel interface {
+	OnBookingMade(ctx context.Context, event *entities.BookingMade_v1) error
+	OnTicketReceiptIssued(ctx context.Context, event *entities.TicketReceiptIssued_v1) error
+	OnTicketBookingConfirmed(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error
+	OnTicketPrinted(ctx context.Context, event *entities.TicketPrinted_v1) error
+	OnTicketRefunded(ctx context.Context, event *entities.TicketRefunded_v1) error
+	OnBookingCanceled(ctx context.Context, event *entities.BookingCanceled_v1) error
+}
+
 type DataLake interface {
 	FindAll(ctx context.Context) ([]entities.DataLakeEvent, error)
 	Store(ctx context.Context, event entities.DataLakeEvent) error
 }
 
+type DeadLetterRepository interface {
+	Add(ctx context.Context, deadLetter entities.DeadLetter) error
+	FindAll(ctx context.Context) ([]entities.DeadLetter, error)
+	FindByID(ctx context.Context, deadLetterID uuid.UUID) (entities.DeadLetter, error)
+	Remove(ctx context.Context, deadLetterID uuid.UUID) error
+	Count(ctx context.Context) (int, error)
+}
+
+type ScheduledMessageRepository interface {
+	Add(ctx context.Context, scheduledMessage entities.ScheduledMessage) error
+	FindAll(ctx context.Context) ([]entities.ScheduledMessage, error)
+	Remove(ctx context.Context, scheduledMessageID uuid.UUID) error
+	ClaimDue(ctx context.Context, claimFor time.Duration) (entities.ScheduledMessage, bool, error)
+	MarkReleased(ctx context.Context, scheduledMessageID uuid.UUID) error
+	Unclaim(ctx context.Context, scheduledMessageID uuid.UUID) error
+	Count(ctx context.Context) (int, error)
+}
+
+// Scheduler sends commands and publishes events at the given time.
+// The returned ID can be used to cancel the message before it's delivered.
+type Scheduler interface {
+	SendCommandAt(ctx context.Context, cmd any, deliverAt time.Time) (uuid.UUID, error)
+	SendCommandAfter(ctx context.Context, cmd any, delay time.Duration) (uuid.UUID, error)
+	PublishEventAt(ctx context.Context, event any, deliverAt time.Time) (uuid.UUID, error)
+	PublishEventAfter(ctx context.Context, event any, delay time.Duration) (uuid.UUID, error)
+	Cancel(ctx context.Context, scheduledMessageID uuid.UUID) error
+}
+
 type FilesAPI interface {
 	UploadFile(ctx context.Context, fileID string, fileContent string) error
 }
 
 type DeadNationApi interface {
 	BookInDeadNation(ctx context.Context, booking entities.DeadNationBooking) error
+	CancelInDeadNation(ctx context.Context, bookingID uuid.UUID) error
+}
+
+type AccommodationService interface {
+	BookHotelRoom(ctx context.Context, request entities.BookHotelRoomRequest) (entities.BookHotelRoomResponse, error)
+	CancelHotelRoomBooking(ctx context.Context, request entities.CancelHotelRoomBookingRequest) error
 }
 
 type TransportationService interface {
 	BookFlight(ctx context.Context, request entities.BookFlightTicketRequest) (entities.BookFlightTicketResponse, error)
 	BookTaxi(ctx context.Context, request entities.BookTaxiRequest) (entities.BookTaxiResponse, error)
 	CancelFlightTickets(ctx context.Context, request entities.CancelFlightTicketsRequest) error
+	CancelTaxiBooking(ctx context.Context, request entities.CancelTaxiBookingRequest) error
+}
+
+type ExchangeRates interface {
+	// ExchangeRate returns the amount of the "to" currency for one unit of the "from" currency.
+	ExchangeRate(ctx context.Context, from string, to string) (entities.Decimal, error)
 }
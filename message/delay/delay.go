@@ -0,0 +1,73 @@
+package delay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+)
+
+const (
+	DeliverAtMetadataKey          = "deliver_at"
+	ScheduledMessageIDMetadataKey = "scheduled_message_id"
+)
+
+type delivery struct {
+	scheduledMessageID uuid.UUID
+	deliverAt          time.Time
+}
+
+type contextKey struct{}
+
+// WithDeliverAt marks the command or event sent by the command or event bus with ctx to be delivered at deliverAt.
+// scheduledMessageID identifies the stored message, so it can be canceled before it's delivered.
+// Each delayed message needs its own context, as the ID is unique.
+func WithDeliverAt(ctx context.Context, scheduledMessageID uuid.UUID, deliverAt time.Time) context.Context {
+	return context.WithValue(ctx, contextKey{}, delivery{scheduledMessageID: scheduledMessageID, deliverAt: deliverAt})
+}
+
+// WithDelay is WithDeliverAt for messages which should be delivered after the delay.
+func WithDelay(ctx context.Context, scheduledMessageID uuid.UUID, delay time.Duration) context.Context {
+	return WithDeliverAt(ctx, scheduledMessageID, time.Now().Add(delay))
+}
+
+// SetMessageMetadata copies the delivery time from the context of the sent message to its metadata.
+// It's called by the command and event buses, messages without the delivery time are left untouched.
+func SetMessageMetadata(msg *message.Message) {
+	d, ok := msg.Context().Value(contextKey{}).(delivery)
+	if !ok {
+		return
+	}
+
+	msg.Metadata.Set(ScheduledMessageIDMetadataKey, d.scheduledMessageID.String())
+	msg.Metadata.Set(DeliverAtMetadataKey, d.deliverAt.UTC().Format(time.RFC3339Nano))
+}
+
+// FromMessage returns when the message should be delivered.
+// It returns false for messages which should be delivered right away.
+func FromMessage(msg *message.Message) (scheduledMessageID uuid.UUID, deliverAt time.Time, ok bool, err error) {
+	rawDeliverAt := msg.Metadata.Get(DeliverAtMetadataKey)
+	if rawDeliverAt == "" {
+		return uuid.Nil, time.Time{}, false, nil
+	}
+
+	deliverAt, err = time.Parse(time.RFC3339Nano, rawDeliverAt)
+	if err != nil {
+		return uuid.Nil, time.Time{}, false, fmt.Errorf("invalid delivery time of message %s: %w", msg.UUID, err)
+	}
+
+	scheduledMessageID, err = uuid.Parse(msg.Metadata.Get(ScheduledMessageIDMetadataKey))
+	if err != nil {
+		return uuid.Nil, time.Time{}, false, fmt.Errorf("invalid scheduled message id of message %s: %w", msg.UUID, err)
+	}
+
+	return scheduledMessageID, deliverAt, true, nil
+}
+
+// RemoveMessageMetadata removes the delivery time, so the message is delivered right away when it's published again.
+func RemoveMessageMetadata(metadata message.Metadata) {
+	delete(metadata, DeliverAtMetadataKey)
+	delete(metadata, ScheduledMessageIDMetadataKey)
+}
@@ -23,6 +23,6 @@ func (h AppendToTrackerHandler) Handle(ctx context.Context, event *entities.Tick
 	return h.spreadsheetsClient.AppendRow(
 		ctx,
 		"tickets-to-print",
-		[]string{event.TicketID, event.CustomerEmail, event.Price.Amount, event.Price.Currency},
+		[]string{event.TicketID, event.CustomerEmail, event.Price.Amount.String(), event.Price.Currency},
 	)
 }
@@ -2,26 +2,49 @@ package event_handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"tickets/entities"
 	"tickets/message/contracts"
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 )
 
 type BookPlaceInDeadNationHandler struct {
 	deadNationClient contracts.DeadNationApi
 	showRepo         contracts.ShowRepository
+	bookingRepo      contracts.BookingRepository
+	eventBus         *cqrs.EventBus
 }
 
-func NewBookingMadeHandler(deadNationClient contracts.DeadNationApi, showRepo contracts.ShowRepository) BookPlaceInDeadNationHandler {
-	return BookPlaceInDeadNationHandler{deadNationClient: deadNationClient, showRepo: showRepo}
+func NewBookingMadeHandler(
+	deadNationClient contracts.DeadNationApi,
+	showRepo contracts.ShowRepository,
+	bookingRepo contracts.BookingRepository,
+	eventBus *cqrs.EventBus,
+) BookPlaceInDeadNationHandler {
+	return BookPlaceInDeadNationHandler{
+		deadNationClient: deadNationClient,
+		showRepo:         showRepo,
+		bookingRepo:      bookingRepo,
+		eventBus:         eventBus,
+	}
 }
 
 func (h BookPlaceInDeadNationHandler) Handle(ctx context.Context, event *entities.BookingMade_v1) error {
 	log.FromContext(ctx).Info("Generating ticket for booking")
 
+	booking, err := h.bookingRepo.FindByID(ctx, event.BookingID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking: %w", err)
+	}
+	if booking.CanceledAt != nil {
+		log.FromContext(ctx).WithField("booking_id", event.BookingID).Info("Booking was canceled, skipping Dead Nation booking")
+		return nil
+	}
+
 	show, err := h.showRepo.FindByID(ctx, event.ShowId)
 	if err != nil {
 		return fmt.Errorf("failed to get show: %w", err)
@@ -33,6 +56,19 @@ func (h BookPlaceInDeadNationHandler) Handle(ctx context.Context, event *entitie
 		NumberOfTickets:   event.NumberOfTickets,
 		BookingID:         event.BookingID,
 	})
+	if errors.Is(err, entities.ErrDeadNationBookingRejected) {
+		// retrying won't help, the booking is compensated by handlers of DeadNationBookingFailed_v1
+		publishErr := h.eventBus.Publish(ctx, entities.DeadNationBookingFailed_v1{
+			Header:        entities.NewEventHeaderWithIdempotencyKey(event.BookingID.String() + "-dead-nation-failed"),
+			BookingID:     event.BookingID,
+			FailureReason: err.Error(),
+		})
+		if publishErr != nil {
+			return fmt.Errorf("failed to publish DeadNationBookingFailed_v1 event: %w", publishErr)
+		}
+
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to book in dead nation: %w", err)
 	}
@@ -0,0 +1,33 @@
+package event_handlers
+
+import (
+	"context"
+	"fmt"
+
+	"tickets/entities"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+// CancelBookingRejectedByDeadNationHandler releases seats and refunds tickets of bookings which Dead Nation refused.
+type CancelBookingRejectedByDeadNationHandler struct {
+	commandBus *cqrs.CommandBus
+}
+
+func NewCancelBookingRejectedByDeadNationHandler(commandBus *cqrs.CommandBus) CancelBookingRejectedByDeadNationHandler {
+	return CancelBookingRejectedByDeadNationHandler{commandBus: commandBus}
+}
+
+func (h CancelBookingRejectedByDeadNationHandler) Handle(ctx context.Context, event *entities.DeadNationBookingFailed_v1) error {
+	log.FromContext(ctx).WithField("booking_id", event.BookingID).Info("Canceling booking rejected by Dead Nation")
+
+	err := h.commandBus.Send(ctx, entities.CancelBooking{
+		BookingID: event.BookingID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send CancelBooking command: %w", err)
+	}
+
+	return nil
+}
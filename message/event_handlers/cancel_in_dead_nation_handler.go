@@ -0,0 +1,54 @@
+package event_handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/google/uuid"
+)
+
+// CancelInDeadNationHandler cancels the Dead Nation booking once all tickets of the booking are refunded.
+type CancelInDeadNationHandler struct {
+	deadNationClient contracts.DeadNationApi
+	bookingRepo      contracts.BookingRepository
+}
+
+func NewCancelInDeadNationHandler(
+	deadNationClient contracts.DeadNationApi,
+	bookingRepo contracts.BookingRepository,
+) CancelInDeadNationHandler {
+	return CancelInDeadNationHandler{
+		deadNationClient: deadNationClient,
+		bookingRepo:      bookingRepo,
+	}
+}
+
+func (h CancelInDeadNationHandler) Handle(ctx context.Context, event *entities.TicketRefunded_v1) error {
+	// the refund may be already recorded by RecordRefundedTicketHandler, the booking is returned anyway
+	booking, _, err := h.bookingRepo.AddRefundedTicket(ctx, event.TicketID)
+	if err != nil {
+		return fmt.Errorf("failed to add refunded ticket to booking: %w", err)
+	}
+	if booking.BookingID == uuid.Nil || booking.RefundedTickets < booking.NumberOfTickets {
+		return nil
+	}
+
+	logger := log.FromContext(ctx).WithField("booking_id", booking.BookingID)
+	logger.Info("All tickets refunded, canceling booking in Dead Nation")
+
+	err = h.deadNationClient.CancelInDeadNation(ctx, booking.BookingID)
+	if errors.Is(err, entities.ErrDeadNationCancellationNotSupported) {
+		logger.WithError(err).Warn("The booking has to be canceled in Dead Nation manually")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel booking in Dead Nation: %w", err)
+	}
+
+	return nil
+}
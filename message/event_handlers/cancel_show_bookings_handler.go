@@ -0,0 +1,48 @@
+package event_handlers
+
+import (
+	"context"
+	"fmt"
+
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+// CancelShowBookingsHandler cancels every booking of a canceled show, which refunds all of their tickets.
+type CancelShowBookingsHandler struct {
+	bookingRepo contracts.BookingRepository
+	commandBus  *cqrs.CommandBus
+}
+
+func NewCancelShowBookingsHandler(bookingRepo contracts.BookingRepository, commandBus *cqrs.CommandBus) CancelShowBookingsHandler {
+	return CancelShowBookingsHandler{
+		bookingRepo: bookingRepo,
+		commandBus:  commandBus,
+	}
+}
+
+func (h CancelShowBookingsHandler) Handle(ctx context.Context, event *entities.ShowCanceled_v1) error {
+	bookings, err := h.bookingRepo.FindByShowID(ctx, event.ShowID)
+	if err != nil {
+		return fmt.Errorf("failed to find bookings of show %s: %w", event.ShowID, err)
+	}
+
+	log.FromContext(ctx).
+		WithField("show_id", event.ShowID).
+		WithField("bookings", len(bookings)).
+		Info("Canceling bookings of canceled show")
+
+	for _, booking := range bookings {
+		err := h.commandBus.Send(ctx, entities.CancelBooking{
+			BookingID: booking.BookingID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send CancelBooking command for booking %s: %w", booking.BookingID, err)
+		}
+	}
+
+	return nil
+}
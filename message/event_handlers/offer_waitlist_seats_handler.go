@@ -0,0 +1,39 @@
+package event_handlers
+
+import (
+	"context"
+	"fmt"
+
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/google/uuid"
+)
+
+// OfferWaitlistSeatsHandler offers seats to customers on the waitlist when they are freed
+// by canceled bookings or by a bigger capacity of the show.
+// Seats of refunded tickets are offered by RecordRefundedTicketHandler, after the refund is recorded,
+// and seats freed by expired offers are offered again when the offer expires.
+type OfferWaitlistSeatsHandler struct {
+	waitlistRepo contracts.WaitlistRepository
+}
+
+func NewOfferWaitlistSeatsHandler(waitlistRepo contracts.WaitlistRepository) OfferWaitlistSeatsHandler {
+	return OfferWaitlistSeatsHandler{waitlistRepo: waitlistRepo}
+}
+
+func (h OfferWaitlistSeatsHandler) OnBookingCanceled(ctx context.Context, event *entities.BookingCanceled_v1) error {
+	return h.offer(ctx, event.ShowId)
+}
+
+func (h OfferWaitlistSeatsHandler) OnShowCapacityIncreased(ctx context.Context, event *entities.ShowCapacityIncreased_v1) error {
+	return h.offer(ctx, event.ShowID)
+}
+
+func (h OfferWaitlistSeatsHandler) offer(ctx context.Context, showID uuid.UUID) error {
+	if err := h.waitlistRepo.OfferFreedSeats(ctx, showID); err != nil {
+		return fmt.Errorf("failed to offer freed seats of show %s: %w", showID, err)
+	}
+
+	return nil
+}
@@ -3,25 +3,37 @@ package event_handlers
 import (
 	"context"
 	"fmt"
+	"html"
 	"tickets/entities"
 	"tickets/message/contracts"
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/log"
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/google/uuid"
 )
 
 type PrintTicketHandler struct {
-	filesAPI contracts.FilesAPI
-	eventBus *cqrs.EventBus
+	filesAPI    contracts.FilesAPI
+	bookingRepo contracts.BookingRepository
+	eventBus    *cqrs.EventBus
 }
 
-func NewPrintTicketHandler(filesAPI contracts.FilesAPI, eventBus *cqrs.EventBus) PrintTicketHandler {
-	return PrintTicketHandler{filesAPI: filesAPI, eventBus: eventBus}
+func NewPrintTicketHandler(
+	filesAPI contracts.FilesAPI,
+	bookingRepo contracts.BookingRepository,
+	eventBus *cqrs.EventBus,
+) PrintTicketHandler {
+	return PrintTicketHandler{filesAPI: filesAPI, bookingRepo: bookingRepo, eventBus: eventBus}
 }
 
 func (h PrintTicketHandler) Handle(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error {
 	log.FromContext(ctx).Info("Printing ticket")
 
+	seat, err := h.ticketSeat(ctx, event)
+	if err != nil {
+		return err
+	}
+
 	ticketHTML := `
 		<html>
 			<head>
@@ -29,14 +41,15 @@ func (h PrintTicketHandler) Handle(ctx context.Context, event *entities.TicketBo
 			</head>
 			<body>
 				<h1>Ticket ` + event.TicketID + `</h1>
-				<p>Price: ` + event.Price.Amount + ` ` + event.Price.Currency + `</p>	
+				<p>Price: ` + html.EscapeString(event.Price.String()) + `</p>	
+				` + seat + `
 			</body>
 		</html>
 `
 
 	ticketFile := event.TicketID + "-ticket.html"
 
-	err := h.filesAPI.UploadFile(ctx, ticketFile, ticketHTML)
+	err = h.filesAPI.UploadFile(ctx, ticketFile, ticketHTML)
 	if err != nil {
 		return fmt.Errorf("failed to upload ticket file: %w", err)
 	}
@@ -52,3 +65,29 @@ func (h PrintTicketHandler) Handle(ctx context.Context, event *entities.TicketBo
 
 	return nil
 }
+
+// ticketSeat returns the seat assigned to the ticket, tickets of shows without a seat map have none.
+func (h PrintTicketHandler) ticketSeat(ctx context.Context, event *entities.TicketBookingConfirmed_v1) (string, error) {
+	if event.BookingID == "" {
+		return "", nil
+	}
+
+	bookingID, err := uuid.Parse(event.BookingID)
+	if err != nil {
+		return "", fmt.Errorf("invalid booking ID %s of ticket %s: %w", event.BookingID, event.TicketID, err)
+	}
+	ticketID, err := uuid.Parse(event.TicketID)
+	if err != nil {
+		return "", fmt.Errorf("invalid ticket ID %s: %w", event.TicketID, err)
+	}
+
+	seat, ok, err := h.bookingRepo.AssignTicketSeat(ctx, bookingID, ticketID)
+	if err != nil {
+		return "", fmt.Errorf("failed to assign seat to ticket: %w", err)
+	}
+	if !ok {
+		return "", nil
+	}
+
+	return `<p>Seat: ` + html.EscapeString(seat.String()) + `</p>`, nil
+}
@@ -0,0 +1,45 @@
+package event_handlers
+
+import (
+	"context"
+	"fmt"
+
+	"tickets/entities"
+	"tickets/message/contracts"
+
+	"github.com/google/uuid"
+)
+
+// RecordRefundedTicketHandler counts refunded tickets of the booking and offers their seats to the waitlist.
+type RecordRefundedTicketHandler struct {
+	bookingRepo  contracts.BookingRepository
+	waitlistRepo contracts.WaitlistRepository
+}
+
+func NewRecordRefundedTicketHandler(
+	bookingRepo contracts.BookingRepository,
+	waitlistRepo contracts.WaitlistRepository,
+) RecordRefundedTicketHandler {
+	return RecordRefundedTicketHandler{
+		bookingRepo:  bookingRepo,
+		waitlistRepo: waitlistRepo,
+	}
+}
+
+func (h RecordRefundedTicketHandler) Handle(ctx context.Context, event *entities.TicketRefunded_v1) error {
+	booking, _, err := h.bookingRepo.AddRefundedTicket(ctx, event.TicketID)
+	if err != nil {
+		return fmt.Errorf("failed to add refunded ticket to booking: %w", err)
+	}
+
+	// the booking is returned for redelivered events too, so seats are offered even if the first offer failed
+	if booking.ShowID == uuid.Nil {
+		return nil
+	}
+
+	if err := h.waitlistRepo.OfferFreedSeats(ctx, booking.ShowID); err != nil {
+		return fmt.Errorf("failed to offer seats of refunded ticket %s: %w", event.TicketID, err)
+	}
+
+	return nil
+}
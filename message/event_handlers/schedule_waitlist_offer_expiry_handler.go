@@ -0,0 +1,27 @@
+package event_handlers
+
+import (
+	"context"
+	"fmt"
+
+	"tickets/entities"
+	"tickets/message/contracts"
+)
+
+// ScheduleWaitlistOfferExpiryHandler releases seats held for the waitlist offer if it isn't claimed in time.
+type ScheduleWaitlistOfferExpiryHandler struct {
+	scheduler contracts.Scheduler
+}
+
+func NewScheduleWaitlistOfferExpiryHandler(scheduler contracts.Scheduler) ScheduleWaitlistOfferExpiryHandler {
+	return ScheduleWaitlistOfferExpiryHandler{scheduler: scheduler}
+}
+
+func (h ScheduleWaitlistOfferExpiryHandler) Handle(ctx context.Context, event *entities.WaitlistSeatOffered_v1) error {
+	_, err := h.scheduler.SendCommandAt(ctx, entities.ExpireWaitlistOffer{EntryID: event.EntryID}, event.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to schedule ExpireWaitlistOffer command: %w", err)
+	}
+
+	return nil
+}
@@ -2,18 +2,31 @@ package event_handlers
 
 import (
 	"context"
+	"fmt"
 	"tickets/entities"
 	"tickets/message/contracts"
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/google/uuid"
 )
 
 type StoreTicketHandler struct {
-	repo contracts.TicketRepository
+	repo        contracts.TicketRepository
+	bookingRepo contracts.BookingRepository
+	commandBus  *cqrs.CommandBus
 }
 
-func NewStoreTicketHandler(repo contracts.TicketRepository) StoreTicketHandler {
-	return StoreTicketHandler{repo: repo}
+func NewStoreTicketHandler(
+	repo contracts.TicketRepository,
+	bookingRepo contracts.BookingRepository,
+	commandBus *cqrs.CommandBus,
+) StoreTicketHandler {
+	return StoreTicketHandler{
+		repo:        repo,
+		bookingRepo: bookingRepo,
+		commandBus:  commandBus,
+	}
 }
 
 func (h StoreTicketHandler) Handle(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error {
@@ -23,7 +36,45 @@ func (h StoreTicketHandler) Handle(ctx context.Context, event *entities.TicketBo
 		TicketID:      event.TicketID,
 		Price:         event.Price,
 		CustomerEmail: event.CustomerEmail,
+		BookingID:     event.BookingID,
+	}
+
+	if err := h.repo.Add(ctx, ticket); err != nil {
+		return err
+	}
+
+	// the booking is checked after the ticket is stored, so a ticket stored while the booking
+	// is being canceled is refunded either here or by the cancellation
+	return h.refundIfBookingCanceled(ctx, event)
+}
+
+func (h StoreTicketHandler) refundIfBookingCanceled(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error {
+	if event.BookingID == "" {
+		return nil
+	}
+
+	bookingID, err := uuid.Parse(event.BookingID)
+	if err != nil {
+		return fmt.Errorf("invalid booking ID %s of ticket %s: %w", event.BookingID, event.TicketID, err)
+	}
+
+	canceled, err := h.bookingRepo.IsCanceled(ctx, bookingID)
+	if err != nil {
+		return fmt.Errorf("failed to check booking %s: %w", bookingID, err)
+	}
+	if !canceled {
+		return nil
+	}
+
+	log.FromContext(ctx).
+		WithField("booking_id", bookingID).
+		WithField("ticket_id", event.TicketID).
+		Info("Refunding ticket confirmed after its booking was canceled")
+
+	err = h.commandBus.Send(ctx, entities.NewRefundTicketOfCanceledBooking(bookingID, event.TicketID))
+	if err != nil {
+		return fmt.Errorf("failed to send RefundTicket command: %w", err)
 	}
 
-	return h.repo.Add(ctx, ticket)
+	return nil
 }
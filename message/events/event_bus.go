@@ -3,6 +3,8 @@ package events
 import (
 	"fmt"
 	"tickets/entities"
+	"tickets/message/delay"
+	"tickets/message/ordering"
 
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -24,6 +26,11 @@ func NewEventBus(pub message.Publisher) *cqrs.EventBus {
 
 				return "events", nil
 			},
+			OnPublish: func(params cqrs.OnEventSendParams) error {
+				ordering.SetMessageKey(params.Message, params.Event)
+				delay.SetMessageMetadata(params.Message)
+				return nil
+			},
 			Marshaler: cqrs.JSONMarshaler{
 				GenerateName: cqrs.StructName,
 			},
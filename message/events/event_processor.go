@@ -1,9 +1,11 @@
 package events
 
 import (
-	"tickets/db/read_model"
+	"fmt"
 	"tickets/message/contracts"
 	"tickets/message/event_handlers"
+	"tickets/message/inbox"
+	"tickets/message/ordering"
 	"tickets/process_manager"
 
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
@@ -12,16 +14,21 @@ import (
 func AddEventProcessorHandlers(
 	ep *cqrs.EventProcessor,
 	eventBus *cqrs.EventBus,
+	commandBus *cqrs.CommandBus,
 	receiptsService contracts.ReceiptsService,
 	spreadsheetsService contracts.SpreadsheetsAPI,
 	ticketRepo contracts.TicketRepository,
 	showRepo contracts.ShowRepository,
+	bookingRepo contracts.BookingRepository,
+	waitlistRepo contracts.WaitlistRepository,
 	filesAPI contracts.FilesAPI,
 	deadNationAPI contracts.DeadNationApi,
-	opsReadModel read_model.OpsBookingReadModel,
-	vipBundlePM *process_manager.VipBundleProcessManager,
+	scheduler contracts.Scheduler,
+	handlersInbox inbox.Inbox,
 ) {
-	ep.AddHandlers(
+	offerWaitlistSeatsHandler := event_handlers.NewOfferWaitlistSeatsHandler(waitlistRepo)
+
+	handlers := []cqrs.EventHandler{
 		cqrs.NewEventHandler(
 			"IssueReceipt",
 			event_handlers.NewIssueReceiptsHandler(receiptsService, eventBus).Handle,
@@ -36,7 +43,7 @@ func AddEventProcessorHandlers(
 		),
 		cqrs.NewEventHandler(
 			"StoreTicket",
-			event_handlers.NewStoreTicketHandler(ticketRepo).Handle,
+			event_handlers.NewStoreTicketHandler(ticketRepo, bookingRepo, commandBus).Handle,
 		),
 		cqrs.NewEventHandler(
 			"RemoveCanceledTicket",
@@ -44,65 +51,102 @@ func AddEventProcessorHandlers(
 		),
 		cqrs.NewEventHandler(
 			"PrintTicket",
-			event_handlers.NewPrintTicketHandler(filesAPI, eventBus).Handle,
+			event_handlers.NewPrintTicketHandler(filesAPI, bookingRepo, eventBus).Handle,
 		),
 		cqrs.NewEventHandler(
 			"BookPlaceInDeadNation",
-			event_handlers.NewBookingMadeHandler(deadNationAPI, showRepo).Handle,
-		),
-		// read model
-		cqrs.NewEventHandler(
-			"ops_read_model.OnBookingMade",
-			opsReadModel.OnBookingMade,
-		),
-		cqrs.NewEventHandler(
-			"ops_read_model.OnTicketReceiptIssued",
-			opsReadModel.OnTicketReceiptIssued,
-		),
-		cqrs.NewEventHandler(
-			"ops_read_model.OnTicketBookingConfirmed",
-			opsReadModel.OnTicketBookingConfirmed,
-		),
-		cqrs.NewEventHandler(
-			"ops_read_model.OnTicketPrinted",
-			opsReadModel.OnTicketPrinted,
-		),
-		cqrs.NewEventHandler(
-			"ops_read_model.OnTicketRefunded",
-			opsReadModel.OnTicketRefunded,
+			event_handlers.NewBookingMadeHandler(deadNationAPI, showRepo, bookingRepo, eventBus).Handle,
 		),
-		// process manager
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnVipBundleInitialized",
-			vipBundlePM.OnVipBundleInitialized,
+			"CancelBookingRejectedByDeadNation",
+			event_handlers.NewCancelBookingRejectedByDeadNationHandler(commandBus).Handle,
 		),
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnBookingMade",
-			vipBundlePM.OnBookingMade,
+			"CancelShowBookings",
+			event_handlers.NewCancelShowBookingsHandler(bookingRepo, commandBus).Handle,
 		),
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnBookingFailed",
-			vipBundlePM.OnBookingFailed,
-		),
-		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnTicketBookingConfirmed",
-			vipBundlePM.OnTicketBookingConfirmed,
+			"ScheduleWaitlistOfferExpiry",
+			event_handlers.NewScheduleWaitlistOfferExpiryHandler(scheduler).Handle,
 		),
+	}
+
+	for _, handler := range handlers {
+		ep.AddHandlers(handlersInbox.EventHandler(handler))
+	}
+
+	// free seats are counted by the waitlist only in serializable transactions
+	serializableHandlers := []cqrs.EventHandler{
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnFlightBooked",
-			vipBundlePM.OnFlightBooked,
+			"RecordRefundedTicket",
+			event_handlers.NewRecordRefundedTicketHandler(bookingRepo, waitlistRepo).Handle,
 		),
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnFlightBookingFailed",
-			vipBundlePM.OnFlightBookingFailed,
+			"CancelInDeadNation",
+			event_handlers.NewCancelInDeadNationHandler(deadNationAPI, bookingRepo).Handle,
 		),
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnTaxiBooked",
-			vipBundlePM.OnTaxiBooked,
+			"OfferWaitlistSeatsOnBookingCanceled",
+			offerWaitlistSeatsHandler.OnBookingCanceled,
 		),
 		cqrs.NewEventHandler(
-			"vip_bundle_process_manager.OnTaxiBookingFailed",
-			vipBundlePM.OnTaxiBookingFailed,
+			"OfferWaitlistSeatsOnShowCapacityIncreased",
+			offerWaitlistSeatsHandler.OnShowCapacityIncreased,
 		),
-	)
+	}
+
+	for _, handler := range serializableHandlers {
+		ep.AddHandlers(handlersInbox.Serializable().EventHandler(handler))
+	}
+}
+
+// AddEventGroupProcessorHandlers registers handlers which require events of a booking to be handled in order.
+// Each partition is consumed by its own group, so different bookings are handled in parallel.
+// The order holds only for a single consumer of each partition, see ordering.Partitions.
+func AddEventGroupProcessorHandlers(
+	egp *cqrs.EventGroupProcessor,
+	opsReadModel contracts.OpsBookingReadModel,
+	vipBundlePM *process_manager.VipBundleProcessManager,
+	handlersInbox inbox.Inbox,
+) error {
+	// VIP bundles are updated in serializable transactions
+	pmInbox := handlersInbox.Serializable()
+
+	for partition := 0; partition < ordering.Partitions; partition++ {
+		if err := egp.AddHandlersGroup(
+			ordering.GroupName("ops_read_model", partition),
+			handlersInbox.GroupEventHandler("ops_read_model.OnBookingMade", cqrs.NewGroupEventHandler(opsReadModel.OnBookingMade)),
+			handlersInbox.GroupEventHandler("ops_read_model.OnTicketReceiptIssued", cqrs.NewGroupEventHandler(opsReadModel.OnTicketReceiptIssued)),
+			handlersInbox.GroupEventHandler("ops_read_model.OnTicketBookingConfirmed", cqrs.NewGroupEventHandler(opsReadModel.OnTicketBookingConfirmed)),
+			handlersInbox.GroupEventHandler("ops_read_model.OnTicketPrinted", cqrs.NewGroupEventHandler(opsReadModel.OnTicketPrinted)),
+			handlersInbox.GroupEventHandler("ops_read_model.OnTicketRefunded", cqrs.NewGroupEventHandler(opsReadModel.OnTicketRefunded)),
+			handlersInbox.GroupEventHandler("ops_read_model.OnBookingCanceled", cqrs.NewGroupEventHandler(opsReadModel.OnBookingCanceled)),
+		); err != nil {
+			return fmt.Errorf("could not add ops read model handlers: %w", err)
+		}
+
+		if err := egp.AddHandlersGroup(
+			ordering.GroupName("vip_bundle_process_manager", partition),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnVipBundleInitialized", cqrs.NewGroupEventHandler(vipBundlePM.OnVipBundleInitialized)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnBookingMade", cqrs.NewGroupEventHandler(vipBundlePM.OnBookingMade)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnBookingFailed", cqrs.NewGroupEventHandler(vipBundlePM.OnBookingFailed)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnTicketBookingConfirmed", cqrs.NewGroupEventHandler(vipBundlePM.OnTicketBookingConfirmed)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnFlightBooked", cqrs.NewGroupEventHandler(vipBundlePM.OnFlightBooked)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnFlightBookingFailed", cqrs.NewGroupEventHandler(vipBundlePM.OnFlightBookingFailed)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnHotelBooked", cqrs.NewGroupEventHandler(vipBundlePM.OnHotelBooked)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnHotelBookingFailed", cqrs.NewGroupEventHandler(vipBundlePM.OnHotelBookingFailed)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnHotelBookingCanceled", cqrs.NewGroupEventHandler(vipBundlePM.OnHotelBookingCanceled)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnTaxiBooked", cqrs.NewGroupEventHandler(vipBundlePM.OnTaxiBooked)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnTaxiBookingFailed", cqrs.NewGroupEventHandler(vipBundlePM.OnTaxiBookingFailed)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnStepTimedOut", cqrs.NewGroupEventHandler(vipBundlePM.OnStepTimedOut)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnCancellationRequested", cqrs.NewGroupEventHandler(vipBundlePM.OnCancellationRequested)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnTicketRefunded", cqrs.NewGroupEventHandler(vipBundlePM.OnTicketRefunded)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnFlightTicketsCanceled", cqrs.NewGroupEventHandler(vipBundlePM.OnFlightTicketsCanceled)),
+			pmInbox.GroupEventHandler("vip_bundle_process_manager.OnTaxiBookingCanceled", cqrs.NewGroupEventHandler(vipBundlePM.OnTaxiBookingCanceled)),
+		); err != nil {
+			return fmt.Errorf("could not add vip bundle process manager handlers: %w", err)
+		}
+	}
+
+	return nil
 }
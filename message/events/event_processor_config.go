@@ -3,19 +3,21 @@ package events
 import (
 	"fmt"
 	"tickets/entities"
+	"tickets/message/ordering"
 
 	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/redis/go-redis/v9"
 )
 
 var Marshaler = cqrs.JSONMarshaler{
 	GenerateName: cqrs.StructName,
 }
 
-func NewEventProcessorConfig(rdb *redis.Client, logger watermill.LoggerAdapter) cqrs.EventProcessorConfig {
+func NewEventProcessorConfig(
+	newSubscriber func(consumerGroup string) (message.Subscriber, error),
+	logger watermill.LoggerAdapter,
+) cqrs.EventProcessorConfig {
 	return cqrs.EventProcessorConfig{
 		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
 			handlerEvent := params.EventHandler.NewEvent()
@@ -31,15 +33,29 @@ func NewEventProcessorConfig(rdb *redis.Client, logger watermill.LoggerAdapter)
 			return "events." + params.EventName, nil
 		},
 		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
-			return redisstream.NewSubscriber(
-				redisstream.SubscriberConfig{
-					Client:        rdb,
-					ConsumerGroup: "svc-tickets.events." + params.HandlerName,
-				},
-				logger,
-			)
+			return newSubscriber("svc-tickets.events." + params.HandlerName)
 		},
 		Marshaler: Marshaler,
 		Logger:    logger,
 	}
 }
+
+// NewEventGroupProcessorConfig configures ordered consumers.
+// Every handlers group consumes a single partition of ordered events (see ordering.GroupName).
+func NewEventGroupProcessorConfig(
+	newSubscriber func(consumerGroup string) (message.Subscriber, error),
+	logger watermill.LoggerAdapter,
+) cqrs.EventGroupProcessorConfig {
+	return cqrs.EventGroupProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+			return ordering.GroupTopic(params.EventGroupName)
+		},
+		SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return newSubscriber("svc-tickets.events." + params.EventGroupName)
+		},
+		// partition contains all events, group handles only some of them
+		AckOnUnknownEvent: true,
+		Marshaler:         Marshaler,
+		Logger:            logger,
+	}
+}
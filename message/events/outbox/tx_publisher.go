@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"fmt"
+	"tickets/db/util"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// TxPublisher stores messages in the outbox of the transaction carried by the message context,
+// so they are forwarded only if the transaction commits.
+// Messages sent without a transaction are published right away by the wrapped publisher.
+type TxPublisher struct {
+	pub message.Publisher
+}
+
+func NewTxPublisher(pub message.Publisher) TxPublisher {
+	if pub == nil {
+		panic("publisher is nil")
+	}
+
+	return TxPublisher{pub: pub}
+}
+
+func (p TxPublisher) Publish(topic string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		tx, ok := util.TxFromContext(msg.Context())
+		if !ok {
+			if err := p.pub.Publish(topic, msg); err != nil {
+				return err
+			}
+			continue
+		}
+
+		outboxPublisher, err := NewPublisherForDb(msg.Context(), tx)
+		if err != nil {
+			return fmt.Errorf("could not create outbox publisher: %w", err)
+		}
+
+		if err := outboxPublisher.Publish(topic, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p TxPublisher) Close() error {
+	return p.pub.Close()
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"tickets/entities"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Upcaster transforms the payload of an event from version N to version N+1.
+type Upcaster func(payload []byte) ([]byte, error)
+
+// UnchangedPayload can be used when the new version only adds fields,
+// and zero values are fine for events stored before.
+func UnchangedPayload(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+var versionedEventName = regexp.MustCompile(`^(.+)_v(\d+)$`)
+
+// EventRegistry knows the current version of each event and how to upcast older payloads to it.
+// Upcasters are chained, so adding a new version requires registering only the upcaster from the previous one.
+type EventRegistry struct {
+	currentVersions map[string]int
+	upcasters       map[string]map[int]Upcaster
+}
+
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		currentVersions: map[string]int{},
+		upcasters:       map[string]map[int]Upcaster{},
+	}
+}
+
+// RegisterEvent declares event's version as the current one.
+func (r *EventRegistry) RegisterEvent(event entities.Event) {
+	baseName, version, ok := parseEventName(Marshaler.Name(event))
+	if !ok {
+		panic(fmt.Sprintf("event %T has no version suffix", event))
+	}
+
+	r.currentVersions[baseName] = version
+}
+
+// RegisterUpcaster registers upcaster from eventName (for example BookingMade_v0) to the next version.
+func (r *EventRegistry) RegisterUpcaster(eventName string, upcaster Upcaster) {
+	baseName, version, ok := parseEventName(eventName)
+	if !ok {
+		panic(fmt.Sprintf("event name %s has no version suffix", eventName))
+	}
+
+	if _, ok := r.upcasters[baseName]; !ok {
+		r.upcasters[baseName] = map[int]Upcaster{}
+	}
+
+	r.upcasters[baseName][version] = upcaster
+}
+
+// Upcast returns the name and the payload of the current version of the event.
+// Events which are not registered are returned unchanged.
+func (r *EventRegistry) Upcast(eventName string, payload []byte) (string, []byte, error) {
+	baseName, version, ok := parseEventName(eventName)
+	if !ok {
+		return eventName, payload, nil
+	}
+
+	currentVersion, ok := r.currentVersions[baseName]
+	if !ok {
+		return eventName, payload, nil
+	}
+
+	if version > currentVersion {
+		return "", nil, fmt.Errorf("event %s is newer than the current version %d", eventName, currentVersion)
+	}
+
+	for ; version < currentVersion; version++ {
+		upcaster, ok := r.upcasters[baseName][version]
+		if !ok {
+			return "", nil, fmt.Errorf("missing upcaster from %s_v%d to v%d", baseName, version, version+1)
+		}
+
+		var err error
+		payload, err = upcaster(payload)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not upcast %s_v%d: %w", baseName, version, err)
+		}
+	}
+
+	return eventNameWithVersion(baseName, currentVersion), payload, nil
+}
+
+// UpcastMessage returns a message with the current version of the event.
+// If the event is already in the current version, msg is returned.
+func (r *EventRegistry) UpcastMessage(msg *message.Message) (*message.Message, error) {
+	eventName := Marshaler.NameFromMessage(msg)
+
+	upcastedName, upcastedPayload, err := r.Upcast(eventName, msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if upcastedName == eventName {
+		return msg, nil
+	}
+
+	upcastedMsg := msg.Copy()
+	upcastedMsg.Payload = upcastedPayload
+	// the same key is used by cqrs.JSONMarshaler
+	upcastedMsg.Metadata.Set("name", upcastedName)
+	upcastedMsg.SetContext(msg.Context())
+
+	return upcastedMsg, nil
+}
+
+func parseEventName(eventName string) (string, int, bool) {
+	matches := versionedEventName.FindStringSubmatch(eventName)
+	if matches == nil {
+		return "", 0, false
+	}
+
+	version, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return matches[1], version, true
+}
+
+func eventNameWithVersion(baseName string, version int) string {
+	return baseName + "_v" + strconv.Itoa(version)
+}
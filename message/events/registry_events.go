@@ -0,0 +1,49 @@
+package events
+
+import "tickets/entities"
+
+// NewRegistry returns a registry of all events of the service.
+// It should be used by everything reading stored or received events:
+// data lake readers, read model migrations and consumers of the events topic.
+func NewRegistry() *EventRegistry {
+	r := NewEventRegistry()
+
+	r.RegisterEvent(entities.TicketBookingConfirmed_v1{})
+	r.RegisterEvent(entities.TicketBookingCanceled_v1{})
+	r.RegisterEvent(entities.TicketRefunded_v1{})
+	r.RegisterEvent(entities.TicketPrinted_v1{})
+	r.RegisterEvent(entities.TicketReceiptIssued_v1{})
+	r.RegisterEvent(entities.BookingMade_v1{})
+	r.RegisterEvent(entities.BookingCanceled_v1{})
+	r.RegisterEvent(entities.DeadNationBookingFailed_v1{})
+	r.RegisterEvent(entities.ShowRescheduled_v1{})
+	r.RegisterEvent(entities.ShowCanceled_v1{})
+	r.RegisterEvent(entities.ShowCapacityIncreased_v1{})
+	r.RegisterEvent(entities.WaitlistSeatOffered_v1{})
+	r.RegisterEvent(entities.VipBundleInitialized_v1{})
+	r.RegisterEvent(entities.BookingFailed_v1{})
+	r.RegisterEvent(entities.FlightBooked_v1{})
+	r.RegisterEvent(entities.FlightBookingFailed_v1{})
+	r.RegisterEvent(entities.TaxiBooked_v1{})
+	r.RegisterEvent(entities.TaxiBookingFailed_v1{})
+	r.RegisterEvent(entities.VipBundleFinalized_v1{})
+	r.RegisterEvent(entities.VipBundleStepTimedOut_v1{})
+	r.RegisterEvent(entities.VipBundleCancellationRequested_v1{})
+	r.RegisterEvent(entities.VipBundleFailed_v1{})
+	r.RegisterEvent(entities.FlightTicketsCanceled_v1{})
+	r.RegisterEvent(entities.TaxiBookingCanceled_v1{})
+	r.RegisterEvent(entities.HotelBooked_v1{})
+	r.RegisterEvent(entities.HotelBookingFailed_v1{})
+	r.RegisterEvent(entities.HotelBookingCanceled_v1{})
+
+	// v1 added dead_nation_id, which is empty for older bookings
+	r.RegisterUpcaster("BookingMade_v0", UnchangedPayload)
+
+	// payloads of these events are the same in v0 and v1, only the event name changed
+	r.RegisterUpcaster("TicketBookingConfirmed_v0", UnchangedPayload)
+	r.RegisterUpcaster("TicketReceiptIssued_v0", UnchangedPayload)
+	r.RegisterUpcaster("TicketPrinted_v0", UnchangedPayload)
+	r.RegisterUpcaster("TicketRefunded_v0", UnchangedPayload)
+
+	return r
+}
@@ -0,0 +1,84 @@
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+	"tickets/entities"
+	"tickets/message/events"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent_v2 struct {
+	Header entities.EventHeader `json:"header"`
+
+	FullName string `json:"full_name"`
+}
+
+func (e testEvent_v2) IsInternal() bool {
+	return false
+}
+
+func (e testEvent_v2) GetHeader() entities.EventHeader {
+	return e.Header
+}
+
+func TestEventRegistry_Upcast(t *testing.T) {
+	registry := events.NewEventRegistry()
+	registry.RegisterEvent(testEvent_v2{})
+
+	registry.RegisterUpcaster("testEvent_v0", events.UnchangedPayload)
+	registry.RegisterUpcaster("testEvent_v1", func(payload []byte) ([]byte, error) {
+		var v1 map[string]any
+		if err := json.Unmarshal(payload, &v1); err != nil {
+			return nil, err
+		}
+
+		v1["full_name"] = v1["first_name"].(string) + " " + v1["last_name"].(string)
+
+		return json.Marshal(v1)
+	})
+
+	payload := []byte(`{"first_name": "John", "last_name": "Doe"}`)
+
+	for _, eventName := range []string{"testEvent_v0", "testEvent_v1"} {
+		t.Run(eventName, func(t *testing.T) {
+			upcastedName, upcastedPayload, err := registry.Upcast(eventName, payload)
+			require.NoError(t, err)
+
+			assert.Equal(t, "testEvent_v2", upcastedName)
+
+			var event testEvent_v2
+			require.NoError(t, json.Unmarshal(upcastedPayload, &event))
+			assert.Equal(t, "John Doe", event.FullName)
+		})
+	}
+
+	t.Run("current_version", func(t *testing.T) {
+		currentPayload := []byte(`{"full_name": "John Doe"}`)
+
+		upcastedName, upcastedPayload, err := registry.Upcast("testEvent_v2", currentPayload)
+		require.NoError(t, err)
+
+		assert.Equal(t, "testEvent_v2", upcastedName)
+		assert.Equal(t, currentPayload, upcastedPayload)
+	})
+
+	t.Run("unknown_event", func(t *testing.T) {
+		upcastedName, upcastedPayload, err := registry.Upcast("OtherEvent_v0", payload)
+		require.NoError(t, err)
+
+		assert.Equal(t, "OtherEvent_v0", upcastedName)
+		assert.Equal(t, payload, upcastedPayload)
+	})
+
+	t.Run("missing_upcaster", func(t *testing.T) {
+		registry := events.NewEventRegistry()
+		registry.RegisterEvent(testEvent_v2{})
+		registry.RegisterUpcaster("testEvent_v1", events.UnchangedPayload)
+
+		_, _, err := registry.Upcast("testEvent_v0", payload)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,147 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"tickets/db/util"
+	"tickets/entities"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+// Inbox deduplicates handlers by storing handled messages in Postgres.
+// The message is marked as handled in the same transaction in which the handler runs,
+// so repositories using util.UpdateInTx or util.Executor commit together with the inbox entry.
+// A duplicate delivered concurrently waits for the first transaction and is skipped once it commits.
+type Inbox struct {
+	db *sqlx.DB
+
+	// isolation must be at least the isolation required by repositories of the handlers,
+	// util.UpdateInTx doesn't join weaker transactions
+	isolation sql.IsolationLevel
+}
+
+func NewInbox(db *sqlx.DB) Inbox {
+	if db == nil {
+		panic("db is nil")
+	}
+
+	return Inbox{db: db, isolation: sql.LevelRepeatableRead}
+}
+
+// Serializable returns the inbox for handlers with repositories requiring serializable transactions,
+// like the waitlist counting free seats or the VIP bundle process manager.
+func (i Inbox) Serializable() Inbox {
+	i.isolation = sql.LevelSerializable
+	return i
+}
+
+func (i Inbox) EventHandler(handler cqrs.EventHandler) cqrs.EventHandler {
+	return eventHandler{EventHandler: handler, inbox: i}
+}
+
+// GroupEventHandler wraps handler of cqrs.EventGroupProcessor. Group handlers have no names,
+// so handlerName is used to deduplicate events.
+func (i Inbox) GroupEventHandler(handlerName string, handler cqrs.GroupEventHandler) cqrs.GroupEventHandler {
+	return groupEventHandler{GroupEventHandler: handler, handlerName: handlerName, inbox: i}
+}
+
+func (i Inbox) CommandHandler(handler cqrs.CommandHandler) cqrs.CommandHandler {
+	return commandHandler{CommandHandler: handler, inbox: i}
+}
+
+func (i Inbox) handleOnce(
+	ctx context.Context,
+	handlerName string,
+	messageID string,
+	handle func(ctx context.Context) error,
+) error {
+	if messageID == "" {
+		return fmt.Errorf("message for handler %s has no deduplication key", handlerName)
+	}
+
+	return util.UpdateInTx(
+		ctx,
+		i.db,
+		i.isolation,
+		func(ctx context.Context, tx *sqlx.Tx) error {
+			var stored bool
+			err := tx.GetContext(ctx, &stored, `
+				INSERT INTO
+					inbox (handler_name, message_id, handled_at)
+				VALUES
+					($1, $2, now())
+				ON CONFLICT DO NOTHING
+				RETURNING
+					true
+			`, handlerName, messageID)
+			if errors.Is(err, sql.ErrNoRows) {
+				log.FromContext(ctx).WithFields(logrus.Fields{
+					"handler":    handlerName,
+					"message_id": messageID,
+				}).Info("Message already handled, skipping")
+
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not store message in inbox: %w", err)
+			}
+
+			return handle(ctx)
+		},
+	)
+}
+
+type eventHandler struct {
+	cqrs.EventHandler
+	inbox Inbox
+}
+
+func (h eventHandler) Handle(ctx context.Context, event any) error {
+	e, ok := event.(entities.Event)
+	if !ok {
+		return fmt.Errorf("invalid event type: %T doesn't implement entities.Event", event)
+	}
+
+	return h.inbox.handleOnce(ctx, h.HandlerName(), e.GetHeader().DeduplicationKey(), func(ctx context.Context) error {
+		return h.EventHandler.Handle(ctx, event)
+	})
+}
+
+type groupEventHandler struct {
+	cqrs.GroupEventHandler
+	handlerName string
+	inbox       Inbox
+}
+
+func (h groupEventHandler) Handle(ctx context.Context, event any) error {
+	e, ok := event.(entities.Event)
+	if !ok {
+		return fmt.Errorf("invalid event type: %T doesn't implement entities.Event", event)
+	}
+
+	return h.inbox.handleOnce(ctx, h.handlerName, e.GetHeader().DeduplicationKey(), func(ctx context.Context) error {
+		return h.GroupEventHandler.Handle(ctx, event)
+	})
+}
+
+type commandHandler struct {
+	cqrs.CommandHandler
+	inbox Inbox
+}
+
+func (h commandHandler) Handle(ctx context.Context, cmd any) error {
+	c, ok := cmd.(entities.Command)
+	if !ok {
+		return fmt.Errorf("invalid command type: %T doesn't implement entities.Command", cmd)
+	}
+
+	return h.inbox.handleOnce(ctx, h.HandlerName(), c.DeduplicationKey(), func(ctx context.Context) error {
+		return h.CommandHandler.Handle(ctx, cmd)
+	})
+}
@@ -0,0 +1,63 @@
+package inbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"tickets/db"
+	"tickets/entities"
+	"tickets/message/inbox"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInbox_EventHandler_skips_duplicates(t *testing.T) {
+	ctx := context.Background()
+
+	dbConn, err := sqlx.Open("postgres", os.Getenv("POSTGRES_URL"))
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	err = db.InitializeDatabaseSchema(dbConn)
+	require.NoError(t, err)
+
+	calls := 0
+	shouldFail := true
+
+	handler := inbox.NewInbox(dbConn).EventHandler(cqrs.NewEventHandler(
+		"inbox_test",
+		func(ctx context.Context, event *entities.TicketPrinted_v1) error {
+			calls++
+
+			if shouldFail {
+				return errors.New("failed")
+			}
+
+			return nil
+		},
+	))
+
+	event := &entities.TicketPrinted_v1{
+		Header:   entities.NewEventHeader(),
+		TicketID: "ticket-id",
+		FileName: "ticket-id-ticket.html",
+	}
+
+	// failed handling should not mark message as handled
+	err = handler.Handle(ctx, event)
+	require.Error(t, err)
+
+	shouldFail = false
+
+	for i := 0; i < 3; i++ {
+		err = handler.Handle(ctx, event)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, calls)
+}
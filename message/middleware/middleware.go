@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"tickets/message/ordering"
 	"time"
 
 	"github.com/ThreeDotsLabs/go-event-driven/common/log"
@@ -28,6 +29,16 @@ func CorrelationIDMiddleware(next message.HandlerFunc) message.HandlerFunc {
 	}
 }
 
+func OrderingKeyMiddleware(next message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		if key := msg.Metadata.Get(ordering.MetadataKey); key != "" {
+			msg.SetContext(ordering.ContextWithKey(msg.Context(), key))
+		}
+
+		return next(msg)
+	}
+}
+
 func LoggingMiddleware(next message.HandlerFunc) message.HandlerFunc {
 	return func(msg *message.Message) ([]*message.Message, error) {
 		logger := log.FromContext(msg.Context()).WithFields(logrus.Fields{
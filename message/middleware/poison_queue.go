@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+	"time"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// PoisonQueueMiddleware should be added before RetryMiddleware: when all retries are exhausted,
+// the message is parked in the dead letter store and acked, so it doesn't block the consumer group.
+func PoisonQueueMiddleware(deadLetters contracts.DeadLetterRepository, retryCount int) message.HandlerMiddleware {
+	return func(next message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			msgs, err := next(msg)
+			if err == nil {
+				return msgs, nil
+			}
+
+			metadata := make(map[string]string, len(msg.Metadata))
+			for k, v := range msg.Metadata {
+				metadata[k] = v
+			}
+
+			deadLetter := entities.DeadLetter{
+				DeadLetterID: uuid.New(),
+				MessageUUID:  msg.UUID,
+				Topic:        message.SubscribeTopicFromCtx(msg.Context()),
+				HandlerName:  message.HandlerNameFromCtx(msg.Context()),
+				Payload:      msg.Payload,
+				Metadata:     metadata,
+				Error:        err.Error(),
+				RetryCount:   retryCount,
+				FailedAt:     time.Now().UTC(),
+			}
+
+			if storeErr := deadLetters.Add(msg.Context(), deadLetter); storeErr != nil {
+				return nil, errors.Join(err, fmt.Errorf("could not move message to dead letter store: %w", storeErr))
+			}
+
+			log.FromContext(msg.Context()).WithFields(logrus.Fields{
+				"message_id":     msg.UUID,
+				"dead_letter_id": deadLetter.DeadLetterID,
+				"handler":        deadLetter.HandlerName,
+				"topic":          deadLetter.Topic,
+			}).WithError(err).Error("Message moved to dead letter store")
+
+			return nil, nil
+		}
+	}
+}
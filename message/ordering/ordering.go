@@ -0,0 +1,94 @@
+package ordering
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"tickets/entities"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Partitions is the number of ordered events streams.
+// Events with the same ordering key always land in the same partition, so they are handled in the publish order,
+// while different keys are handled in parallel.
+// Changing it re-shuffles keys between partitions, so it should be done when there are no in-flight events.
+//
+// The order is kept only when the events topic and each partition are consumed by one consumer at a time.
+// Consumer groups load-balance messages between all consumers of the group, so with more than one replica
+// of the service on Redis Streams, events of the same key can be partitioned and handled in parallel.
+// Only a single replica should consume the ordered streams until partitions are assigned to replicas explicitly.
+// GoChannel delivers messages concurrently, so it doesn't keep the order at all.
+const Partitions = 8
+
+const MetadataKey = "ordering_key"
+
+const partitionGroupSeparator = ".partition-"
+
+type contextKey struct{}
+
+func ContextWithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(contextKey{}).(string)
+	return key
+}
+
+// SetMessageKey sets the ordering key of the published event or command.
+// Messages which don't know their key (for example FlightBooked_v1) inherit it from the message being handled,
+// so all messages of one booking share the key.
+func SetMessageKey(msg *message.Message, v any) {
+	var key string
+	if orderedEvent, ok := v.(entities.OrderedEvent); ok {
+		key = orderedEvent.OrderingKey()
+	}
+	if key == "" {
+		key = KeyFromContext(msg.Context())
+	}
+
+	if key != "" {
+		msg.Metadata.Set(MetadataKey, key)
+	}
+}
+
+// PartitionTopic returns the ordered events topic for the message.
+// Messages without an ordering key are spread across partitions.
+func PartitionTopic(msg *message.Message) string {
+	key := msg.Metadata.Get(MetadataKey)
+	if key == "" {
+		key = msg.UUID
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+
+	return partitionTopic(int(hash.Sum32() % Partitions))
+}
+
+// GroupName returns the name of the handlers group consuming the partition.
+func GroupName(consumer string, partition int) string {
+	return consumer + partitionGroupSeparator + strconv.Itoa(partition)
+}
+
+// GroupTopic returns the partition topic consumed by the handlers group created with GroupName.
+func GroupTopic(groupName string) (string, error) {
+	idx := strings.LastIndex(groupName, partitionGroupSeparator)
+	if idx == -1 {
+		return "", fmt.Errorf("group %s is not a partition group", groupName)
+	}
+
+	partition, err := strconv.Atoi(groupName[idx+len(partitionGroupSeparator):])
+	if err != nil {
+		return "", fmt.Errorf("invalid partition in group %s: %w", groupName, err)
+	}
+
+	return partitionTopic(partition), nil
+}
+
+func partitionTopic(partition int) string {
+	return "events-ordered.partition-" + strconv.Itoa(partition)
+}
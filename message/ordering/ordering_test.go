@@ -0,0 +1,44 @@
+package ordering_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"tickets/entities"
+	"tickets/message/ordering"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMessageKey(t *testing.T) {
+	bookingID := uuid.New()
+
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	ordering.SetMessageKey(msg, entities.BookingMade_v1{BookingID: bookingID})
+	assert.Equal(t, bookingID.String(), msg.Metadata.Get(ordering.MetadataKey))
+
+	inheritedMsg := message.NewMessage(watermill.NewUUID(), nil)
+	inheritedMsg.SetContext(ordering.ContextWithKey(context.Background(), bookingID.String()))
+	ordering.SetMessageKey(inheritedMsg, entities.FlightBooked_v1{})
+	assert.Equal(t, bookingID.String(), inheritedMsg.Metadata.Get(ordering.MetadataKey))
+	assert.Equal(t, ordering.PartitionTopic(msg), ordering.PartitionTopic(inheritedMsg))
+
+	unorderedMsg := message.NewMessage(watermill.NewUUID(), nil)
+	ordering.SetMessageKey(unorderedMsg, entities.FlightBooked_v1{})
+	assert.Empty(t, unorderedMsg.Metadata.Get(ordering.MetadataKey))
+}
+
+func TestGroupTopic(t *testing.T) {
+	for partition := 0; partition < ordering.Partitions; partition++ {
+		topic, err := ordering.GroupTopic(ordering.GroupName("ops_read_model", partition))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("events-ordered.partition-%d", partition), topic)
+	}
+
+	_, err := ordering.GroupTopic("ops_read_model")
+	assert.Error(t, err)
+}
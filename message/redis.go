@@ -19,9 +19,10 @@ func NewRedisPublisher(rdb *redis.Client, watermillLogger watermill.LoggerAdapte
 	return pub
 }
 
-func NewRedisSubscriber(rdb *redis.Client, watermillLogger watermill.LoggerAdapter) message.Subscriber {
+func NewRedisSubscriber(rdb *redis.Client, consumerGroup string, watermillLogger watermill.LoggerAdapter) message.Subscriber {
 	sub, err := redisstream.NewSubscriber(redisstream.SubscriberConfig{
-		Client: rdb,
+		Client:        rdb,
+		ConsumerGroup: consumerGroup,
 	}, watermillLogger)
 	if err != nil {
 		panic(err)
@@ -7,6 +7,8 @@ import (
 	"tickets/message/events"
 	"tickets/message/events/outbox"
 	"tickets/message/middleware"
+	"tickets/message/ordering"
+	"tickets/message/scheduler"
 	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
@@ -15,10 +17,12 @@ import (
 )
 
 func NewWatermillRouter(
+	eventRegistry *events.EventRegistry,
 	dataLake contracts.DataLake,
+	deadLetters contracts.DeadLetterRepository,
 	postgresSubscriber message.Subscriber,
-	redisPublisher message.Publisher,
-	redisSubscriber message.Subscriber,
+	scheduledMessagesSubscriber *scheduler.Subscriber,
+	broker Broker,
 	logger watermill.LoggerAdapter,
 ) *message.Router {
 	router, err := message.NewRouter(message.RouterConfig{}, logger)
@@ -26,33 +30,78 @@ func NewWatermillRouter(
 		panic(err)
 	}
 
+	retryMiddleware := middleware.RetryMiddleware(logger)
+
+	// the first middleware is the outermost one, Recoverer is the innermost,
+	// so a panic becomes an error which is retried and then parked like any other
 	router.AddMiddleware(
-		watermillMiddleware.Recoverer,
-		middleware.RetryMiddleware(logger).Middleware,
+		middleware.PoisonQueueMiddleware(deadLetters, retryMiddleware.MaxRetries),
+		retryMiddleware.Middleware,
 		middleware.TracingMiddleware,
 		middleware.PrometheusMiddleware,
 		middleware.CorrelationIDMiddleware,
+		middleware.OrderingKeyMiddleware,
 		middleware.LoggingMiddleware,
+		watermillMiddleware.Recoverer,
 	)
 
+	splitterSubscriber, err := broker.NewSubscriber("svc-tickets.events_splitter")
+	if err != nil {
+		panic(err)
+	}
+
 	router.AddNoPublisherHandler(
 		"events_splitter",
 		"events",
-		redisSubscriber,
+		splitterSubscriber,
 		func(msg *message.Message) error {
-			eventName := events.Marshaler.NameFromMessage(msg)
-			if eventName == "" {
+			if events.Marshaler.NameFromMessage(msg) == "" {
+				return fmt.Errorf("cannot get event name from message")
+			}
+
+			// consumers subscribe to the current version of the event, older versions are upcasted
+			upcastedMsg, err := eventRegistry.UpcastMessage(msg)
+			if err != nil {
+				return fmt.Errorf("cannot upcast event: %w", err)
+			}
+
+			return broker.Publisher().Publish("events."+events.Marshaler.NameFromMessage(upcastedMsg), upcastedMsg)
+		},
+	)
+
+	partitionerSubscriber, err := broker.NewSubscriber("svc-tickets.events_partitioner")
+	if err != nil {
+		panic(err)
+	}
+
+	// ordered consumers subscribe to partitions, events with the same ordering key are always in the same partition
+	router.AddNoPublisherHandler(
+		"events_partitioner",
+		"events",
+		partitionerSubscriber,
+		func(msg *message.Message) error {
+			if events.Marshaler.NameFromMessage(msg) == "" {
 				return fmt.Errorf("cannot get event name from message")
 			}
 
-			return redisPublisher.Publish("events."+eventName, msg)
+			upcastedMsg, err := eventRegistry.UpcastMessage(msg)
+			if err != nil {
+				return fmt.Errorf("cannot upcast event: %w", err)
+			}
+
+			return broker.Publisher().Publish(ordering.PartitionTopic(upcastedMsg), upcastedMsg)
 		},
 	)
 
+	storeSubscriber, err := broker.NewSubscriber("svc-tickets.events_store")
+	if err != nil {
+		panic(err)
+	}
+
 	router.AddNoPublisherHandler(
 		"events_store",
 		"events",
-		redisSubscriber,
+		storeSubscriber,
 		func(msg *message.Message) error {
 			eventName := events.Marshaler.NameFromMessage(msg)
 			if eventName == "" {
@@ -77,7 +126,8 @@ func NewWatermillRouter(
 		},
 	)
 
-	outbox.AddForwarderHandler(postgresSubscriber, redisPublisher, router, logger)
+	outbox.AddForwarderHandler(postgresSubscriber, broker.Publisher(), router, logger)
+	scheduler.AddReleaserHandler(scheduledMessagesSubscriber, broker.Publisher(), router)
 
 	return router
 }
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"fmt"
+	"tickets/entities"
+	"tickets/message/contracts"
+	"tickets/message/delay"
+	"tickets/observability"
+	"time"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Publisher stores messages sent with delay.WithDeliverAt in the scheduled messages store,
+// other messages are published right away by the wrapped publisher.
+// Command and event buses created with it can send messages both now and later.
+type Publisher struct {
+	pub   message.Publisher
+	store message.Publisher
+}
+
+func NewPublisher(pub message.Publisher, repo contracts.ScheduledMessageRepository) Publisher {
+	if pub == nil {
+		panic("publisher is nil")
+	}
+	if repo == nil {
+		panic("repo is nil")
+	}
+
+	// the wrapped publisher adds correlation ID and tracing metadata only to messages it publishes
+	var store message.Publisher = storePublisher{repo: repo}
+	store = log.CorrelationPublisherDecorator{Publisher: store}
+	store = observability.TracingPublisherDecorator{Publisher: store}
+
+	return Publisher{pub: pub, store: store}
+}
+
+func (p Publisher) Publish(topic string, messages ...*message.Message) error {
+	var now []*message.Message
+
+	for _, msg := range messages {
+		_, _, delayed, err := delay.FromMessage(msg)
+		if err != nil {
+			return err
+		}
+		if !delayed {
+			now = append(now, msg)
+			continue
+		}
+
+		if err := p.store.Publish(topic, msg); err != nil {
+			return err
+		}
+	}
+
+	if len(now) == 0 {
+		return nil
+	}
+
+	return p.pub.Publish(topic, now...)
+}
+
+func (p Publisher) Close() error {
+	return p.pub.Close()
+}
+
+// storePublisher stores messages in the scheduled messages store instead of publishing them.
+type storePublisher struct {
+	repo contracts.ScheduledMessageRepository
+}
+
+func (p storePublisher) Publish(topic string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		scheduledMessageID, deliverAt, _, err := delay.FromMessage(msg)
+		if err != nil {
+			return err
+		}
+
+		metadata := make(message.Metadata, len(msg.Metadata))
+		for k, v := range msg.Metadata {
+			metadata[k] = v
+		}
+		delay.RemoveMessageMetadata(metadata)
+
+		err = p.repo.Add(msg.Context(), entities.ScheduledMessage{
+			ScheduledMessageID: scheduledMessageID,
+			MessageUUID:        msg.UUID,
+			Topic:              topic,
+			Payload:            msg.Payload,
+			Metadata:           metadata,
+			DeliverAt:          deliverAt,
+			ScheduledAt:        time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("could not schedule message %s: %w", msg.UUID, err)
+		}
+	}
+
+	return nil
+}
+
+func (p storePublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/sirupsen/logrus"
+)
+
+// AddReleaserHandler publishes due scheduled messages to their original topics.
+func AddReleaserHandler(
+	subscriber *Subscriber,
+	publisher message.Publisher,
+	router *message.Router,
+) {
+	router.AddNoPublisherHandler(
+		"scheduled_messages_releaser",
+		releaseTopic,
+		subscriber,
+		func(msg *message.Message) error {
+			topic := msg.Metadata.Get(topicMetadataKey)
+			if topic == "" {
+				return fmt.Errorf("scheduled message %s has no topic", msg.UUID)
+			}
+
+			releasedMsg := msg.Copy()
+			delete(releasedMsg.Metadata, topicMetadataKey)
+			releasedMsg.SetContext(msg.Context())
+
+			log.FromContext(msg.Context()).WithFields(logrus.Fields{
+				"message_id": msg.UUID,
+				"topic":      topic,
+			}).Info("Releasing scheduled message")
+
+			return publisher.Publish(topic, releasedMsg)
+		},
+	)
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"tickets/message/commands"
+	"tickets/message/contracts"
+	"tickets/message/delay"
+	"tickets/message/events"
+	"tickets/observability"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+)
+
+// Scheduler sends commands and publishes events at the given time.
+// It uses the regular command and event buses with delay.WithDeliverAt, so the messages are stored in Postgres
+// until their delivery time and then released to their normal topics.
+type Scheduler struct {
+	commandBus *cqrs.CommandBus
+	eventBus   *cqrs.EventBus
+	repo       contracts.ScheduledMessageRepository
+}
+
+func NewScheduler(pub message.Publisher, repo contracts.ScheduledMessageRepository) Scheduler {
+	if repo == nil {
+		panic("repo is nil")
+	}
+
+	schedulerPublisher := NewPublisher(pub, repo)
+
+	return Scheduler{
+		commandBus: commands.NewCommandBus(schedulerPublisher),
+		eventBus:   events.NewEventBus(schedulerPublisher),
+		repo:       repo,
+	}
+}
+
+func (s Scheduler) SendCommandAt(ctx context.Context, cmd any, deliverAt time.Time) (uuid.UUID, error) {
+	scheduledMessageID := uuid.New()
+
+	err := s.commandBus.Send(delay.WithDeliverAt(ctx, scheduledMessageID, deliverAt), cmd)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("could not schedule command: %w", err)
+	}
+
+	return scheduledMessageID, nil
+}
+
+func (s Scheduler) SendCommandAfter(ctx context.Context, cmd any, delay time.Duration) (uuid.UUID, error) {
+	return s.SendCommandAt(ctx, cmd, time.Now().Add(delay))
+}
+
+func (s Scheduler) PublishEventAt(ctx context.Context, event any, deliverAt time.Time) (uuid.UUID, error) {
+	scheduledMessageID := uuid.New()
+
+	err := s.eventBus.Publish(delay.WithDeliverAt(ctx, scheduledMessageID, deliverAt), event)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("could not schedule event: %w", err)
+	}
+
+	return scheduledMessageID, nil
+}
+
+func (s Scheduler) PublishEventAfter(ctx context.Context, event any, delay time.Duration) (uuid.UUID, error) {
+	return s.PublishEventAt(ctx, event, time.Now().Add(delay))
+}
+
+// Cancel removes the message if it was not released yet.
+// It returns db.ErrScheduledMessageNotFound if the message was already released, is being released or was canceled.
+func (s Scheduler) Cancel(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	if err := s.repo.Remove(ctx, scheduledMessageID); err != nil {
+		return err
+	}
+
+	observability.ScheduledMessagesCanceled.Inc()
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"tickets/entities"
+	"tickets/message/scheduler"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_delayed_delivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := watermill.NopLogger{}
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+	repo := newScheduledMessagesMock()
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+	scheduler.AddReleaserHandler(scheduler.NewSubscriber(repo, logger), pubSub, router)
+
+	go func() {
+		require.NoError(t, router.Run(ctx))
+	}()
+	<-router.Running()
+
+	refunds, err := pubSub.Subscribe(ctx, "commands.RefundTicket")
+	require.NoError(t, err)
+
+	messageScheduler := scheduler.NewScheduler(pubSub, repo)
+
+	scheduledMessageID, err := messageScheduler.SendCommandAfter(ctx, entities.RefundTicket{
+		Header:   entities.NewEventHeader(),
+		TicketID: uuid.NewString(),
+	}, time.Hour)
+	require.NoError(t, err)
+
+	select {
+	case msg := <-refunds:
+		t.Fatalf("message %s was delivered before its delivery time", msg.UUID)
+	case <-time.After(time.Second * 2):
+	}
+
+	repo.advance(time.Hour)
+
+	select {
+	case msg := <-refunds:
+		msg.Ack()
+		assert.Empty(t, msg.Metadata.Get("deliver_at"))
+	case <-time.After(time.Second * 5):
+		t.Fatal("message was not delivered after its delivery time")
+	}
+
+	assert.Eventually(t, func() bool {
+		return !repo.contains(scheduledMessageID)
+	}, time.Second*5, time.Millisecond*100, "released message should be removed")
+}
+
+func TestScheduler_canceled_message_is_not_delivered(t *testing.T) {
+	ctx := context.Background()
+
+	repo := newScheduledMessagesMock()
+	messageScheduler := scheduler.NewScheduler(gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}), repo)
+
+	scheduledMessageID, err := messageScheduler.PublishEventAfter(ctx, entities.VipBundleStepTimedOut_v1{
+		Header: entities.NewEventHeader(),
+	}, time.Minute)
+	require.NoError(t, err)
+	require.True(t, repo.contains(scheduledMessageID))
+
+	require.NoError(t, messageScheduler.Cancel(ctx, scheduledMessageID))
+	assert.False(t, repo.contains(scheduledMessageID))
+}
+
+func TestSubscriber_crash_mid_release(t *testing.T) {
+	logger := watermill.NopLogger{}
+	repo := newScheduledMessagesMock()
+
+	scheduledMessage := entities.ScheduledMessage{
+		ScheduledMessageID: uuid.New(),
+		MessageUUID:        uuid.NewString(),
+		Topic:              "commands.RefundTicket",
+		Payload:            []byte(`{}`),
+		Metadata:           map[string]string{},
+		DeliverAt:          repo.now().Add(-time.Second),
+		ScheduledAt:        repo.now(),
+	}
+	require.NoError(t, repo.Add(context.Background(), scheduledMessage))
+
+	crashedCtx, crash := context.WithCancel(context.Background())
+	crashedMessages, err := scheduler.NewSubscriber(repo, logger).Subscribe(crashedCtx, "scheduled_messages")
+	require.NoError(t, err)
+
+	select {
+	case msg := <-crashedMessages:
+		assert.Equal(t, scheduledMessage.MessageUUID, msg.UUID)
+	case <-time.After(time.Second * 5):
+		t.Fatal("due message was not emitted")
+	}
+
+	// the service stops before the message is acked
+	crash()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := scheduler.NewSubscriber(repo, logger).Subscribe(ctx, "scheduled_messages")
+	require.NoError(t, err)
+
+	select {
+	case msg := <-messages:
+		t.Fatalf("message %s was released twice while it was claimed", msg.UUID)
+	case <-time.After(time.Second * 2):
+	}
+
+	repo.advance(time.Hour)
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, scheduledMessage.MessageUUID, msg.UUID)
+		msg.Ack()
+	case <-time.After(time.Second * 5):
+		t.Fatal("message was not released again after the claim expired")
+	}
+
+	assert.Eventually(t, func() bool {
+		return !repo.contains(scheduledMessage.ScheduledMessageID)
+	}, time.Second*5, time.Millisecond*100, "released message should be removed")
+}
+
+// scheduledMessagesMock is an in-memory store with a clock which can be moved forward.
+type scheduledMessagesMock struct {
+	lock     sync.Mutex
+	offset   time.Duration
+	messages map[uuid.UUID]entities.ScheduledMessage
+}
+
+func newScheduledMessagesMock() *scheduledMessagesMock {
+	return &scheduledMessagesMock{messages: map[uuid.UUID]entities.ScheduledMessage{}}
+}
+
+func (m *scheduledMessagesMock) now() time.Time {
+	return time.Now().Add(m.offset)
+}
+
+func (m *scheduledMessagesMock) advance(d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.offset += d
+}
+
+func (m *scheduledMessagesMock) contains(scheduledMessageID uuid.UUID) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, ok := m.messages[scheduledMessageID]
+	return ok
+}
+
+func (m *scheduledMessagesMock) Add(ctx context.Context, scheduledMessage entities.ScheduledMessage) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.messages[scheduledMessage.ScheduledMessageID] = scheduledMessage
+
+	return nil
+}
+
+func (m *scheduledMessagesMock) FindAll(ctx context.Context) ([]entities.ScheduledMessage, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	scheduledMessages := make([]entities.ScheduledMessage, 0, len(m.messages))
+	for _, scheduledMessage := range m.messages {
+		scheduledMessages = append(scheduledMessages, scheduledMessage)
+	}
+	sort.Slice(scheduledMessages, func(i, j int) bool {
+		return scheduledMessages[i].DeliverAt.Before(scheduledMessages[j].DeliverAt)
+	})
+
+	return scheduledMessages, nil
+}
+
+func (m *scheduledMessagesMock) Remove(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	scheduledMessage, ok := m.messages[scheduledMessageID]
+	if !ok || m.isClaimed(scheduledMessage) {
+		return errors.New("scheduled message not found")
+	}
+	delete(m.messages, scheduledMessageID)
+
+	return nil
+}
+
+func (m *scheduledMessagesMock) ClaimDue(ctx context.Context, claimFor time.Duration) (entities.ScheduledMessage, bool, error) {
+	scheduledMessages, _ := m.FindAll(ctx)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, scheduledMessage := range scheduledMessages {
+		if scheduledMessage.DeliverAt.After(m.now()) || m.isClaimed(scheduledMessage) {
+			continue
+		}
+
+		claimedUntil := m.now().Add(claimFor)
+		scheduledMessage.ClaimedUntil = &claimedUntil
+		m.messages[scheduledMessage.ScheduledMessageID] = scheduledMessage
+
+		return scheduledMessage, true, nil
+	}
+
+	return entities.ScheduledMessage{}, false, nil
+}
+
+func (m *scheduledMessagesMock) MarkReleased(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.messages, scheduledMessageID)
+
+	return nil
+}
+
+func (m *scheduledMessagesMock) Unclaim(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if scheduledMessage, ok := m.messages[scheduledMessageID]; ok {
+		scheduledMessage.ClaimedUntil = nil
+		m.messages[scheduledMessageID] = scheduledMessage
+	}
+
+	return nil
+}
+
+func (m *scheduledMessagesMock) Count(ctx context.Context) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.messages), nil
+}
+
+func (m *scheduledMessagesMock) isClaimed(scheduledMessage entities.ScheduledMessage) bool {
+	return scheduledMessage.ClaimedUntil != nil && scheduledMessage.ClaimedUntil.After(m.now())
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"tickets/entities"
+	"tickets/message/contracts"
+	"tickets/observability"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const (
+	releaseTopic = "scheduled_messages"
+
+	// topicMetadataKey keeps the original topic of the released message
+	topicMetadataKey = "scheduled_topic"
+
+	pollInterval = time.Second
+
+	// claimTimeout has to be longer than retrying the message by the router,
+	// otherwise the message may be released twice
+	claimTimeout = time.Minute
+)
+
+var errSubscriberClosed = errors.New("subscriber closed")
+
+// Subscriber polls the scheduled messages store and emits due messages.
+// A message is claimed before it's emitted and removed from the store when it's acked.
+// Nacked messages are unclaimed and emitted again on the next poll.
+// If the service stops before the message is acked, it's emitted again when the claim expires.
+type Subscriber struct {
+	repo   contracts.ScheduledMessageRepository
+	logger watermill.LoggerAdapter
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewSubscriber(repo contracts.ScheduledMessageRepository, logger watermill.LoggerAdapter) *Subscriber {
+	if repo == nil {
+		panic("repo is nil")
+	}
+
+	return &Subscriber{
+		repo:    repo,
+		logger:  logger,
+		closing: make(chan struct{}),
+	}
+}
+
+func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	if topic != releaseTopic {
+		return nil, fmt.Errorf("scheduled messages can be consumed only from %s topic", releaseTopic)
+	}
+
+	out := make(chan *message.Message)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(out)
+
+		for {
+			scheduledMessage, claimed, err := s.repo.ClaimDue(ctx, claimTimeout)
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("Could not claim scheduled message", err, nil)
+			}
+			if claimed {
+				err := s.release(ctx, out, scheduledMessage)
+				if errors.Is(err, errSubscriberClosed) || ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					s.logger.Error("Could not release scheduled message", err, watermill.LogFields{
+						"scheduled_message_id": scheduledMessage.ScheduledMessageID,
+					})
+				}
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.closing:
+				return
+			case <-time.After(pollInterval):
+			}
+
+			s.refreshPendingGauge(ctx)
+		}
+	}()
+
+	return out, nil
+}
+
+// release emits the claimed message and waits until it's handled.
+func (s *Subscriber) release(ctx context.Context, out chan<- *message.Message, scheduledMessage entities.ScheduledMessage) error {
+	msg := message.NewMessage(scheduledMessage.MessageUUID, scheduledMessage.Payload)
+	for k, v := range scheduledMessage.Metadata {
+		msg.Metadata.Set(k, v)
+	}
+	msg.Metadata.Set(topicMetadataKey, scheduledMessage.Topic)
+
+	msgCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	msg.SetContext(msgCtx)
+
+	select {
+	case out <- msg:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.closing:
+		return errSubscriberClosed
+	}
+
+	select {
+	case <-msg.Acked():
+		observability.ScheduledMessagesReleased.Inc()
+		return s.repo.MarkReleased(ctx, scheduledMessage.ScheduledMessageID)
+	case <-msg.Nacked():
+		if err := s.repo.Unclaim(ctx, scheduledMessage.ScheduledMessageID); err != nil {
+			return err
+		}
+		return fmt.Errorf("message %s was nacked", msg.UUID)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.closing:
+		return errSubscriberClosed
+	}
+}
+
+func (s *Subscriber) refreshPendingGauge(ctx context.Context) {
+	// messages scheduled in handlers' transactions are not counted when they are added
+	count, err := s.repo.Count(ctx)
+	if err != nil {
+		return
+	}
+
+	observability.ScheduledMessagesPending.Set(float64(count))
+}
+
+func (s *Subscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.wg.Wait()
+
+	return nil
+}
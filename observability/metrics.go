@@ -0,0 +1,46 @@
+package observability
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var DeadLettersParked = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "messages",
+		Name:      "dead_letters_parked",
+		Help:      "The number of messages parked in the dead letter store",
+	},
+)
+
+var ScheduledMessagesPending = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "messages",
+		Name:      "scheduled_pending",
+		Help:      "The number of scheduled messages waiting for delivery",
+	},
+)
+
+var ScheduledMessagesReleased = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "messages",
+		Name:      "scheduled_released_total",
+		Help:      "The total number of scheduled messages released to their topics",
+	},
+)
+
+var ScheduledMessagesCanceled = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "messages",
+		Name:      "scheduled_canceled_total",
+		Help:      "The total number of scheduled messages canceled before delivery",
+	},
+)
+
+var TicketPriceMismatches = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "tickets",
+		Name:      "price_mismatches_total",
+		Help:      "The total number of tickets confirmed with a price different from their price category",
+	},
+)
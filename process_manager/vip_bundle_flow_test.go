@@ -0,0 +1,829 @@
+package process_manager_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"tickets/entities"
+	"tickets/message/commands"
+	"tickets/message/events"
+	"tickets/process_manager"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVipBundle_step_timed_out(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.bookShowAndInboundFlight()
+
+	timedOut := h.deadline(vb.VipBundleID, entities.VipBundleStepReturnFlight)
+	h.takeMessages()
+
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &timedOut))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, entities.VipBundleStepReturnFlight, vb.FailedStep)
+
+	refunds := sentCommands[entities.RefundTicket](h)
+	assert.ElementsMatch(t, uuidStrings(vb.TicketIDs), lo.Map(refunds, func(c entities.RefundTicket, _ int) string { return c.TicketID }))
+
+	canceledFlights := sentCommands[entities.CancelFlightTickets](h)
+	require.Len(t, canceledFlights, 1)
+	assert.Equal(t, vb.InboundFlightID, canceledFlights[0].FlightID)
+
+	require.Len(t, publishedEvents[entities.VipBundleFailed_v1](h), 1)
+	h.deadline(vb.VipBundleID, entities.VipBundleStepCompensation)
+}
+
+func TestVipBundle_deadline_after_step_completed(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.bookShowAndInboundFlight()
+
+	inboundTimedOut := h.deadline(vb.VipBundleID, entities.VipBundleStepInboundFlight)
+	h.takeMessages()
+
+	// deadlines are not canceled, the inbound flight was booked before its deadline
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &inboundTimedOut))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateInboundFlightBooked, vb.State)
+	assert.False(t, vb.Failed)
+	assert.Empty(t, h.takeMessages())
+}
+
+func TestVipBundle_compensation_timed_out(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.bookShowAndInboundFlight()
+
+	returnTimedOut := h.deadline(vb.VipBundleID, entities.VipBundleStepReturnFlight)
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &returnTimedOut))
+
+	refunded := vb.TicketIDs[0]
+	require.NoError(t, h.pm.OnTicketRefunded(h.ctx, &entities.TicketRefunded_v1{
+		Header:      entities.NewEventHeader(),
+		TicketID:    refunded.String(),
+		ReferenceID: vb.VipBundleID.String(),
+	}))
+	h.takeMessages()
+
+	compensationTimedOut := h.deadline(vb.VipBundleID, entities.VipBundleStepCompensation)
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &compensationTimedOut))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	for _, c := range vb.Compensations {
+		if c.ReferenceID == refunded {
+			assert.Equal(t, entities.VipBundleCompensationDone, c.Status)
+		} else {
+			assert.Equal(t, entities.VipBundleCompensationFailed, c.Status, "%s of %s", c.Action, c.ReferenceID)
+		}
+	}
+	assert.Empty(t, h.takeMessages())
+}
+
+func TestVipBundle_taxi_compensation(t *testing.T) {
+	h := newVipBundleHarness(t)
+
+	vb := newVipBundle()
+	// three taxis of the default capacity
+	vb.Passengers = []string{"Ann", "Bob", "Cid", "Dan", "Eve", "Fay", "Gus", "Hal", "Ivy"}
+	vb = h.startVipBundle(vb)
+	h.flightBooked(vb, entities.FlightLegInbound, vb.InboundFlightID)
+	h.flightBooked(vb, entities.FlightLegReturn, vb.ReturnFlightID)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	require.Len(t, vb.Taxis, 3)
+	require.Len(t, sentCommands[entities.BookTaxi](h), 3)
+	h.takeMessages()
+
+	firstTaxi := h.taxiBooked(vb, 0)
+	h.taxiBookingFailed(vb, 1)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, entities.VipBundleStepTaxi, vb.FailedStep)
+	assert.Equal(t, []uuid.UUID{firstTaxi}, canceledTaxis(h))
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelTaxi, firstTaxi, entities.VipBundleCompensationPending)
+	h.takeMessages()
+
+	// the third taxi was still being booked when the bundle failed
+	lateTaxi := h.taxiBooked(vb, 2)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, []uuid.UUID{lateTaxi}, canceledTaxis(h))
+	assert.Empty(t, publishedEvents[entities.VipBundleFinalized_v1](h))
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelTaxi, lateTaxi, entities.VipBundleCompensationPending)
+
+	for _, taxiBookingID := range []uuid.UUID{firstTaxi, lateTaxi} {
+		require.NoError(t, h.pm.OnTaxiBookingCanceled(h.ctx, &entities.TaxiBookingCanceled_v1{
+			Header:        entities.NewEventHeader(),
+			TaxiBookingID: taxiBookingID,
+			ReferenceID:   vb.VipBundleID.String(),
+		}))
+	}
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelTaxi, firstTaxi, entities.VipBundleCompensationDone)
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelTaxi, lateTaxi, entities.VipBundleCompensationDone)
+}
+
+func TestVipBundle_return_flight_booked_after_inbound_failed(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.startVipBundle(newVipBundle())
+	h.takeMessages()
+
+	h.flightBookingFailed(vb, entities.FlightLegInbound, vb.InboundFlightID)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, entities.VipBundleStepInboundFlight, vb.FailedStep)
+	assert.Len(t, sentCommands[entities.RefundTicket](h), vb.NumberOfTickets)
+	assert.Empty(t, sentCommands[entities.CancelFlightTickets](h), "no flight was booked yet")
+	h.takeMessages()
+
+	returnFlight := h.flightBooked(vb, entities.FlightLegReturn, vb.ReturnFlightID)
+
+	canceled := sentCommands[entities.CancelFlightTickets](h)
+	require.Len(t, canceled, 1)
+	assert.Equal(t, vb.ReturnFlightID, canceled[0].FlightID)
+	assert.Equal(t, returnFlight.TicketIDs, canceled[0].FlightTicketIDs)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelFlight, vb.ReturnFlightID, entities.VipBundleCompensationPending)
+
+	require.NoError(t, h.pm.OnFlightTicketsCanceled(h.ctx, &entities.FlightTicketsCanceled_v1{
+		Header:          entities.NewEventHeader(),
+		FlightID:        vb.ReturnFlightID,
+		FlightTicketIDs: returnFlight.TicketIDs,
+		ReferenceID:     vb.VipBundleID.String(),
+	}))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelFlight, vb.ReturnFlightID, entities.VipBundleCompensationDone)
+}
+
+func TestVipBundle_alternative_flight_booked(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := newVipBundle()
+	alternative := uuid.New()
+	vb.InboundFlightAlternatives = []uuid.UUID{alternative}
+	vb = h.startVipBundle(vb)
+
+	preferredDeadline := h.deadline(vb.VipBundleID, entities.VipBundleStepInboundFlight)
+	assert.Equal(t, vb.InboundFlightID, preferredDeadline.FlightID)
+	h.takeMessages()
+
+	h.flightBookingFailed(vb, entities.FlightLegInbound, vb.InboundFlightID)
+
+	assert.Equal(t, []uuid.UUID{vb.InboundFlightID, alternative}, bookedFlights(h, entities.FlightLegInbound))
+
+	alternativeDeadline := h.deadline(vb.VipBundleID, entities.VipBundleStepInboundFlight)
+	assert.Equal(t, alternative, alternativeDeadline.FlightID)
+	assert.NotEqual(t, preferredDeadline.Header.IdempotencyKey, alternativeDeadline.Header.IdempotencyKey)
+
+	customerFlight := vb.InboundFlightID
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateShowBooked, vb.State)
+	assert.Equal(t, customerFlight, vb.InboundFlightID, "the flight chosen by the customer is kept")
+	assert.Equal(t, alternative, vb.AttemptedFlightID(entities.FlightLegInbound))
+	h.takeMessages()
+
+	// the deadline of the replaced flight doesn't fail the bundle
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &preferredDeadline))
+	assert.False(t, h.vipBundle(vb.VipBundleID).Failed)
+
+	h.flightBooked(vb, entities.FlightLegInbound, alternative)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateInboundFlightBooked, vb.State)
+	assert.False(t, vb.Failed)
+
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &alternativeDeadline))
+	assert.False(t, h.vipBundle(vb.VipBundleID).Failed)
+
+	// compensation cancels the booked alternative, not the flight chosen by the customer
+	timedOut := h.deadline(vb.VipBundleID, entities.VipBundleStepReturnFlight)
+	require.NoError(t, h.pm.OnStepTimedOut(h.ctx, &timedOut))
+
+	canceled := sentCommands[entities.CancelFlightTickets](h)
+	require.Len(t, canceled, 1)
+	assert.Equal(t, alternative, canceled[0].FlightID)
+}
+
+func TestVipBundle_flight_alternatives_exhausted(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := newVipBundle()
+	alternatives := []uuid.UUID{uuid.New(), uuid.New()}
+	vb.InboundFlightAlternatives = alternatives
+	vb = h.startVipBundle(vb)
+	h.takeMessages()
+
+	h.flightBookingFailed(vb, entities.FlightLegInbound, vb.InboundFlightID)
+	h.flightBookingFailed(vb, entities.FlightLegInbound, alternatives[0])
+	h.flightBookingFailed(vb, entities.FlightLegInbound, alternatives[1])
+
+	assert.Equal(t, append([]uuid.UUID{vb.InboundFlightID}, alternatives...), bookedFlights(h, entities.FlightLegInbound))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, entities.VipBundleStepInboundFlight, vb.FailedStep)
+	inboundAttempts := lo.Filter(vb.FlightAttempts, func(a entities.VipBundleFlightAttempt, _ int) bool {
+		return a.Leg == entities.FlightLegInbound
+	})
+	assert.Len(t, inboundAttempts, 3)
+	for _, attempt := range inboundAttempts {
+		assert.Equal(t, entities.VipBundleFlightAttemptFailed, attempt.Status, "flight %s", attempt.FlightID)
+	}
+
+	assert.Len(t, sentCommands[entities.RefundTicket](h), vb.NumberOfTickets)
+	assert.Empty(t, sentCommands[entities.CancelFlightTickets](h), "no flight was booked")
+	require.Len(t, publishedEvents[entities.VipBundleFailed_v1](h), 1)
+}
+
+func TestVipBundle_hotel_booked_before_taxi(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.startVipBundle(newVipBundleWithHotel())
+	h.flightBooked(vb, entities.FlightLegInbound, vb.InboundFlightID)
+	h.takeMessages()
+
+	h.flightBooked(vb, entities.FlightLegReturn, vb.ReturnFlightID)
+
+	bookings := sentCommands[entities.BookHotel](h)
+	require.Len(t, bookings, 1)
+	assert.Equal(t, *vb.HotelID, bookings[0].HotelID)
+	assert.Equal(t, vb.Nights, bookings[0].Nights)
+	assert.Equal(t, len(vb.Passengers), bookings[0].NumberOfGuests)
+	assert.Empty(t, sentCommands[entities.BookTaxi](h), "taxi is booked after the hotel")
+	h.deadline(vb.VipBundleID, entities.VipBundleStepHotel)
+	h.takeMessages()
+
+	hotelBookingID := h.hotelBooked(vb)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateHotelBooked, vb.State)
+	assert.Equal(t, &hotelBookingID, vb.HotelBookingID)
+	assert.NotEmpty(t, sentCommands[entities.BookTaxi](h))
+}
+
+func TestVipBundle_hotel_booking_failed(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.startVipBundle(newVipBundleWithHotel())
+	h.flightBooked(vb, entities.FlightLegInbound, vb.InboundFlightID)
+	h.flightBooked(vb, entities.FlightLegReturn, vb.ReturnFlightID)
+	h.takeMessages()
+
+	require.NoError(t, h.pm.OnHotelBookingFailed(h.ctx, &entities.HotelBookingFailed_v1{
+		Header:        entities.NewEventHeader(),
+		HotelID:       *vb.HotelID,
+		FailureReason: "no rooms left",
+		ReferenceID:   vb.VipBundleID.String(),
+	}))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, entities.VipBundleStepHotel, vb.FailedStep)
+	assert.Nil(t, vb.HotelBookingID)
+
+	canceled := sentCommands[entities.CancelFlightTickets](h)
+	assert.ElementsMatch(t, []uuid.UUID{vb.InboundFlightID, vb.ReturnFlightID}, lo.Map(canceled, func(c entities.CancelFlightTickets, _ int) uuid.UUID {
+		return c.FlightID
+	}))
+	assert.Empty(t, sentCommands[entities.CancelHotel](h), "no hotel room was booked")
+	assert.Empty(t, sentCommands[entities.BookTaxi](h))
+}
+
+func TestVipBundle_hotel_compensation(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.startVipBundle(newVipBundleWithHotel())
+	h.flightBooked(vb, entities.FlightLegInbound, vb.InboundFlightID)
+	h.flightBooked(vb, entities.FlightLegReturn, vb.ReturnFlightID)
+	hotelBookingID := h.hotelBooked(vb)
+	h.takeMessages()
+
+	h.taxiBookingFailed(vb, 0)
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assert.Equal(t, entities.VipBundleStateFailed, vb.State)
+	assert.Equal(t, entities.VipBundleStepTaxi, vb.FailedStep)
+
+	canceled := sentCommands[entities.CancelHotel](h)
+	require.Len(t, canceled, 1)
+	assert.Equal(t, hotelBookingID, canceled[0].HotelBookingID)
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelHotel, hotelBookingID, entities.VipBundleCompensationPending)
+
+	require.NoError(t, h.pm.OnHotelBookingCanceled(h.ctx, &entities.HotelBookingCanceled_v1{
+		Header:         entities.NewEventHeader(),
+		HotelBookingID: hotelBookingID,
+		ReferenceID:    vb.VipBundleID.String(),
+	}))
+
+	vb = h.vipBundle(vb.VipBundleID)
+	assertCompensation(t, vb, entities.VipBundleCompensationCancelHotel, hotelBookingID, entities.VipBundleCompensationDone)
+}
+
+func TestVipBundle_events_of_other_bookings_are_skipped(t *testing.T) {
+	h := newVipBundleHarness(t)
+	h.startVipBundle(newVipBundle())
+	h.takeMessages()
+
+	bookingID := uuid.New()
+
+	require.NoError(t, h.pm.OnBookingMade(h.ctx, &entities.BookingMade_v1{
+		Header:          entities.NewEventHeader(),
+		NumberOfTickets: 1,
+		BookingID:       bookingID,
+		ShowId:          uuid.New(),
+	}))
+	require.NoError(t, h.pm.OnTicketBookingConfirmed(h.ctx, &entities.TicketBookingConfirmed_v1{
+		Header:    entities.NewEventHeader(),
+		TicketID:  uuid.NewString(),
+		BookingID: bookingID.String(),
+	}))
+	require.NoError(t, h.pm.OnBookingFailed(h.ctx, &entities.BookingFailed_v1{
+		Header:        entities.NewEventHeader(),
+		BookingID:     bookingID,
+		FailureReason: "sold out",
+	}))
+
+	assert.Empty(t, h.takeMessages())
+}
+
+func TestVipBundle_illegal_transition_is_acked(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.bookShowAndInboundFlight()
+	h.takeMessages()
+
+	// the show was booked already
+	err := h.pm.OnVipBundleInitialized(h.ctx, &entities.VipBundleInitialized_v1{
+		Header:      entities.NewEventHeader(),
+		VipBundleID: vb.VipBundleID,
+		BookingID:   vb.BookingID,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, entities.VipBundleStateInboundFlightBooked, h.vipBundle(vb.VipBundleID).State)
+	assert.Empty(t, h.takeMessages())
+}
+
+func TestVipBundle_invalid_ticket_id(t *testing.T) {
+	h := newVipBundleHarness(t)
+	vb := h.startVipBundle(newVipBundle())
+	h.takeMessages()
+
+	err := h.pm.OnTicketBookingConfirmed(h.ctx, &entities.TicketBookingConfirmed_v1{
+		Header:    entities.NewEventHeader(),
+		TicketID:  "not-a-uuid",
+		BookingID: vb.BookingID.String(),
+	})
+	require.ErrorContains(t, err, "invalid ticket id")
+
+	assert.Equal(t, vb.TicketIDs, h.vipBundle(vb.VipBundleID).TicketIDs)
+	assert.Empty(t, h.takeMessages())
+}
+
+// vipBundleHarness runs the process manager with in-memory storage and records the messages it sends.
+type vipBundleHarness struct {
+	t   *testing.T
+	ctx context.Context
+
+	pm        *process_manager.VipBundleProcessManager
+	repo      *vipBundleRepositoryMock
+	publisher *recordingPublisher
+	scheduler *schedulerMock
+
+	sent []*message.Message
+}
+
+func newVipBundleHarness(t *testing.T) *vipBundleHarness {
+	t.Helper()
+
+	repo := &vipBundleRepositoryMock{vipBundles: map[uuid.UUID]entities.VipBundle{}}
+	publisher := &recordingPublisher{}
+	scheduler := &schedulerMock{}
+
+	return &vipBundleHarness{
+		t:   t,
+		ctx: context.Background(),
+		pm: process_manager.NewVipBundleProcessManager(
+			commands.NewCommandBus(publisher),
+			events.NewEventBus(publisher),
+			repo,
+			scheduler,
+			process_manager.DefaultVipBundleDeadlines(),
+			process_manager.DefaultTaxiCapacity,
+		),
+		repo:      repo,
+		publisher: publisher,
+		scheduler: scheduler,
+	}
+}
+
+// startVipBundle stores the bundle and handles the show booking, so flights are being booked.
+func (h *vipBundleHarness) startVipBundle(vb entities.VipBundle) entities.VipBundle {
+	h.t.Helper()
+
+	require.NoError(h.t, h.repo.Add(h.ctx, vb))
+	require.NoError(h.t, h.pm.OnVipBundleInitialized(h.ctx, &entities.VipBundleInitialized_v1{
+		Header:      entities.NewEventHeader(),
+		VipBundleID: vb.VipBundleID,
+		BookingID:   vb.BookingID,
+	}))
+
+	require.NoError(h.t, h.pm.OnBookingMade(h.ctx, &entities.BookingMade_v1{
+		Header:          entities.NewEventHeader(),
+		NumberOfTickets: vb.NumberOfTickets,
+		BookingID:       vb.BookingID,
+		CustomerEmail:   vb.CustomerEmail,
+		ShowId:          vb.ShowId,
+	}))
+
+	for i := 0; i < vb.NumberOfTickets; i++ {
+		require.NoError(h.t, h.pm.OnTicketBookingConfirmed(h.ctx, &entities.TicketBookingConfirmed_v1{
+			Header:        entities.NewEventHeader(),
+			TicketID:      uuid.NewString(),
+			CustomerEmail: vb.CustomerEmail,
+			BookingID:     vb.BookingID.String(),
+		}))
+	}
+
+	return h.vipBundle(vb.VipBundleID)
+}
+
+// bookShowAndInboundFlight returns a bundle which waits for its return flight.
+func (h *vipBundleHarness) bookShowAndInboundFlight() entities.VipBundle {
+	h.t.Helper()
+
+	vb := h.startVipBundle(newVipBundle())
+	h.flightBooked(vb, entities.FlightLegInbound, vb.InboundFlightID)
+
+	return h.vipBundle(vb.VipBundleID)
+}
+
+func (h *vipBundleHarness) flightBooked(vb entities.VipBundle, leg entities.FlightLeg, flightID uuid.UUID) entities.FlightBooked_v1 {
+	h.t.Helper()
+
+	event := entities.FlightBooked_v1{
+		Header:      entities.NewEventHeader(),
+		FlightID:    flightID,
+		Leg:         leg,
+		TicketIDs:   []uuid.UUID{uuid.New(), uuid.New()},
+		ReferenceID: vb.VipBundleID.String(),
+	}
+	require.NoError(h.t, h.pm.OnFlightBooked(h.ctx, &event))
+
+	return event
+}
+
+func (h *vipBundleHarness) flightBookingFailed(vb entities.VipBundle, leg entities.FlightLeg, flightID uuid.UUID) {
+	h.t.Helper()
+
+	require.NoError(h.t, h.pm.OnFlightBookingFailed(h.ctx, &entities.FlightBookingFailed_v1{
+		Header:        entities.NewEventHeader(),
+		FlightID:      flightID,
+		Leg:           leg,
+		FailureReason: "no seats left",
+		ReferenceID:   vb.VipBundleID.String(),
+	}))
+}
+
+func (h *vipBundleHarness) hotelBooked(vb entities.VipBundle) uuid.UUID {
+	h.t.Helper()
+
+	hotelBookingID := uuid.New()
+	require.NoError(h.t, h.pm.OnHotelBooked(h.ctx, &entities.HotelBooked_v1{
+		Header:         entities.NewEventHeader(),
+		HotelID:        *vb.HotelID,
+		HotelBookingID: hotelBookingID,
+		ReferenceID:    vb.VipBundleID.String(),
+	}))
+
+	return hotelBookingID
+}
+
+func (h *vipBundleHarness) taxiBooked(vb entities.VipBundle, taxiIndex int) uuid.UUID {
+	h.t.Helper()
+
+	taxiBookingID := uuid.New()
+	require.NoError(h.t, h.pm.OnTaxiBooked(h.ctx, &entities.TaxiBooked_v1{
+		Header:        entities.NewEventHeader(),
+		TaxiBookingID: taxiBookingID,
+		TaxiIndex:     taxiIndex,
+		ReferenceID:   vb.VipBundleID.String(),
+	}))
+
+	return taxiBookingID
+}
+
+func (h *vipBundleHarness) taxiBookingFailed(vb entities.VipBundle, taxiIndex int) {
+	h.t.Helper()
+
+	require.NoError(h.t, h.pm.OnTaxiBookingFailed(h.ctx, &entities.TaxiBookingFailed_v1{
+		Header:        entities.NewEventHeader(),
+		FailureReason: "no taxi available",
+		TaxiIndex:     taxiIndex,
+		ReferenceID:   vb.VipBundleID.String(),
+	}))
+}
+
+func (h *vipBundleHarness) vipBundle(vipBundleID uuid.UUID) entities.VipBundle {
+	h.t.Helper()
+
+	vb, err := h.repo.Get(h.ctx, vipBundleID)
+	require.NoError(h.t, err)
+
+	return vb
+}
+
+// deadline returns the last scheduled deadline of the step.
+func (h *vipBundleHarness) deadline(vipBundleID uuid.UUID, step entities.VipBundleStep) entities.VipBundleStepTimedOut_v1 {
+	h.t.Helper()
+
+	deadlines := h.scheduler.deadlines(vipBundleID, step)
+	require.NotEmpty(h.t, deadlines, "deadline of %s was not scheduled", step)
+
+	return deadlines[len(deadlines)-1]
+}
+
+// takeMessages returns messages sent since the last call.
+func (h *vipBundleHarness) takeMessages() []*message.Message {
+	h.sent = h.publisher.take()
+	return h.sent
+}
+
+var marshaler = cqrs.JSONMarshaler{GenerateName: cqrs.StructName}
+
+// sentCommands returns commands of type T sent since the last takeMessages call.
+func sentCommands[T any](h *vipBundleHarness) []T {
+	return messagesOfType[T](h)
+}
+
+func publishedEvents[T any](h *vipBundleHarness) []T {
+	return messagesOfType[T](h)
+}
+
+func messagesOfType[T any](h *vipBundleHarness) []T {
+	h.t.Helper()
+
+	h.sent = append(h.sent, h.publisher.take()...)
+
+	var result []T
+	for _, msg := range h.sent {
+		if marshaler.NameFromMessage(msg) != cqrs.StructName(new(T)) {
+			continue
+		}
+
+		var v T
+		require.NoError(h.t, marshaler.Unmarshal(msg, &v))
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// bookedFlights returns flights of the leg for which BookFlight was sent, in order.
+func bookedFlights(h *vipBundleHarness, leg entities.FlightLeg) []uuid.UUID {
+	bookings := lo.Filter(sentCommands[entities.BookFlight](h), func(c entities.BookFlight, _ int) bool { return c.Leg == leg })
+	return lo.Map(bookings, func(c entities.BookFlight, _ int) uuid.UUID { return c.FlightID })
+}
+
+func canceledTaxis(h *vipBundleHarness) []uuid.UUID {
+	return lo.Map(sentCommands[entities.CancelTaxi](h), func(c entities.CancelTaxi, _ int) uuid.UUID { return c.TaxiBookingID })
+}
+
+func assertCompensation(
+	t *testing.T,
+	vb entities.VipBundle,
+	action entities.VipBundleCompensationAction,
+	referenceID uuid.UUID,
+	status entities.VipBundleCompensationStatus,
+) {
+	t.Helper()
+
+	c, ok := lo.Find(vb.Compensations, func(c entities.VipBundleCompensation) bool {
+		return c.Action == action && c.ReferenceID == referenceID
+	})
+	if assert.True(t, ok, "%s of %s was not started", action, referenceID) {
+		assert.Equal(t, status, c.Status, "%s of %s", action, referenceID)
+	}
+}
+
+func newVipBundle() entities.VipBundle {
+	return entities.VipBundle{
+		VipBundleID:     uuid.New(),
+		BookingID:       uuid.New(),
+		CustomerEmail:   "vip@example.com",
+		NumberOfTickets: 2,
+		ShowId:          uuid.New(),
+		Passengers:      []string{"Ann", "Bob"},
+		InboundFlightID: uuid.New(),
+		ReturnFlightID:  uuid.New(),
+		State:           entities.VipBundleStateInitialized,
+	}
+}
+
+func newVipBundleWithHotel() entities.VipBundle {
+	vb := newVipBundle()
+	vb.HotelID = lo.ToPtr(uuid.New())
+	vb.Nights = 3
+
+	return vb
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	return lo.Map(ids, func(id uuid.UUID, _ int) string { return id.String() })
+}
+
+type recordingPublisher struct {
+	lock     sync.Mutex
+	messages []*message.Message
+}
+
+func (p *recordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.messages = append(p.messages, messages...)
+
+	return nil
+}
+
+func (p *recordingPublisher) take() []*message.Message {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	messages := p.messages
+	p.messages = nil
+
+	return messages
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+type schedulerMock struct {
+	lock   sync.Mutex
+	events []any
+}
+
+func (s *schedulerMock) SendCommandAt(ctx context.Context, cmd any, deliverAt time.Time) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (s *schedulerMock) SendCommandAfter(ctx context.Context, cmd any, delay time.Duration) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (s *schedulerMock) PublishEventAt(ctx context.Context, event any, deliverAt time.Time) (uuid.UUID, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.events = append(s.events, event)
+
+	return uuid.New(), nil
+}
+
+func (s *schedulerMock) PublishEventAfter(ctx context.Context, event any, delay time.Duration) (uuid.UUID, error) {
+	return s.PublishEventAt(ctx, event, time.Now().Add(delay))
+}
+
+func (s *schedulerMock) Cancel(ctx context.Context, scheduledMessageID uuid.UUID) error {
+	return nil
+}
+
+func (s *schedulerMock) deadlines(vipBundleID uuid.UUID, step entities.VipBundleStep) []entities.VipBundleStepTimedOut_v1 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var deadlines []entities.VipBundleStepTimedOut_v1
+	for _, event := range s.events {
+		timedOut, ok := event.(entities.VipBundleStepTimedOut_v1)
+		if ok && timedOut.VipBundleID == vipBundleID && timedOut.Step == step {
+			deadlines = append(deadlines, timedOut)
+		}
+	}
+
+	return deadlines
+}
+
+var errVipBundleNotFound = errors.New("vip bundle not found")
+
+// vipBundleRepositoryMock stores copies of bundles, so changes of a failed update are not kept.
+type vipBundleRepositoryMock struct {
+	lock       sync.Mutex
+	vipBundles map[uuid.UUID]entities.VipBundle
+}
+
+func (r *vipBundleRepositoryMock) Add(ctx context.Context, vipBundle entities.VipBundle) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.vipBundles[vipBundle.VipBundleID] = copyVipBundle(vipBundle)
+
+	return nil
+}
+
+func (r *vipBundleRepositoryMock) Get(ctx context.Context, vipBundleID uuid.UUID) (entities.VipBundle, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	vb, ok := r.vipBundles[vipBundleID]
+	if !ok {
+		return entities.VipBundle{}, errVipBundleNotFound
+	}
+
+	return copyVipBundle(vb), nil
+}
+
+func (r *vipBundleRepositoryMock) GetByBookingID(ctx context.Context, bookingID uuid.UUID) (entities.VipBundle, error) {
+	vb, ok, err := r.FindByBookingID(ctx, bookingID)
+	if err != nil {
+		return entities.VipBundle{}, err
+	}
+	if !ok {
+		return entities.VipBundle{}, errVipBundleNotFound
+	}
+
+	return vb, nil
+}
+
+func (r *vipBundleRepositoryMock) FindByBookingID(ctx context.Context, bookingID uuid.UUID) (entities.VipBundle, bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, vb := range r.vipBundles {
+		if vb.BookingID == bookingID {
+			return copyVipBundle(vb), true, nil
+		}
+	}
+
+	return entities.VipBundle{}, false, nil
+}
+
+func (r *vipBundleRepositoryMock) FindByCustomerEmail(ctx context.Context, customerEmail string) ([]entities.VipBundle, error) {
+	return nil, nil
+}
+
+func (r *vipBundleRepositoryMock) FindWithIncompleteRollback(ctx context.Context) ([]entities.VipBundle, error) {
+	return nil, nil
+}
+
+func (r *vipBundleRepositoryMock) UpdateByID(
+	ctx context.Context,
+	vipBundleID uuid.UUID,
+	updateFn func(ctx context.Context, vipBundle entities.VipBundle) (entities.VipBundle, error),
+) (entities.VipBundle, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	vb, ok := r.vipBundles[vipBundleID]
+	if !ok {
+		return entities.VipBundle{}, errVipBundleNotFound
+	}
+
+	updated, err := updateFn(ctx, copyVipBundle(vb))
+	if err != nil {
+		return entities.VipBundle{}, err
+	}
+	r.vipBundles[vipBundleID] = copyVipBundle(updated)
+
+	return updated, nil
+}
+
+func (r *vipBundleRepositoryMock) UpdateByBookingID(
+	ctx context.Context,
+	bookingID uuid.UUID,
+	updateFn func(ctx context.Context, vipBundle entities.VipBundle) (entities.VipBundle, error),
+) (entities.VipBundle, error) {
+	vb, err := r.GetByBookingID(ctx, bookingID)
+	if err != nil {
+		return entities.VipBundle{}, err
+	}
+
+	return r.UpdateByID(ctx, vb.VipBundleID, updateFn)
+}
+
+func copyVipBundle(vb entities.VipBundle) entities.VipBundle {
+	payload, err := json.Marshal(vb)
+	if err != nil {
+		panic(err)
+	}
+
+	var c entities.VipBundle
+	if err := json.Unmarshal(payload, &c); err != nil {
+		panic(err)
+	}
+
+	return c
+}
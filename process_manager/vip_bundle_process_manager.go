@@ -2,240 +2,1048 @@ package process_manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"tickets/entities"
 	"tickets/message/contracts"
+	"tickets/saga"
+	"time"
 
+	"github.com/ThreeDotsLabs/go-event-driven/common/log"
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/google/uuid"
 )
 
+// VipBundleDeadlines is the time each step has to complete, before the bundle is rolled back.
+type VipBundleDeadlines struct {
+	ShowBooking   time.Duration
+	InboundFlight time.Duration
+	ReturnFlight  time.Duration
+	Hotel         time.Duration
+	Taxi          time.Duration
+
+	// Compensation is the time to confirm all compensations of a rolled back bundle
+	Compensation time.Duration
+}
+
+func DefaultVipBundleDeadlines() VipBundleDeadlines {
+	return VipBundleDeadlines{
+		ShowBooking:   15 * time.Minute,
+		InboundFlight: 10 * time.Minute,
+		ReturnFlight:  10 * time.Minute,
+		Hotel:         10 * time.Minute,
+		Taxi:          10 * time.Minute,
+		Compensation:  30 * time.Minute,
+	}
+}
+
+func (d VipBundleDeadlines) forStep(step entities.VipBundleStep) time.Duration {
+	switch step {
+	case entities.VipBundleStepShowBooking:
+		return d.ShowBooking
+	case entities.VipBundleStepInboundFlight:
+		return d.InboundFlight
+	case entities.VipBundleStepReturnFlight:
+		return d.ReturnFlight
+	case entities.VipBundleStepHotel:
+		return d.Hotel
+	case entities.VipBundleStepTaxi:
+		return d.Taxi
+	case entities.VipBundleStepCompensation:
+		return d.Compensation
+	default:
+		return 0
+	}
+}
+
+// States of the VIP bundle saga, they are stored in entities.VipBundle.
+const (
+	stateInitialized         = saga.State(entities.VipBundleStateInitialized)
+	stateBookingShow         = saga.State(entities.VipBundleStateBookingShow)
+	stateShowBooked          = saga.State(entities.VipBundleStateShowBooked)
+	stateInboundFlightBooked = saga.State(entities.VipBundleStateInboundFlightBooked)
+	stateReturnFlightBooked  = saga.State(entities.VipBundleStateReturnFlightBooked)
+	stateFlightsBooked       = saga.State(entities.VipBundleStateFlightsBooked)
+	stateHotelBooked         = saga.State(entities.VipBundleStateHotelBooked)
+	stateFinalized           = saga.State(entities.VipBundleStateFinalized)
+	stateFailed              = saga.State(entities.VipBundleStateFailed)
+	stateCanceled            = saga.State(entities.VipBundleStateCanceled)
+)
+
+// vipBundleTransitions is the VIP bundle flow from exercise.md.
+// Every step can fail or be canceled by the customer, completed steps are then compensated.
+var vipBundleTransitions = map[saga.State][]saga.State{
+	stateInitialized: {
+		stateBookingShow,
+		stateFailed,
+		stateCanceled,
+	},
+	stateBookingShow: {
+		stateShowBooked,
+		stateFailed,
+		stateCanceled,
+	},
+	stateShowBooked: {
+		stateInboundFlightBooked,
+		stateReturnFlightBooked,
+		stateFailed,
+		stateCanceled,
+	},
+	stateInboundFlightBooked: {
+		stateFlightsBooked,
+		stateFailed,
+		stateCanceled,
+	},
+	stateReturnFlightBooked: {
+		stateFlightsBooked,
+		// bundles which booked flights one after another wait for the taxi in this state
+		stateFinalized,
+		stateFailed,
+		stateCanceled,
+	},
+	stateFlightsBooked: {
+		stateHotelBooked,
+		stateFinalized,
+		stateFailed,
+		stateCanceled,
+	},
+	stateHotelBooked: {
+		stateFinalized,
+		stateFailed,
+		stateCanceled,
+	},
+	stateFinalized: {
+		stateCanceled,
+	},
+}
+
+// isRolledBack returns true if completed steps of the bundle were compensated.
+// Steps completing after the rollback are compensated by their rules.
+func isRolledBack(state saga.State) bool {
+	return state == stateFailed || state == stateCanceled
+}
+
+// DefaultTaxiCapacity is the number of passengers fitting in one taxi.
+const DefaultTaxiCapacity = 4
+
 type VipBundleProcessManager struct {
-	commandBus *cqrs.CommandBus
-	eventBus   *cqrs.EventBus
-	repository contracts.VipBundleRepository
+	saga         *saga.Saga[entities.VipBundle]
+	repository   contracts.VipBundleRepository
+	deadlines    VipBundleDeadlines
+	taxiCapacity int
 }
 
 func NewVipBundleProcessManager(
 	commandBus *cqrs.CommandBus,
 	eventBus *cqrs.EventBus,
 	repository contracts.VipBundleRepository,
+	scheduler contracts.Scheduler,
+	deadlines VipBundleDeadlines,
+	taxiCapacity int,
 ) *VipBundleProcessManager {
-	return &VipBundleProcessManager{
-		commandBus: commandBus,
-		eventBus:   eventBus,
-		repository: repository,
+	if taxiCapacity <= 0 {
+		taxiCapacity = DefaultTaxiCapacity
 	}
+
+	pm := &VipBundleProcessManager{
+		saga: saga.New(saga.Config[entities.VipBundle]{
+			Name:        "vip_bundle",
+			Initial:     stateInitialized,
+			Transitions: vipBundleTransitions,
+			State: func(vb entities.VipBundle) saga.State {
+				return saga.State(vb.CurrentState())
+			},
+			Record: func(vb *entities.VipBundle, transition saga.Transition) {
+				vb.State = entities.VipBundleState(transition.To)
+				vb.History = append(vb.History, entities.VipBundleTransition{
+					From:  entities.VipBundleState(transition.From),
+					To:    entities.VipBundleState(transition.To),
+					Event: transition.Event,
+					At:    transition.At,
+				})
+			},
+			Store:      repository,
+			CommandBus: commandBus,
+			EventBus:   eventBus,
+			Scheduler:  scheduler,
+		}),
+		repository:   repository,
+		deadlines:    deadlines,
+		taxiCapacity: taxiCapacity,
+	}
+	pm.defineSaga()
+
+	return pm
 }
 
-func (v VipBundleProcessManager) OnVipBundleInitialized(ctx context.Context, event *entities.VipBundleInitialized_v1) error {
-	vb, err := v.repository.Get(ctx, event.VipBundleID)
-	if err != nil {
-		return err
+// handle acks events which are not accepted in the current state of the bundle.
+// The messages of the state were sent in the transaction which stored it, so nothing is lost,
+// and redelivering the event wouldn't change the state. Undeclared transitions are returned as errors.
+func (v VipBundleProcessManager) handle(ctx context.Context, event any) error {
+	err := v.saga.Handle(ctx, event)
+	if errors.Is(err, saga.ErrEventNotAccepted) {
+		log.FromContext(ctx).WithError(err).Warn("Ignoring VIP bundle event")
+		return nil
 	}
 
-	return v.commandBus.Send(ctx, entities.BookShowTickets{
-		BookingID:       vb.BookingID,
-		CustomerEmail:   vb.CustomerEmail,
-		NumberOfTickets: vb.NumberOfTickets,
-		ShowId:          vb.ShowId,
-	})
+	return err
+}
+
+func (v VipBundleProcessManager) OnVipBundleInitialized(ctx context.Context, event *entities.VipBundleInitialized_v1) error {
+	return v.handle(ctx, event)
 }
 
 func (v VipBundleProcessManager) OnBookingMade(ctx context.Context, event *entities.BookingMade_v1) error {
-	vb, err := v.repository.UpdateByBookingID(
-		ctx,
-		event.BookingID,
-		func(vipBundle entities.VipBundle) (entities.VipBundle, error) {
-			vipBundle.BookingMadeAt = &event.Header.PublishedAt
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnTicketBookingConfirmed(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnBookingFailed(ctx context.Context, event *entities.BookingFailed_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnFlightBooked(ctx context.Context, event *entities.FlightBooked_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnFlightBookingFailed(ctx context.Context, event *entities.FlightBookingFailed_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnTaxiBooked(ctx context.Context, event *entities.TaxiBooked_v1) error {
+	return v.handle(ctx, event)
+}
 
-			return vipBundle, nil
+func (v VipBundleProcessManager) OnTaxiBookingFailed(ctx context.Context, event *entities.TaxiBookingFailed_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnCancellationRequested(ctx context.Context, event *entities.VipBundleCancellationRequested_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnTicketRefunded(ctx context.Context, event *entities.TicketRefunded_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnFlightTicketsCanceled(ctx context.Context, event *entities.FlightTicketsCanceled_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnTaxiBookingCanceled(ctx context.Context, event *entities.TaxiBookingCanceled_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnHotelBooked(ctx context.Context, event *entities.HotelBooked_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnHotelBookingFailed(ctx context.Context, event *entities.HotelBookingFailed_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnHotelBookingCanceled(ctx context.Context, event *entities.HotelBookingCanceled_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) OnStepTimedOut(ctx context.Context, event *entities.VipBundleStepTimedOut_v1) error {
+	return v.handle(ctx, event)
+}
+
+func (v VipBundleProcessManager) defineSaga() {
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.VipBundleInitialized_v1]{
+		From: []saga.State{stateInitialized, stateCanceled},
+		InstanceID: func(ctx context.Context, event *entities.VipBundleInitialized_v1) (uuid.UUID, error) {
+			return event.VipBundleID, nil
 		},
-	)
-	if err != nil {
-		return err
-	}
+		Apply: func(vb *entities.VipBundle, event *entities.VipBundleInitialized_v1, current saga.State) (saga.State, error) {
+			if current == stateCanceled {
+				// canceled before the process started
+				return current, nil
+			}
 
-	return v.commandBus.Send(ctx, entities.BookFlight{
-		CustomerEmail:  vb.CustomerEmail,
-		FlightID:       vb.InboundFlightID,
-		Passengers:     vb.Passengers,
-		ReferenceID:    vb.VipBundleID.String(),
-		IdempotencyKey: uuid.NewString(),
+			return stateBookingShow, nil
+		},
+		Emit: func(vb entities.VipBundle, event *entities.VipBundleInitialized_v1, transition saga.Transition) saga.Outcome {
+			if transition.To != stateBookingShow {
+				return saga.Outcome{}
+			}
+
+			return saga.Outcome{
+				Commands: []any{
+					entities.BookShowTickets{
+						BookingID:       vb.BookingID,
+						CustomerEmail:   vb.CustomerEmail,
+						NumberOfTickets: vb.NumberOfTickets,
+						ShowId:          vb.ShowId,
+					},
+				},
+				Deadlines: v.deadline(vb, entities.VipBundleStepShowBooking),
+			}
+		},
 	})
-}
 
-func (v VipBundleProcessManager) OnTicketBookingConfirmed(ctx context.Context, event *entities.TicketBookingConfirmed_v1) error {
-	_, err := v.repository.UpdateByBookingID(
-		ctx,
-		uuid.MustParse(event.BookingID),
-		func(vipBundle entities.VipBundle) (entities.VipBundle, error) {
-			eventTicketID := uuid.MustParse(event.TicketID)
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.BookingMade_v1]{
+		From: []saga.State{
+			stateBookingShow,
+			stateFailed,
+			stateCanceled,
+		},
+		InstanceID: func(ctx context.Context, event *entities.BookingMade_v1) (uuid.UUID, error) {
+			return v.vipBundleIDByBookingID(ctx, event.BookingID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.BookingMade_v1, current saga.State) (saga.State, error) {
+			vb.BookingMadeAt = &event.Header.PublishedAt
+
+			if isRolledBack(current) {
+				// tickets of a rolled back bundle are refunded when they are confirmed
+				return current, nil
+			}
+
+			vb.SetFlightAttempt(entities.FlightLegInbound, vb.InboundFlightID, entities.VipBundleFlightAttemptBooking, "")
+			vb.SetFlightAttempt(entities.FlightLegReturn, vb.ReturnFlightID, entities.VipBundleFlightAttemptBooking, "")
+
+			return stateShowBooked, nil
+		},
+		Emit: func(vb entities.VipBundle, event *entities.BookingMade_v1, transition saga.Transition) saga.Outcome {
+			if transition.To != stateShowBooked {
+				return saga.Outcome{}
+			}
+
+			// both flights are booked at once, the taxi is booked when both succeed
+			return saga.Outcome{
+				Commands: []any{
+					bookFlight(vb, entities.FlightLegInbound),
+					bookFlight(vb, entities.FlightLegReturn),
+				},
+				Deadlines: append(
+					v.flightDeadline(vb, entities.FlightLegInbound),
+					v.flightDeadline(vb, entities.FlightLegReturn)...,
+				),
+			}
+		},
+	})
+
+	// tickets are confirmed independently of the booking, so they are accepted in all states
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.TicketBookingConfirmed_v1]{
+		InstanceID: func(ctx context.Context, event *entities.TicketBookingConfirmed_v1) (uuid.UUID, error) {
+			bookingID, err := uuid.Parse(event.BookingID)
+			if err != nil {
+				return uuid.Nil, fmt.Errorf("invalid booking id: %w", err)
+			}
 
-			for _, ticketID := range vipBundle.TicketIDs {
+			return v.vipBundleIDByBookingID(ctx, bookingID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.TicketBookingConfirmed_v1, current saga.State) (saga.State, error) {
+			eventTicketID, err := uuid.Parse(event.TicketID)
+			if err != nil {
+				return current, fmt.Errorf("invalid ticket id: %w", err)
+			}
+
+			for _, ticketID := range vb.TicketIDs {
 				if ticketID == eventTicketID {
 					// re-delivery (already stored)
-					continue
+					return current, nil
 				}
 			}
 
-			vipBundle.TicketIDs = append(vipBundle.TicketIDs, eventTicketID)
+			vb.TicketIDs = append(vb.TicketIDs, eventTicketID)
+
+			if isRolledBack(current) {
+				vb.StartCompensation(entities.VipBundleCompensationRefundTicket, eventTicketID)
+			}
 
-			return vipBundle, nil
+			return current, nil
 		},
-	)
-	if err != nil {
-		return err
-	}
+		Emit: func(vb entities.VipBundle, event *entities.TicketBookingConfirmed_v1, transition saga.Transition) saga.Outcome {
+			if !isRolledBack(transition.To) {
+				return saga.Outcome{}
+			}
 
-	return nil
-}
+			// the ticket ID was validated by Apply
+			ticketID, err := uuid.Parse(event.TicketID)
+			if err != nil {
+				return saga.Outcome{}
+			}
 
-func (v VipBundleProcessManager) OnBookingFailed(ctx context.Context, event *entities.BookingFailed_v1) error {
-	vb, err := v.repository.GetByBookingID(ctx, event.BookingID)
-	if err != nil {
-		return err
-	}
+			// the ticket was confirmed after the bundle was rolled back
+			return saga.Outcome{
+				Commands: []any{refundTicket(vb, ticketID)},
+			}
+		},
+	})
 
-	return v.rollbackProcess(ctx, vb.VipBundleID)
-}
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.FlightBooked_v1]{
+		From: []saga.State{
+			stateShowBooked,
+			stateInboundFlightBooked,
+			stateReturnFlightBooked,
+			stateFailed,
+			stateCanceled,
+		},
+		InstanceID: func(ctx context.Context, event *entities.FlightBooked_v1) (uuid.UUID, error) {
+			return uuid.Parse(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.FlightBooked_v1, current saga.State) (saga.State, error) {
+			leg, err := flightLeg(*vb, event.Leg, event.FlightID)
+			if err != nil {
+				return current, err
+			}
 
-func (v VipBundleProcessManager) OnFlightBooked(ctx context.Context, event *entities.FlightBooked_v1) error {
-	vb, err := v.repository.UpdateByID(
-		ctx,
-		uuid.MustParse(event.ReferenceID),
-		func(vipBundle entities.VipBundle) (entities.VipBundle, error) {
-			if vipBundle.InboundFlightID == event.FlightID {
-				vipBundle.InboundFlightBookedAt = &event.Header.PublishedAt
-				vipBundle.InboundFlightTicketsIDs = event.TicketIDs
+			if isRolledBack(current) {
+				vb.StartCompensation(entities.VipBundleCompensationCancelFlight, event.FlightID)
+				return current, nil
 			}
-			if vipBundle.ReturnFlightID == event.FlightID {
-				vipBundle.ReturnFlightBookedAt = &event.Header.PublishedAt
-				vipBundle.ReturnFlightTicketsIDs = event.TicketIDs
+
+			vb.SetFlightAttempt(leg, event.FlightID, entities.VipBundleFlightAttemptBooked, "")
+
+			switch leg {
+			case entities.FlightLegInbound:
+				vb.InboundFlightBookedAt = &event.Header.PublishedAt
+				vb.InboundFlightTicketsIDs = event.TicketIDs
+			case entities.FlightLegReturn:
+				vb.ReturnFlightBookedAt = &event.Header.PublishedAt
+				vb.ReturnFlightTicketsIDs = event.TicketIDs
 			}
 
-			return vipBundle, nil
+			switch {
+			case vb.InboundFlightBookedAt != nil && vb.ReturnFlightBookedAt != nil:
+				vb.Taxis = allocateTaxis(*vb, v.taxiCapacity)
+				return stateFlightsBooked, nil
+			case vb.InboundFlightBookedAt != nil:
+				return stateInboundFlightBooked, nil
+			default:
+				return stateReturnFlightBooked, nil
+			}
 		},
-	)
-	if err != nil {
-		return err
-	}
+		Emit: func(vb entities.VipBundle, event *entities.FlightBooked_v1, transition saga.Transition) saga.Outcome {
+			// the leg was validated by Apply
+			leg, _ := flightLeg(vb, event.Leg, event.FlightID)
 
-	switch {
-	case vb.InboundFlightBookedAt != nil && vb.ReturnFlightBookedAt == nil:
-		return v.commandBus.Send(ctx, entities.BookFlight{
-			CustomerEmail:  vb.CustomerEmail,
-			FlightID:       vb.ReturnFlightID,
-			Passengers:     vb.Passengers,
-			ReferenceID:    vb.VipBundleID.String(),
-			IdempotencyKey: uuid.NewString(),
-		})
-	case vb.InboundFlightBookedAt != nil && vb.ReturnFlightBookedAt != nil:
-		return v.commandBus.Send(ctx, entities.BookTaxi{
-			CustomerEmail:      vb.CustomerEmail,
-			CustomerName:       vb.Passengers[0],
-			NumberOfPassengers: vb.NumberOfTickets,
-			ReferenceID:        vb.VipBundleID.String(),
-			IdempotencyKey:     uuid.NewString(),
-		})
-	default:
-		return fmt.Errorf(
-			"unsupported state: InboundFlightBookedAt: %v, ReturnFlightBookedAt: %v",
-			vb.InboundFlightBookedAt,
-			vb.ReturnFlightBookedAt,
-		)
-	}
-}
+			switch transition.To {
+			case stateInboundFlightBooked, stateReturnFlightBooked:
+				// waiting for the other flight
+				return saga.Outcome{}
+			case stateFlightsBooked:
+				if !vb.HasHotel() {
+					return v.bookTaxis(vb)
+				}
 
-func (v VipBundleProcessManager) OnFlightBookingFailed(ctx context.Context, event *entities.FlightBookingFailed_v1) error {
-	return v.rollbackProcess(ctx, uuid.MustParse(event.ReferenceID))
-}
+				return saga.Outcome{
+					Commands:  []any{bookHotel(vb)},
+					Deadlines: v.deadline(vb, entities.VipBundleStepHotel),
+				}
+			default:
+				// the flight was booked after the bundle was rolled back
+				return saga.Outcome{
+					Commands: []any{cancelFlightTickets(vb, leg, event.TicketIDs)},
+				}
+			}
+		},
+	})
+
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.HotelBooked_v1]{
+		From: []saga.State{
+			stateFlightsBooked,
+			stateFailed,
+			stateCanceled,
+		},
+		InstanceID: func(ctx context.Context, event *entities.HotelBooked_v1) (uuid.UUID, error) {
+			return uuid.Parse(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.HotelBooked_v1, current saga.State) (saga.State, error) {
+			vb.HotelBookedAt = &event.Header.PublishedAt
+			vb.HotelBookingID = &event.HotelBookingID
+
+			if isRolledBack(current) {
+				vb.StartCompensation(entities.VipBundleCompensationCancelHotel, event.HotelBookingID)
+				return current, nil
+			}
+
+			return stateHotelBooked, nil
+		},
+		Emit: func(vb entities.VipBundle, event *entities.HotelBooked_v1, transition saga.Transition) saga.Outcome {
+			if isRolledBack(transition.To) {
+				// the hotel was booked after the bundle was rolled back
+				return saga.Outcome{
+					Commands: []any{cancelHotel(vb, event.HotelBookingID)},
+				}
+			}
+
+			return v.bookTaxis(vb)
+		},
+	})
+
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.TaxiBooked_v1]{
+		From: []saga.State{
+			stateFlightsBooked,
+			stateHotelBooked,
+			stateReturnFlightBooked,
+			stateFailed,
+			stateCanceled,
+		},
+		InstanceID: func(ctx context.Context, event *entities.TaxiBooked_v1) (uuid.UUID, error) {
+			return uuid.Parse(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.TaxiBooked_v1, current saga.State) (saga.State, error) {
+			vb.SetTaxiBooked(event.TaxiIndex, event.TaxiBookingID, event.Header.PublishedAt)
+
+			if isRolledBack(current) {
+				vb.StartCompensation(entities.VipBundleCompensationCancelTaxi, event.TaxiBookingID)
+				return current, nil
+			}
+
+			if !vb.AllTaxisBooked() {
+				return current, nil
+			}
 
-func (v VipBundleProcessManager) OnTaxiBooked(ctx context.Context, event *entities.TaxiBooked_v1) error {
-	vb, err := v.repository.UpdateByID(
-		ctx,
-		uuid.MustParse(event.ReferenceID),
-		func(vb entities.VipBundle) (entities.VipBundle, error) {
 			vb.TaxiBookedAt = &event.Header.PublishedAt
-			vb.TaxiBookingID = &event.TaxiBookingID
+			vb.TaxiBookingID = vb.Taxis[0].TaxiBookingID
+			vb.IsFinalized = true
+
+			return stateFinalized, nil
+		},
+		Emit: func(vb entities.VipBundle, event *entities.TaxiBooked_v1, transition saga.Transition) saga.Outcome {
+			if isRolledBack(transition.To) {
+				// the taxi was booked after the bundle was rolled back
+				return saga.Outcome{
+					Commands: []any{cancelTaxi(vb, event.TaxiBookingID)},
+				}
+			}
+			if transition.To != stateFinalized {
+				// waiting for other taxis
+				return saga.Outcome{}
+			}
+
+			return saga.Outcome{
+				Events: []any{
+					entities.VipBundleFinalized_v1{
+						Header:      entities.NewEventHeaderWithIdempotencyKey(idempotencyKey(vb, "finalized")),
+						VipBundleID: vb.VipBundleID,
+					},
+				},
+			}
+		},
+	})
+
+	// the cancellation policy is checked when the cancellation is requested
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.VipBundleCancellationRequested_v1]{
+		InstanceID: func(ctx context.Context, event *entities.VipBundleCancellationRequested_v1) (uuid.UUID, error) {
+			return event.VipBundleID, nil
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.VipBundleCancellationRequested_v1, current saga.State) (saga.State, error) {
+			if isRolledBack(current) {
+				return current, nil
+			}
 
 			vb.IsFinalized = true
+			vb.Canceled = true
+			startCompensations(vb)
 
-			return vb, nil
+			return stateCanceled, nil
 		},
-	)
-	if err != nil {
-		return err
-	}
+		Emit: func(vb entities.VipBundle, event *entities.VipBundleCancellationRequested_v1, transition saga.Transition) saga.Outcome {
+			if transition.From == transition.To {
+				return saga.Outcome{}
+			}
+
+			return saga.Outcome{
+				Commands:  compensations(vb),
+				Deadlines: v.deadline(vb, entities.VipBundleStepCompensation),
+			}
+		},
+	})
+
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.TicketRefunded_v1]{
+		InstanceID: func(ctx context.Context, event *entities.TicketRefunded_v1) (uuid.UUID, error) {
+			return referenceID(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.TicketRefunded_v1, current saga.State) (saga.State, error) {
+			ticketID, err := uuid.Parse(event.TicketID)
+			if err != nil {
+				return current, fmt.Errorf("invalid ticket id: %w", err)
+			}
+
+			vb.SetCompensationStatus(entities.VipBundleCompensationRefundTicket, ticketID, entities.VipBundleCompensationDone)
+
+			return current, nil
+		},
+	})
+
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.FlightTicketsCanceled_v1]{
+		InstanceID: func(ctx context.Context, event *entities.FlightTicketsCanceled_v1) (uuid.UUID, error) {
+			return referenceID(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.FlightTicketsCanceled_v1, current saga.State) (saga.State, error) {
+			vb.SetCompensationStatus(entities.VipBundleCompensationCancelFlight, event.FlightID, entities.VipBundleCompensationDone)
+
+			return current, nil
+		},
+	})
 
-	return v.eventBus.Publish(ctx, entities.VipBundleFinalized_v1{
-		Header:      entities.NewEventHeader(),
-		VipBundleID: vb.VipBundleID,
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.HotelBookingCanceled_v1]{
+		InstanceID: func(ctx context.Context, event *entities.HotelBookingCanceled_v1) (uuid.UUID, error) {
+			return referenceID(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.HotelBookingCanceled_v1, current saga.State) (saga.State, error) {
+			vb.SetCompensationStatus(entities.VipBundleCompensationCancelHotel, event.HotelBookingID, entities.VipBundleCompensationDone)
+
+			return current, nil
+		},
+	})
+
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.TaxiBookingCanceled_v1]{
+		InstanceID: func(ctx context.Context, event *entities.TaxiBookingCanceled_v1) (uuid.UUID, error) {
+			return referenceID(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.TaxiBookingCanceled_v1, current saga.State) (saga.State, error) {
+			vb.SetCompensationStatus(entities.VipBundleCompensationCancelTaxi, event.TaxiBookingID, entities.VipBundleCompensationDone)
+
+			return current, nil
+		},
+	})
+
+	saga.On(v.saga, failureRule(
+		v,
+		func(ctx context.Context, event *entities.BookingFailed_v1) (uuid.UUID, error) {
+			return v.vipBundleIDByBookingID(ctx, event.BookingID)
+		},
+		func(vb entities.VipBundle, event *entities.BookingFailed_v1) (string, entities.VipBundleStep, bool) {
+			return "show booking failed: " + event.FailureReason, entities.VipBundleStepShowBooking, true
+		},
+	))
+
+	// a full flight is replaced by the next alternative of its leg, the bundle fails when none is left
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.FlightBookingFailed_v1]{
+		InstanceID: func(ctx context.Context, event *entities.FlightBookingFailed_v1) (uuid.UUID, error) {
+			return uuid.Parse(event.ReferenceID)
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.FlightBookingFailed_v1, current saga.State) (saga.State, error) {
+			leg, err := flightLeg(*vb, event.Leg, event.FlightID)
+			if err != nil {
+				leg = entities.FlightLegInbound
+			}
+			reason := fmt.Sprintf("%s flight %s booking failed: %s", leg, event.FlightID, event.FailureReason)
+
+			if err != nil || current == stateFinalized || isRolledBack(current) {
+				return fail(vb, current, reason, flightStep(leg)), nil
+			}
+
+			vb.SetFlightAttempt(leg, event.FlightID, entities.VipBundleFlightAttemptFailed, event.FailureReason)
+
+			if event.FlightID != vb.AttemptedFlightID(leg) {
+				// an earlier candidate, the alternative is already being booked
+				return current, nil
+			}
+
+			if next, ok := vb.NextFlightAlternative(leg); ok {
+				vb.SetAttemptedFlightID(leg, next)
+				vb.SetFlightAttempt(leg, next, entities.VipBundleFlightAttemptBooking, "")
+				return current, nil
+			}
+
+			// the other flight may still be booked, it's canceled when its FlightBooked_v1 arrives
+			return fail(vb, current, reason, flightStep(leg)), nil
+		},
+		Emit: func(vb entities.VipBundle, event *entities.FlightBookingFailed_v1, transition saga.Transition) saga.Outcome {
+			if transition.From != transition.To {
+				return v.failureOutcome(vb, transition)
+			}
+			if transition.To == stateFinalized || isRolledBack(transition.To) {
+				return saga.Outcome{}
+			}
+
+			leg, _ := flightLeg(vb, event.Leg, event.FlightID)
+			if vb.IsStepCompleted(flightStep(leg)) {
+				return saga.Outcome{}
+			}
+
+			// failures of earlier candidates send the same command and deadline again,
+			// they are deduplicated by their idempotency keys
+			return saga.Outcome{
+				Commands:  []any{bookFlight(vb, leg)},
+				Deadlines: v.flightDeadline(vb, leg),
+			}
+		},
+	})
+
+	saga.On(v.saga, failureRule(
+		v,
+		func(ctx context.Context, event *entities.HotelBookingFailed_v1) (uuid.UUID, error) {
+			return uuid.Parse(event.ReferenceID)
+		},
+		func(vb entities.VipBundle, event *entities.HotelBookingFailed_v1) (string, entities.VipBundleStep, bool) {
+			return fmt.Sprintf("hotel %s booking failed: %s", event.HotelID, event.FailureReason), entities.VipBundleStepHotel, true
+		},
+	))
+
+	saga.On(v.saga, failureRule(
+		v,
+		func(ctx context.Context, event *entities.TaxiBookingFailed_v1) (uuid.UUID, error) {
+			return uuid.Parse(event.ReferenceID)
+		},
+		func(vb entities.VipBundle, event *entities.TaxiBookingFailed_v1) (string, entities.VipBundleStep, bool) {
+			// taxis which are still being booked are canceled when their TaxiBooked_v1 arrives
+			return fmt.Sprintf("taxi %d booking failed: %s", event.TaxiIndex, event.FailureReason), entities.VipBundleStepTaxi, true
+		},
+	))
+
+	saga.On(v.saga, saga.Rule[entities.VipBundle, entities.VipBundleStepTimedOut_v1]{
+		InstanceID: func(ctx context.Context, event *entities.VipBundleStepTimedOut_v1) (uuid.UUID, error) {
+			return event.VipBundleID, nil
+		},
+		Apply: func(vb *entities.VipBundle, event *entities.VipBundleStepTimedOut_v1, current saga.State) (saga.State, error) {
+			if event.Step == entities.VipBundleStepCompensation {
+				// compensations not confirmed in time need attention of ops, late confirmations still mark them as done
+				for _, c := range vb.Compensations {
+					if c.Status == entities.VipBundleCompensationPending {
+						vb.SetCompensationStatus(c.Action, c.ReferenceID, entities.VipBundleCompensationFailed)
+					}
+				}
+
+				return current, nil
+			}
+
+			// deadlines are not canceled, so they are ignored when the step has completed in time
+			if vb.IsStepCompleted(event.Step) {
+				return current, nil
+			}
+			if leg, ok := stepFlightLeg(event.Step); ok && event.FlightID != uuid.Nil && event.FlightID != vb.AttemptedFlightID(leg) {
+				// the flight was replaced by an alternative, which has a deadline of its own
+				return current, nil
+			}
+
+			return fail(vb, current, fmt.Sprintf("step %s timed out", event.Step), event.Step), nil
+		},
+		Emit: func(vb entities.VipBundle, event *entities.VipBundleStepTimedOut_v1, transition saga.Transition) saga.Outcome {
+			return v.failureOutcome(vb, transition)
+		},
 	})
 }
 
-func (v VipBundleProcessManager) OnTaxiBookingFailed(ctx context.Context, event *entities.TaxiBookingFailed_v1) error {
-	return v.rollbackProcess(ctx, uuid.MustParse(event.ReferenceID))
+// failureRule fails the bundle and compensates its completed steps.
+// Failures of finalized or already rolled back bundles are ignored.
+func failureRule[E any](
+	v VipBundleProcessManager,
+	instanceID func(ctx context.Context, event *E) (uuid.UUID, error),
+	failure func(vb entities.VipBundle, event *E) (reason string, step entities.VipBundleStep, failed bool),
+) saga.Rule[entities.VipBundle, E] {
+	return saga.Rule[entities.VipBundle, E]{
+		InstanceID: instanceID,
+		Apply: func(vb *entities.VipBundle, event *E, current saga.State) (saga.State, error) {
+			reason, step, failed := failure(*vb, event)
+			if !failed {
+				return current, nil
+			}
+
+			return fail(vb, current, reason, step), nil
+		},
+		Emit: func(vb entities.VipBundle, event *E, transition saga.Transition) saga.Outcome {
+			return v.failureOutcome(vb, transition)
+		},
+	}
 }
 
-func (v VipBundleProcessManager) rollbackProcess(ctx context.Context, vipBundleID uuid.UUID) error {
-	vb, err := v.repository.Get(ctx, vipBundleID)
-	if err != nil {
-		return err
+// fail marks the bundle as failed and starts compensations of its completed steps.
+// Finalized and already rolled back bundles are kept in their state.
+func fail(vb *entities.VipBundle, current saga.State, reason string, step entities.VipBundleStep) saga.State {
+	if current == stateFinalized || isRolledBack(current) {
+		return current
 	}
 
-	if vb.BookingMadeAt != nil {
-		if err := v.rollbackTickets(ctx, vb); err != nil {
-			return err
-		}
+	vb.IsFinalized = true
+	vb.Failed = true
+	vb.FailureReason = reason
+	vb.FailedStep = step
+	startCompensations(vb)
+
+	return stateFailed
+}
+
+// failureOutcome compensates the bundle which has just failed.
+func (v VipBundleProcessManager) failureOutcome(vb entities.VipBundle, transition saga.Transition) saga.Outcome {
+	if transition.From == transition.To {
+		return saga.Outcome{}
+	}
+
+	return saga.Outcome{
+		Commands: compensations(vb),
+		Events: []any{
+			entities.VipBundleFailed_v1{
+				Header:        entities.NewEventHeaderWithIdempotencyKey(idempotencyKey(vb, "failed")),
+				VipBundleID:   vb.VipBundleID,
+				BookingID:     vb.BookingID,
+				FailureReason: vb.FailureReason,
+				FailedStep:    vb.FailedStep,
+			},
+		},
+		Deadlines: v.deadline(vb, entities.VipBundleStepCompensation),
+	}
+}
+
+// startCompensations tracks compensations sent by compensations.
+func startCompensations(vb *entities.VipBundle) {
+	for _, ticketID := range vb.TicketIDs {
+		vb.StartCompensation(entities.VipBundleCompensationRefundTicket, ticketID)
 	}
 	if vb.InboundFlightBookedAt != nil {
-		if err := v.commandBus.Send(ctx, entities.CancelFlightTickets{
-			FlightTicketIDs: vb.InboundFlightTicketsIDs,
-		}); err != nil {
-			return err
-		}
+		vb.StartCompensation(entities.VipBundleCompensationCancelFlight, vb.AttemptedFlightID(entities.FlightLegInbound))
 	}
 	if vb.ReturnFlightBookedAt != nil {
-		if err := v.commandBus.Send(ctx, entities.CancelFlightTickets{
-			FlightTicketIDs: vb.ReturnFlightTicketsIDs,
-		}); err != nil {
-			return err
-		}
+		vb.StartCompensation(entities.VipBundleCompensationCancelFlight, vb.AttemptedFlightID(entities.FlightLegReturn))
 	}
+	if vb.HotelBookingID != nil {
+		vb.StartCompensation(entities.VipBundleCompensationCancelHotel, *vb.HotelBookingID)
+	}
+	for _, taxiBookingID := range vb.BookedTaxiIDs() {
+		vb.StartCompensation(entities.VipBundleCompensationCancelTaxi, taxiBookingID)
+	}
+}
 
-	_, err = v.repository.UpdateByID(
-		ctx,
-		vb.VipBundleID,
-		func(vb entities.VipBundle) (entities.VipBundle, error) {
-			vb.IsFinalized = true
-			vb.Failed = true
-			return vb, nil
+// compensations undo the completed steps of the bundle.
+// Tickets confirmed later are refunded by the TicketBookingConfirmed_v1 rule.
+func compensations(vb entities.VipBundle) []any {
+	var commands []any
+	for _, ticketID := range vb.TicketIDs {
+		commands = append(commands, refundTicket(vb, ticketID))
+	}
+	if vb.InboundFlightBookedAt != nil {
+		commands = append(commands, cancelFlightTickets(vb, entities.FlightLegInbound, vb.InboundFlightTicketsIDs))
+	}
+	if vb.ReturnFlightBookedAt != nil {
+		commands = append(commands, cancelFlightTickets(vb, entities.FlightLegReturn, vb.ReturnFlightTicketsIDs))
+	}
+	if vb.HotelBookingID != nil {
+		commands = append(commands, cancelHotel(vb, *vb.HotelBookingID))
+	}
+	for _, taxiBookingID := range vb.BookedTaxiIDs() {
+		commands = append(commands, cancelTaxi(vb, taxiBookingID))
+	}
+
+	return commands
+}
+
+// referenceID returns the VIP bundle ID from the reference of compensation confirmations,
+// confirmations without the reference don't belong to any bundle.
+func referenceID(reference string) (uuid.UUID, error) {
+	if reference == "" {
+		return uuid.Nil, nil
+	}
+
+	return uuid.Parse(reference)
+}
+
+// vipBundleIDByBookingID returns uuid.Nil for bookings which are not a part of any VIP bundle,
+// so the saga skips their events.
+func (v VipBundleProcessManager) vipBundleIDByBookingID(ctx context.Context, bookingID uuid.UUID) (uuid.UUID, error) {
+	vb, ok, err := v.repository.FindByBookingID(ctx, bookingID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !ok {
+		return uuid.Nil, nil
+	}
+
+	return vb.VipBundleID, nil
+}
+
+func (v VipBundleProcessManager) bookTaxis(vb entities.VipBundle) saga.Outcome {
+	var commands []any
+	for _, taxi := range vb.Taxis {
+		commands = append(commands, bookTaxi(vb, taxi))
+	}
+
+	return saga.Outcome{
+		Commands:  commands,
+		Deadlines: v.deadline(vb, entities.VipBundleStepTaxi),
+	}
+}
+
+func (v VipBundleProcessManager) deadline(vb entities.VipBundle, step entities.VipBundleStep) []saga.Deadline {
+	after := v.deadlines.forStep(step)
+	if after <= 0 {
+		return nil
+	}
+
+	return []saga.Deadline{
+		{
+			Event: entities.VipBundleStepTimedOut_v1{
+				Header:      entities.NewEventHeaderWithIdempotencyKey(idempotencyKey(vb, string(step), "timed_out")),
+				VipBundleID: vb.VipBundleID,
+				BookingID:   vb.BookingID,
+				Step:        step,
+			},
+			After: after,
 		},
-	)
+	}
+}
 
-	return err
+// flightDeadline is the deadline of the flight being booked for the leg.
+// Each alternative flight gets the whole time of the step.
+func (v VipBundleProcessManager) flightDeadline(vb entities.VipBundle, leg entities.FlightLeg) []saga.Deadline {
+	step := flightStep(leg)
+	flightID := vb.AttemptedFlightID(leg)
+
+	after := v.deadlines.forStep(step)
+	if after <= 0 {
+		return nil
+	}
+
+	return []saga.Deadline{
+		{
+			Event: entities.VipBundleStepTimedOut_v1{
+				Header:      entities.NewEventHeaderWithIdempotencyKey(idempotencyKey(vb, string(step), flightID.String(), "timed_out")),
+				VipBundleID: vb.VipBundleID,
+				BookingID:   vb.BookingID,
+				Step:        step,
+				FlightID:    flightID,
+			},
+			After: after,
+		},
+	}
 }
 
-func (v VipBundleProcessManager) rollbackTickets(ctx context.Context, vb entities.VipBundle) error {
-	// TicketIDs is eventually consistent, we need to ensure that all tickets are stored
-	// for alternative solutions please check "Message Ordering" module
-	if len(vb.TicketIDs) != vb.NumberOfTickets {
-		return fmt.Errorf(
-			"invalid number of tickets, expected %d, has %d: not all of TicketBookingConfirmed_v1 events were processed",
-			vb.NumberOfTickets,
-			len(vb.TicketIDs),
-		)
+// idempotencyKey is derived from the bundle and the step, so commands sent again for a redelivered event
+// carry the same key and external services don't book or refund twice.
+func idempotencyKey(vb entities.VipBundle, step string, parts ...string) string {
+	return strings.Join(append([]string{vb.VipBundleID.String(), step}, parts...), "-")
+}
+
+// flightLeg returns the leg of a flight event.
+// Events published before flights were booked in parallel have no leg, so it's found by the flight ID.
+func flightLeg(vb entities.VipBundle, leg entities.FlightLeg, flightID uuid.UUID) (entities.FlightLeg, error) {
+	if leg != "" {
+		return leg, nil
 	}
 
-	for _, ticketID := range vb.TicketIDs {
-		if err := v.commandBus.Send(ctx, entities.RefundTicket{
-			Header:   entities.NewEventHeader(),
-			TicketID: ticketID.String(),
-		}); err != nil {
-			return err
+	switch {
+	case flightID == vb.InboundFlightID || flightID == vb.AttemptedFlightID(entities.FlightLegInbound):
+		return entities.FlightLegInbound, nil
+	case flightID == vb.ReturnFlightID || flightID == vb.AttemptedFlightID(entities.FlightLegReturn):
+		return entities.FlightLegReturn, nil
+	default:
+		return "", fmt.Errorf("flight %s is not a part of vip bundle %s", flightID, vb.VipBundleID)
+	}
+}
+
+func flightStep(leg entities.FlightLeg) entities.VipBundleStep {
+	if leg == entities.FlightLegReturn {
+		return entities.VipBundleStepReturnFlight
+	}
+
+	return entities.VipBundleStepInboundFlight
+}
+
+// stepFlightLeg returns the leg booked by the step, false if the step doesn't book a flight.
+func stepFlightLeg(step entities.VipBundleStep) (entities.FlightLeg, bool) {
+	switch step {
+	case entities.VipBundleStepInboundFlight:
+		return entities.FlightLegInbound, true
+	case entities.VipBundleStepReturnFlight:
+		return entities.FlightLegReturn, true
+	default:
+		return "", false
+	}
+}
+
+func bookFlight(vb entities.VipBundle, leg entities.FlightLeg) entities.BookFlight {
+	flightID := vb.AttemptedFlightID(leg)
+
+	return entities.BookFlight{
+		CustomerEmail:  vb.CustomerEmail,
+		FlightID:       flightID,
+		Leg:            leg,
+		Passengers:     vb.Passengers,
+		ReferenceID:    vb.VipBundleID.String(),
+		IdempotencyKey: idempotencyKey(vb, string(flightStep(leg)), flightID.String()),
+	}
+}
+
+func refundTicket(vb entities.VipBundle, ticketID uuid.UUID) entities.RefundTicket {
+	return entities.RefundTicket{
+		Header:      entities.NewEventHeaderWithIdempotencyKey(idempotencyKey(vb, "refund", ticketID.String())),
+		TicketID:    ticketID.String(),
+		ReferenceID: vb.VipBundleID.String(),
+	}
+}
+
+func cancelFlightTickets(vb entities.VipBundle, leg entities.FlightLeg, ticketIDs []uuid.UUID) entities.CancelFlightTickets {
+	return entities.CancelFlightTickets{
+		FlightTicketIDs: ticketIDs,
+		FlightID:        vb.AttemptedFlightID(leg),
+		ReferenceID:     vb.VipBundleID.String(),
+		IdempotencyKey:  idempotencyKey(vb, "cancel", string(flightStep(leg))),
+	}
+}
+
+func cancelTaxi(vb entities.VipBundle, taxiBookingID uuid.UUID) entities.CancelTaxi {
+	return entities.CancelTaxi{
+		TaxiBookingID:  taxiBookingID,
+		ReferenceID:    vb.VipBundleID.String(),
+		IdempotencyKey: idempotencyKey(vb, "cancel", string(entities.VipBundleStepTaxi), taxiBookingID.String()),
+	}
+}
+
+// allocateTaxis splits passengers across taxis of the given capacity.
+// Each taxi is booked for the first of its passengers, or for the customer if passenger names are missing.
+func allocateTaxis(vb entities.VipBundle, capacity int) []entities.VipBundleTaxi {
+	passengers := numberOfPassengers(vb)
+
+	var taxis []entities.VipBundleTaxi
+	for first := 0; first < passengers; first += capacity {
+		customerName := vb.CustomerEmail
+		switch {
+		case first < len(vb.Passengers):
+			customerName = vb.Passengers[first]
+		case len(vb.Passengers) > 0:
+			customerName = vb.Passengers[0]
 		}
+
+		taxis = append(taxis, entities.VipBundleTaxi{
+			Index:              len(taxis),
+			CustomerName:       customerName,
+			NumberOfPassengers: min(capacity, passengers-first),
+		})
 	}
 
-	return nil
+	return taxis
+}
+
+func bookTaxi(vb entities.VipBundle, taxi entities.VipBundleTaxi) entities.BookTaxi {
+	return entities.BookTaxi{
+		CustomerEmail:      vb.CustomerEmail,
+		CustomerName:       taxi.CustomerName,
+		NumberOfPassengers: taxi.NumberOfPassengers,
+		ReferenceID:        vb.VipBundleID.String(),
+		IdempotencyKey:     idempotencyKey(vb, string(entities.VipBundleStepTaxi), strconv.Itoa(taxi.Index)),
+		TaxiIndex:          taxi.Index,
+	}
+}
+
+func numberOfPassengers(vb entities.VipBundle) int {
+	return max(vb.NumberOfTickets, len(vb.Passengers), 1)
+}
+
+func bookHotel(vb entities.VipBundle) entities.BookHotel {
+	return entities.BookHotel{
+		CustomerEmail:  vb.CustomerEmail,
+		HotelID:        *vb.HotelID,
+		NumberOfGuests: numberOfPassengers(vb),
+		Nights:         vb.Nights,
+		ReferenceID:    vb.VipBundleID.String(),
+		IdempotencyKey: idempotencyKey(vb, string(entities.VipBundleStepHotel)),
+	}
+}
+
+func cancelHotel(vb entities.VipBundle, hotelBookingID uuid.UUID) entities.CancelHotel {
+	return entities.CancelHotel{
+		HotelBookingID: hotelBookingID,
+		ReferenceID:    vb.VipBundleID.String(),
+		IdempotencyKey: idempotencyKey(vb, "cancel", string(entities.VipBundleStepHotel)),
+	}
 }
@@ -0,0 +1,71 @@
+package process_manager
+
+import (
+	"testing"
+	"tickets/entities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocateTaxis(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vb       entities.VipBundle
+		capacity int
+		expected []entities.VipBundleTaxi
+	}{
+		{
+			name: "group fits in one taxi",
+			vb: entities.VipBundle{
+				NumberOfTickets: 3,
+				Passengers:      []string{"Ann", "Bob", "Cid"},
+			},
+			capacity: 4,
+			expected: []entities.VipBundleTaxi{
+				{Index: 0, CustomerName: "Ann", NumberOfPassengers: 3},
+			},
+		},
+		{
+			name: "group split across taxis",
+			vb: entities.VipBundle{
+				NumberOfTickets: 5,
+				Passengers:      []string{"Ann", "Bob", "Cid", "Dan", "Eve"},
+			},
+			capacity: 2,
+			expected: []entities.VipBundleTaxi{
+				{Index: 0, CustomerName: "Ann", NumberOfPassengers: 2},
+				{Index: 1, CustomerName: "Cid", NumberOfPassengers: 2},
+				{Index: 2, CustomerName: "Eve", NumberOfPassengers: 1},
+			},
+		},
+		{
+			name: "fewer names than tickets",
+			vb: entities.VipBundle{
+				NumberOfTickets: 4,
+				Passengers:      []string{"Ann"},
+			},
+			capacity: 2,
+			expected: []entities.VipBundleTaxi{
+				{Index: 0, CustomerName: "Ann", NumberOfPassengers: 2},
+				{Index: 1, CustomerName: "Ann", NumberOfPassengers: 2},
+			},
+		},
+		{
+			name: "no passenger names",
+			vb: entities.VipBundle{
+				CustomerEmail:   "vip@example.com",
+				NumberOfTickets: 2,
+			},
+			capacity: 4,
+			expected: []entities.VipBundleTaxi{
+				{Index: 0, CustomerName: "vip@example.com", NumberOfPassengers: 2},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, allocateTaxis(tc.vb, tc.capacity))
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Package saga drives long-running processes declared as state machines.
+//
+// A saga declares its states, the allowed transitions between them and a rule for each event it handles.
+// The rule updates the instance data, picks the next state and returns the messages to emit.
+// The engine rejects illegal transitions, records the history of transitions and sends the messages
+// in the transaction in which the instance is persisted, so a persisted state always has its messages sent.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/google/uuid"
+)
+
+var ErrIllegalTransition = errors.New("illegal transition")
+
+// ErrEventNotAccepted is returned for events which are not accepted in the current state of the instance,
+// for example redelivered after the instance moved on. The messages of the state were sent when it was persisted.
+var ErrEventNotAccepted = fmt.Errorf("%w: event not accepted", ErrIllegalTransition)
+
+type State string
+
+// Transition is an entry of the saga history.
+type Transition struct {
+	From  State     `json:"from"`
+	To    State     `json:"to"`
+	Event string    `json:"event"`
+	At    time.Time `json:"at"`
+}
+
+// Outcome contains messages emitted in the transaction in which the instance is persisted.
+type Outcome struct {
+	Commands  []any
+	Events    []any
+	Deadlines []Deadline
+}
+
+// Deadline is an event published after the delay, for example to time out a step.
+type Deadline struct {
+	Event any
+	After time.Duration
+}
+
+type Store[D any] interface {
+	// UpdateByID persists the data returned by updateFn. updateFn gets ctx carrying the transaction of the update,
+	// the outcome is sent with it.
+	UpdateByID(ctx context.Context, id uuid.UUID, updateFn func(ctx context.Context, data D) (D, error)) (D, error)
+}
+
+type Scheduler interface {
+	PublishEventAfter(ctx context.Context, event any, delay time.Duration) (uuid.UUID, error)
+}
+
+type Config[D any] struct {
+	Name string
+
+	// Initial is the state of instances without a stored state.
+	Initial State
+	// Transitions lists states reachable from each state.
+	Transitions map[State][]State
+	// State returns the state stored in the instance data, empty if no state is stored.
+	State func(data D) State
+	// Record stores the state reached by the transition and appends the transition to the instance history.
+	Record func(data *D, transition Transition)
+
+	Store Store[D]
+
+	// CommandBus, EventBus and Scheduler must send messages in the transaction carried by ctx,
+	// for example through the outbox, so they are not sent when persisting the instance fails.
+	CommandBus *cqrs.CommandBus
+	EventBus   *cqrs.EventBus
+	Scheduler  Scheduler
+}
+
+func (c Config[D]) validate() error {
+	if c.Name == "" {
+		return errors.New("missing name")
+	}
+	if c.Initial == "" {
+		return errors.New("missing initial state")
+	}
+	if c.State == nil {
+		return errors.New("missing state")
+	}
+	if c.Record == nil {
+		return errors.New("missing record")
+	}
+	if c.Store == nil {
+		return errors.New("missing store")
+	}
+	if c.CommandBus == nil {
+		return errors.New("missing command bus")
+	}
+	if c.EventBus == nil {
+		return errors.New("missing event bus")
+	}
+	if c.Scheduler == nil {
+		return errors.New("missing scheduler")
+	}
+
+	return nil
+}
+
+type Saga[D any] struct {
+	config   Config[D]
+	handlers map[string]func(ctx context.Context, event any) error
+}
+
+func New[D any](config Config[D]) *Saga[D] {
+	if err := config.validate(); err != nil {
+		panic(fmt.Errorf("invalid saga config: %w", err))
+	}
+
+	return &Saga[D]{
+		config:   config,
+		handlers: map[string]func(ctx context.Context, event any) error{},
+	}
+}
+
+// Rule declares how the saga reacts to the event of type E.
+type Rule[D, E any] struct {
+	// From lists states in which the event is accepted. Empty means all states.
+	From []State
+
+	// InstanceID finds the saga instance the event belongs to.
+	// Events for which it returns uuid.Nil don't belong to any instance and are ignored.
+	InstanceID func(ctx context.Context, event *E) (uuid.UUID, error)
+
+	// Apply updates the instance data and returns the next state.
+	// Returning the current state updates only the data, without a history entry.
+	Apply func(data *D, event *E, current State) (State, error)
+
+	// Emit returns the messages to send in the transaction persisting the instance.
+	Emit func(data D, event *E, transition Transition) Outcome
+}
+
+// On registers the rule for events of type E.
+func On[D, E any](s *Saga[D], rule Rule[D, E]) {
+	if rule.InstanceID == nil {
+		panic("rule is missing InstanceID")
+	}
+
+	eventName := cqrs.StructName(new(E))
+	if _, ok := s.handlers[eventName]; ok {
+		panic(fmt.Sprintf("saga %s already handles %s", s.config.Name, eventName))
+	}
+
+	s.handlers[eventName] = func(ctx context.Context, event any) error {
+		e, ok := event.(*E)
+		if !ok {
+			return fmt.Errorf("invalid event type: %T, expected %T", event, new(E))
+		}
+
+		return handle(ctx, s, rule, eventName, e)
+	}
+}
+
+// Handle passes the event to the rule registered for its type.
+func (s *Saga[D]) Handle(ctx context.Context, event any) error {
+	eventName := cqrs.StructName(event)
+
+	handler, ok := s.handlers[eventName]
+	if !ok {
+		return fmt.Errorf("saga %s doesn't handle %s", s.config.Name, eventName)
+	}
+
+	return handler(ctx, event)
+}
+
+// stateOf returns the state of the instance, instances without a stored state are in the initial state.
+func (s *Saga[D]) stateOf(data D) State {
+	state := s.config.State(data)
+	if state == "" {
+		return s.config.Initial
+	}
+
+	return state
+}
+
+func (s *Saga[D]) isAllowed(from, to State) bool {
+	return slices.Contains(s.config.Transitions[from], to)
+}
+
+func handle[D, E any](ctx context.Context, s *Saga[D], rule Rule[D, E], eventName string, event *E) error {
+	instanceID, err := rule.InstanceID(ctx, event)
+	if err != nil {
+		return fmt.Errorf("could not find %s instance for %s: %w", s.config.Name, eventName, err)
+	}
+	if instanceID == uuid.Nil {
+		return nil
+	}
+
+	_, err = s.config.Store.UpdateByID(ctx, instanceID, func(ctx context.Context, data D) (D, error) {
+		current := s.stateOf(data)
+
+		if len(rule.From) > 0 && !slices.Contains(rule.From, current) {
+			return data, fmt.Errorf("%w: %s in state %s", ErrEventNotAccepted, eventName, current)
+		}
+
+		next := current
+		if rule.Apply != nil {
+			var err error
+			next, err = rule.Apply(&data, event, current)
+			if err != nil {
+				return data, err
+			}
+		}
+
+		transition := Transition{
+			From:  current,
+			To:    next,
+			Event: eventName,
+			At:    time.Now().UTC(),
+		}
+
+		if next != current {
+			if !s.isAllowed(current, next) {
+				return data, fmt.Errorf("%w: %s -> %s on %s", ErrIllegalTransition, current, next, eventName)
+			}
+
+			s.config.Record(&data, transition)
+		}
+
+		if rule.Emit == nil {
+			return data, nil
+		}
+
+		return data, s.dispatch(ctx, rule.Emit(data, event, transition))
+	})
+	if err != nil {
+		return fmt.Errorf("could not handle %s in saga %s: %w", eventName, s.config.Name, err)
+	}
+
+	return nil
+}
+
+func (s *Saga[D]) dispatch(ctx context.Context, outcome Outcome) error {
+	for _, deadline := range outcome.Deadlines {
+		if _, err := s.config.Scheduler.PublishEventAfter(ctx, deadline.Event, deadline.After); err != nil {
+			return fmt.Errorf("could not schedule deadline: %w", err)
+		}
+	}
+
+	for _, cmd := range outcome.Commands {
+		if err := s.config.CommandBus.Send(ctx, cmd); err != nil {
+			return fmt.Errorf("could not send command: %w", err)
+		}
+	}
+
+	for _, event := range outcome.Events {
+		if err := s.config.EventBus.Publish(ctx, event); err != nil {
+			return fmt.Errorf("could not publish event: %w", err)
+		}
+	}
+
+	return nil
+}
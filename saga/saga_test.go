@@ -0,0 +1,176 @@
+package saga_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"tickets/saga"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stateNew     saga.State = "new"
+	statePaid    saga.State = "paid"
+	stateShipped saga.State = "shipped"
+)
+
+type order struct {
+	ID     uuid.UUID
+	PaidAt *time.Time
+
+	State   saga.State
+	History []saga.Transition
+}
+
+type orderPaid struct {
+	OrderID uuid.UUID
+}
+
+type orderShipped struct {
+	OrderID uuid.UUID
+}
+
+type shipOrder struct {
+	OrderID uuid.UUID
+}
+
+type memoryStore struct {
+	orders map[uuid.UUID]order
+}
+
+func (m *memoryStore) UpdateByID(ctx context.Context, id uuid.UUID, updateFn func(context.Context, order) (order, error)) (order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return order{}, fmt.Errorf("order %s not found", id)
+	}
+
+	o, err := updateFn(ctx, o)
+	if err != nil {
+		return order{}, err
+	}
+	m.orders[id] = o
+
+	return o, nil
+}
+
+type recordingPublisher struct {
+	topics []string
+}
+
+func (r *recordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	r.topics = append(r.topics, topic)
+	return nil
+}
+
+func (r *recordingPublisher) Close() error {
+	return nil
+}
+
+type noopScheduler struct{}
+
+func (noopScheduler) PublishEventAfter(ctx context.Context, event any, delay time.Duration) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func TestSaga(t *testing.T) {
+	ctx := context.Background()
+
+	orderID := uuid.New()
+	store := &memoryStore{orders: map[uuid.UUID]order{orderID: {ID: orderID}}}
+	publisher := &recordingPublisher{}
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands." + params.CommandName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{GenerateName: cqrs.StructName},
+	})
+	require.NoError(t, err)
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events." + params.EventName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{GenerateName: cqrs.StructName},
+	})
+	require.NoError(t, err)
+
+	s := saga.New(saga.Config[order]{
+		Name:    "order",
+		Initial: stateNew,
+		Transitions: map[saga.State][]saga.State{
+			stateNew:  {statePaid},
+			statePaid: {stateShipped},
+		},
+		State: func(o order) saga.State {
+			return o.State
+		},
+		Record: func(o *order, transition saga.Transition) {
+			o.State = transition.To
+			o.History = append(o.History, transition)
+		},
+		Store:      store,
+		CommandBus: commandBus,
+		EventBus:   eventBus,
+		Scheduler:  noopScheduler{},
+	})
+
+	saga.On(s, saga.Rule[order, orderPaid]{
+		From: []saga.State{stateNew},
+		InstanceID: func(ctx context.Context, event *orderPaid) (uuid.UUID, error) {
+			return event.OrderID, nil
+		},
+		Apply: func(o *order, event *orderPaid, current saga.State) (saga.State, error) {
+			now := time.Now()
+			o.PaidAt = &now
+			return statePaid, nil
+		},
+		Emit: func(o order, event *orderPaid, transition saga.Transition) saga.Outcome {
+			return saga.Outcome{Commands: []any{shipOrder{OrderID: o.ID}}}
+		},
+	})
+	saga.On(s, saga.Rule[order, orderShipped]{
+		InstanceID: func(ctx context.Context, event *orderShipped) (uuid.UUID, error) {
+			return event.OrderID, nil
+		},
+		Apply: func(o *order, event *orderShipped, current saga.State) (saga.State, error) {
+			return stateShipped, nil
+		},
+	})
+
+	err = s.Handle(ctx, &orderShipped{OrderID: orderID})
+	assert.ErrorIs(t, err, saga.ErrIllegalTransition, "new -> shipped is not declared")
+	assert.NotErrorIs(t, err, saga.ErrEventNotAccepted, "undeclared transitions are not acked")
+
+	err = s.Handle(ctx, &orderPaid{OrderID: orderID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"commands.shipOrder"}, publisher.topics)
+
+	err = s.Handle(ctx, &orderPaid{OrderID: orderID})
+	assert.ErrorIs(t, err, saga.ErrEventNotAccepted, "order paid is accepted only in the new state")
+	assert.ErrorIs(t, err, saga.ErrIllegalTransition)
+
+	err = s.Handle(ctx, &orderShipped{OrderID: orderID})
+	require.NoError(t, err)
+
+	o := store.orders[orderID]
+	assert.NotNil(t, o.PaidAt)
+	assert.Equal(t, stateShipped, o.State)
+	require.Len(t, o.History, 2)
+	assert.Equal(t, stateNew, o.History[0].From)
+	assert.Equal(t, statePaid, o.History[0].To)
+	assert.Equal(t, "orderPaid", o.History[0].Event)
+	assert.Equal(t, stateShipped, o.History[1].To)
+
+	err = s.Handle(ctx, &orderPaid{OrderID: uuid.Nil})
+	assert.NoError(t, err, "events without instance are ignored")
+
+	err = s.Handle(ctx, &shipOrder{OrderID: orderID})
+	assert.Error(t, err, "saga doesn't handle commands")
+}
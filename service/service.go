@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	stdHTTP "net/http"
+	"tickets/api"
 	"tickets/db"
 	"tickets/db/read_model"
 	ticketsHttp "tickets/http"
@@ -13,9 +14,12 @@ import (
 	"tickets/message/contracts"
 	"tickets/message/events"
 	"tickets/message/events/outbox"
+	"tickets/message/inbox"
+	"tickets/message/scheduler"
 	"tickets/migrations"
 	"tickets/observability"
 	"tickets/process_manager"
+	"time"
 
 	_ "github.com/lib/pq"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -25,7 +29,6 @@ import (
 	watermillMessage "github.com/ThreeDotsLabs/watermill/message"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
-	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 )
@@ -40,6 +43,8 @@ type Service struct {
 	echoRouter      *echo.Echo
 	dataLake        contracts.DataLake
 	opsReadModel    read_model.OpsBookingReadModel
+	deadLetterRepo  contracts.DeadLetterRepository
+	scheduledRepo   contracts.ScheduledMessageRepository
 	tracerProvider  *trace.TracerProvider
 }
 
@@ -50,76 +55,120 @@ type ReceiptService interface {
 
 func New(
 	dbConn *sqlx.DB,
-	redisClient *redis.Client,
+	brokerConfig message.BrokerConfig,
 	spreadsheetsService contracts.SpreadsheetsAPI,
 	receiptsService ReceiptService,
 	filesAPI contracts.FilesAPI,
 	deadNationAPI contracts.DeadNationApi,
 	paymentsService contract.PaymentsService,
 	tranportationService contracts.TransportationService,
+	accommodationService contracts.AccommodationService,
+	vipBundleDeadlines process_manager.VipBundleDeadlines,
+	vipBundleTaxiCapacity int,
+	exchangeRates contracts.ExchangeRates,
+	waitlistOfferTTL time.Duration,
 ) Service {
 	tracerProvider := observability.ConfigureTracerProvider()
 
+	// without an accommodation service bundles with a hotel are rejected,
+	// hotel bookings of already stored bundles fail, so the bundles are rolled back
+	hotelBookingEnabled := accommodationService != nil
+	if !hotelBookingEnabled {
+		accommodationService = api.AccommodationUnavailable{}
+	}
+
 	watermillLogger := log.NewWatermill(log.FromContext(context.Background()))
 
-	redisPublisher := message.NewRedisPublisher(redisClient, watermillLogger)
-	redisPublisher = log.CorrelationPublisherDecorator{Publisher: redisPublisher}
-	redisPublisher = observability.TracingPublisherDecorator{Publisher: redisPublisher}
+	broker := message.NewBroker(brokerConfig, watermillLogger)
+
+	scheduledMessageRepo := db.NewScheduledMessageRepository(dbConn)
+	messageScheduler := scheduler.NewScheduler(broker.Publisher(), scheduledMessageRepo)
 
-	redisSubscriber := message.NewRedisSubscriber(redisClient, watermillLogger)
-	eventBus := events.NewEventBus(redisPublisher)
+	// messages sent with delay.WithDeliverAt are stored until their delivery time
+	publisher := scheduler.NewPublisher(broker.Publisher(), scheduledMessageRepo)
+	eventBus := events.NewEventBus(publisher)
 
 	ticketsRepo := db.NewTicketRepository(dbConn)
 	showRepo := db.NewShowRepository(dbConn)
 	bookingRepo := db.NewBookingRepository(dbConn)
-	dataLake := db.NewDataLake(dbConn)
-	opsReadModel := read_model.NewOpsBookingReadModel(dbConn, eventBus)
+	waitlistRepo := db.NewWaitlistRepository(dbConn, waitlistOfferTTL)
+	eventRegistry := events.NewRegistry()
+	dataLake := db.NewDataLake(dbConn, eventRegistry)
+	opsReadModel := read_model.NewOpsBookingReadModel(dbConn)
+	deadLetterRepo := db.NewDeadLetterRepository(dbConn)
+	handlersInbox := inbox.NewInbox(dbConn)
 
 	postgresSubscriber := outbox.NewPostgresSubscriber(dbConn.DB, watermillLogger)
 
 	watermillRouter := message.NewWatermillRouter(
+		eventRegistry,
 		dataLake,
+		deadLetterRepo,
 		postgresSubscriber,
-		redisPublisher,
-		redisSubscriber,
+		scheduler.NewSubscriber(scheduledMessageRepo, watermillLogger),
+		broker,
 		watermillLogger,
 	)
 
 	eventProcessor, err := cqrs.NewEventProcessorWithConfig(
 		watermillRouter,
-		events.NewEventProcessorConfig(redisClient, watermillLogger),
+		events.NewEventProcessorConfig(broker.NewSubscriber, watermillLogger),
 	)
 	if err != nil {
 		panic(err)
 	}
 
-	commandBus := commands.NewCommandBus(redisPublisher)
+	commandBus := commands.NewCommandBus(publisher)
 	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
 		watermillRouter,
-		commands.NewCommandProcessorConfig(redisClient, watermillLogger),
+		commands.NewCommandProcessorConfig(broker.NewSubscriber, watermillLogger),
 	)
 	if err != nil {
 		panic(err)
 	}
 
-	commands.AddCommandProcessorHandlers(commandProcessor, eventBus, bookingRepo, tranportationService, receiptsService, paymentsService)
+	commands.AddCommandProcessorHandlers(commandProcessor, eventBus, commandBus, bookingRepo, ticketsRepo, waitlistRepo, tranportationService, accommodationService, receiptsService, paymentsService, handlersInbox)
 
 	vipBundleRepo := db.NewVipBundleRepository(dbConn)
-	vipBundlePM := process_manager.NewVipBundleProcessManager(commandBus, eventBus, vipBundleRepo)
+	// messages of the process manager are stored in the outbox of the transaction updating the bundle
+	pmPublisher := outbox.NewTxPublisher(publisher)
+	vipBundlePM := process_manager.NewVipBundleProcessManager(
+		commands.NewCommandBus(pmPublisher),
+		events.NewEventBus(pmPublisher),
+		vipBundleRepo,
+		messageScheduler,
+		vipBundleDeadlines,
+		vipBundleTaxiCapacity,
+	)
 
 	events.AddEventProcessorHandlers(
 		eventProcessor,
 		eventBus,
+		commandBus,
 		receiptsService,
 		spreadsheetsService,
 		ticketsRepo,
 		showRepo,
+		bookingRepo,
+		waitlistRepo,
 		filesAPI,
 		deadNationAPI,
-		opsReadModel,
-		vipBundlePM,
+		messageScheduler,
+		handlersInbox,
 	)
 
+	eventGroupProcessor, err := cqrs.NewEventGroupProcessorWithConfig(
+		watermillRouter,
+		events.NewEventGroupProcessorConfig(broker.NewSubscriber, watermillLogger),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := events.AddEventGroupProcessorHandlers(eventGroupProcessor, opsReadModel, vipBundlePM, handlersInbox); err != nil {
+		panic(err)
+	}
+
 	echoRouter := ticketsHttp.NewHttpRouter(
 		eventBus,
 		commandBus,
@@ -127,8 +176,15 @@ func New(
 		ticketsRepo,
 		showRepo,
 		bookingRepo,
+		waitlistRepo,
 		vipBundleRepo,
 		opsReadModel,
+		deadLetterRepo,
+		broker.Publisher(),
+		scheduledMessageRepo,
+		messageScheduler,
+		exchangeRates,
+		hotelBookingEnabled,
 	)
 
 	return Service{
@@ -137,6 +193,8 @@ func New(
 		echoRouter:      echoRouter,
 		dataLake:        dataLake,
 		opsReadModel:    opsReadModel,
+		deadLetterRepo:  deadLetterRepo,
+		scheduledRepo:   scheduledMessageRepo,
 		tracerProvider:  tracerProvider,
 	}
 }
@@ -146,6 +204,18 @@ func (s Service) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to initialize database schema: %w", err)
 	}
 
+	parkedDeadLetters, err := s.deadLetterRepo.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	observability.DeadLettersParked.Set(float64(parkedDeadLetters))
+
+	pendingScheduledMessages, err := s.scheduledRepo.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count scheduled messages: %w", err)
+	}
+	observability.ScheduledMessagesPending.Set(float64(pendingScheduledMessages))
+
 	go func() {
 		if err := migrations.MigrateReadModel(ctx, s.dataLake, s.opsReadModel); err != nil {
 			log.FromContext(ctx).Errorf("failed to migrate read model: %v", err)
@@ -0,0 +1,80 @@
+package tests_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"tickets/db"
+	"tickets/entities"
+	"tickets/message/commands"
+	"tickets/message/event_handlers"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBookingCanceled_before_ticket_confirmed checks that a ticket confirmed after its booking was canceled
+// is refunded with the same key as tickets refunded by the cancellation.
+func TestBookingCanceled_before_ticket_confirmed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := sqlx.Open("postgres", os.Getenv("POSTGRES_URL"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, db.InitializeDatabaseSchema(conn))
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	refunds, err := pubSub.Subscribe(ctx, "commands.RefundTicket")
+	require.NoError(t, err)
+
+	bookingRepo := db.NewBookingRepository(conn)
+
+	showID := uuid.New()
+	require.NoError(t, db.NewShowRepository(conn).Add(ctx, entities.Show{
+		ShowID:          showID,
+		DeadNationID:    uuid.New(),
+		NumberOfTickets: 2,
+		StartTime:       time.Now().Add(time.Hour),
+		Title:           "Example title",
+		Venue:           "Example venue",
+	}))
+
+	bookingID := uuid.New()
+	require.NoError(t, bookingRepo.Add(ctx, entities.Booking{
+		BookingID:       bookingID,
+		ShowID:          showID,
+		NumberOfTickets: 2,
+		CustomerEmail:   "foo@bar.com",
+	}))
+	require.NoError(t, bookingRepo.Cancel(ctx, bookingID))
+
+	ticketID := uuid.NewString()
+	handler := event_handlers.NewStoreTicketHandler(db.NewTicketRepository(conn), bookingRepo, commands.NewCommandBus(pubSub))
+	require.NoError(t, handler.Handle(ctx, &entities.TicketBookingConfirmed_v1{
+		Header:        entities.NewEventHeader(),
+		TicketID:      ticketID,
+		CustomerEmail: "foo@bar.com",
+		Price:         entities.MustNewMoney("50.00", "EUR"),
+		BookingID:     bookingID.String(),
+	}))
+
+	select {
+	case msg := <-refunds:
+		msg.Ack()
+
+		var refund entities.RefundTicket
+		require.NoError(t, json.Unmarshal(msg.Payload, &refund))
+		assert.Equal(t, ticketID, refund.TicketID)
+		assert.Equal(t, entities.NewRefundTicketOfCanceledBooking(bookingID, ticketID).Header.IdempotencyKey, refund.Header.IdempotencyKey)
+	case <-time.After(time.Second * 5):
+		t.Fatal("ticket confirmed after the booking was canceled was not refunded")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"tickets/db"
 	"tickets/entities"
 	"tickets/message"
+	"tickets/process_manager"
 	"tickets/service"
 	"time"
 
@@ -28,8 +29,20 @@ func TestComponent(t *testing.T) {
 	}
 	defer db.Close()
 
-	redisClient := message.NewRedisClient(os.Getenv("REDIS_ADDR"))
-	defer redisClient.Close()
+	// without MESSAGE_BROKER set, the test runs on in-memory GoChannel, so Redis is not required
+	brokerConfig := message.BrokerConfig{
+		Type: message.BrokerType(os.Getenv("MESSAGE_BROKER")),
+		DB:   db.DB,
+	}
+	if brokerConfig.Type == "" {
+		brokerConfig.Type = message.BrokerTypeGoChannel
+	}
+	if brokerConfig.Type == message.BrokerTypeRedis {
+		redisClient := message.NewRedisClient(os.Getenv("REDIS_ADDR"))
+		defer redisClient.Close()
+
+		brokerConfig.RedisClient = redisClient
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -39,17 +52,24 @@ func TestComponent(t *testing.T) {
 	filesAPI := &api.FilesApiMock{}
 	deadNationAPI := &api.DeadNationMock{}
 	paymentsService := &api.PaymentsMock{}
+	transportationService := &api.TransportationMock{}
+	accommodationService := &api.AccommodationMock{}
 
 	go func() {
 		svc := service.New(
 			db,
-			redisClient,
+			brokerConfig,
 			spreadsheetsService,
 			receiptsService,
 			filesAPI,
 			deadNationAPI,
 			paymentsService,
-			nil,
+			transportationService,
+			accommodationService,
+			process_manager.DefaultVipBundleDeadlines(),
+			process_manager.DefaultTaxiCapacity,
+			api.NewFixedExchangeRates(nil),
+			time.Minute,
 		)
 		assert.NoError(t, svc.Run(ctx))
 	}()
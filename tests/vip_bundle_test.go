@@ -0,0 +1,374 @@
+package tests_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"tickets/api"
+	"tickets/db"
+	"tickets/db/util"
+	"tickets/entities"
+	"tickets/message/command_handlers"
+	"tickets/message/commands"
+	"tickets/message/events"
+	"tickets/message/events/outbox"
+	"tickets/message/scheduler"
+	"tickets/process_manager"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVipBundle_redelivered_events delivers every event of the process manager twice.
+// The first delivery is rolled back after the commands were sent, like a handler which didn't manage to ack the message.
+// Commands of both deliveries reach the external services, which have to get the same idempotency keys.
+func TestVipBundle_redelivered_events(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := sqlx.Open("postgres", os.Getenv("POSTGRES_URL"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, db.InitializeDatabaseSchema(conn))
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	eventBus := events.NewEventBus(pubSub)
+	vipBundleRepo := db.NewVipBundleRepository(conn)
+
+	// commands are published right away, so the rolled back first delivery sends them too
+	services := runVipBundleProcessManager(t, ctx, conn, pubSub, vipBundleTestConfig{
+		pmPublisher: pubSub,
+		middleware:  crashOnFirstDelivery(conn),
+	})
+
+	vb := entities.VipBundle{
+		VipBundleID:     uuid.New(),
+		BookingID:       uuid.New(),
+		CustomerEmail:   "vip@example.com",
+		NumberOfTickets: 2,
+		ShowId:          uuid.New(),
+		Passengers:      []string{"John", "Jane"},
+		InboundFlightID: uuid.New(),
+		ReturnFlightID:  uuid.New(),
+		State:           entities.VipBundleStateInitialized,
+	}
+	finalizeVipBundle(t, ctx, eventBus, vipBundleRepo, vb)
+
+	require.NoError(t, eventBus.Publish(ctx, entities.VipBundleCancellationRequested_v1{
+		Header:      entities.NewEventHeader(),
+		VipBundleID: vb.VipBundleID,
+		BookingID:   vb.BookingID,
+	}))
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		vb, err := vipBundleRepo.Get(ctx, vb.VipBundleID)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, entities.VipBundleStateCanceled, vb.State)
+		assert.Len(t, vb.TicketIDs, 2)
+		assert.NotEmpty(t, vb.Compensations)
+		assert.False(t, vb.HasIncompleteRollback())
+
+		assertRedeliveredWithSameKeys(t, 2, lo.Map(services.transportation.FlightBookings, func(r entities.BookFlightTicketRequest, _ int) string {
+			return r.IdempotencyKey
+		}))
+		assertRedeliveredWithSameKeys(t, 1, lo.Map(services.transportation.TaxiBookings, func(r entities.BookTaxiRequest, _ int) string {
+			return r.IdempotencyKey
+		}))
+		assertRedeliveredWithSameKeys(t, 2, lo.Map(services.receipts.VoidedReceipts, func(r entities.VoidReceipt, _ int) string {
+			return r.IdempotencyKey
+		}))
+		assertRedeliveredWithSameKeys(t, 2, lo.Map(services.payments.Refunds, func(r entities.PaymentRefund, _ int) string {
+			return r.IdempotencyKey
+		}))
+		assert.Len(t, lo.Uniq(services.transportation.CanceledFlightTickets), 4)
+		assert.Len(t, lo.Uniq(services.transportation.CanceledTaxiBookings), 1)
+	}, time.Second*30, time.Millisecond*100)
+}
+
+// TestVipBundle_forwarding_fails_after_commit sends messages of the process manager through the outbox
+// and fails the first attempt to forward each of them, after the bundle update was committed.
+// The messages are forwarded again, so the bundle is still finalized.
+func TestVipBundle_forwarding_fails_after_commit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := sqlx.Open("postgres", os.Getenv("POSTGRES_URL"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, db.InitializeDatabaseSchema(conn))
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	eventBus := events.NewEventBus(pubSub)
+	vipBundleRepo := db.NewVipBundleRepository(conn)
+
+	forwarderPublisher := &failFirstPublish{Publisher: pubSub, published: map[string]bool{}}
+	services := runVipBundleProcessManager(t, ctx, conn, pubSub, vipBundleTestConfig{
+		pmPublisher:        outbox.NewTxPublisher(pubSub),
+		forwarderPublisher: forwarderPublisher,
+	})
+
+	vb := entities.VipBundle{
+		VipBundleID:     uuid.New(),
+		BookingID:       uuid.New(),
+		CustomerEmail:   "vip@example.com",
+		NumberOfTickets: 2,
+		ShowId:          uuid.New(),
+		Passengers:      []string{"John", "Jane"},
+		InboundFlightID: uuid.New(),
+		ReturnFlightID:  uuid.New(),
+		State:           entities.VipBundleStateInitialized,
+	}
+	finalizeVipBundle(t, ctx, eventBus, vipBundleRepo, vb)
+
+	assert.NotZero(t, forwarderPublisher.Failed(), "no message failed to be forwarded")
+	assert.Len(t, lo.Uniq(lo.Map(services.transportation.FlightBookings, func(r entities.BookFlightTicketRequest, _ int) string {
+		return r.IdempotencyKey
+	})), 2)
+	assert.Len(t, lo.Uniq(lo.Map(services.transportation.TaxiBookings, func(r entities.BookTaxiRequest, _ int) string {
+		return r.IdempotencyKey
+	})), 1)
+}
+
+const pmHandlerPrefix = "vip_bundle_process_manager."
+
+type vipBundleTestConfig struct {
+	// pmPublisher sends commands and publishes events of the process manager.
+	pmPublisher message.Publisher
+	// forwarderPublisher forwards messages stored in the outbox, the outbox is not forwarded if it's nil.
+	forwarderPublisher message.Publisher
+	middleware         message.HandlerMiddleware
+}
+
+type vipBundleServices struct {
+	transportation *api.TransportationMock
+	receipts       *api.ReceiptsServiceMock
+	payments       *api.PaymentsMock
+}
+
+// runVipBundleProcessManager runs the process manager with handlers of the commands it sends.
+func runVipBundleProcessManager(
+	t *testing.T,
+	ctx context.Context,
+	conn *sqlx.DB,
+	pubSub *gochannel.GoChannel,
+	config vipBundleTestConfig,
+) vipBundleServices {
+	t.Helper()
+
+	logger := watermill.NopLogger{}
+	newSubscriber := func(string) (message.Subscriber, error) {
+		return pubSub, nil
+	}
+
+	// command handlers publish events right away
+	eventBus := events.NewEventBus(pubSub)
+
+	pm := process_manager.NewVipBundleProcessManager(
+		commands.NewCommandBus(config.pmPublisher),
+		events.NewEventBus(config.pmPublisher),
+		db.NewVipBundleRepository(conn),
+		scheduler.NewScheduler(pubSub, db.NewScheduledMessageRepository(conn)),
+		process_manager.DefaultVipBundleDeadlines(),
+		process_manager.DefaultTaxiCapacity,
+	)
+
+	services := vipBundleServices{
+		transportation: &api.TransportationMock{},
+		receipts:       &api.ReceiptsServiceMock{IssuedReceipts: map[string]entities.IssueReceiptRequest{}},
+		payments:       &api.PaymentsMock{},
+	}
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+	if config.middleware != nil {
+		router.AddMiddleware(config.middleware)
+	}
+
+	if config.forwarderPublisher != nil {
+		outbox.AddForwarderHandler(outbox.NewPostgresSubscriber(conn.DB, logger), config.forwarderPublisher, router, logger)
+	}
+
+	router.AddNoPublisherHandler("events_splitter", "events", pubSub, func(msg *message.Message) error {
+		return pubSub.Publish("events."+events.Marshaler.NameFromMessage(msg), msg)
+	})
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(router, commands.NewCommandProcessorConfig(newSubscriber, logger))
+	require.NoError(t, err)
+	require.NoError(t, commandProcessor.AddHandlers(
+		cqrs.NewCommandHandler("BookFlight", command_handlers.NewBookFlightCommandHandler(services.transportation, eventBus).Handle),
+		cqrs.NewCommandHandler("BookTaxi", command_handlers.NewBookTaxiCommandHandler(services.transportation, eventBus).Handle),
+		cqrs.NewCommandHandler("CancelFlightTickets", command_handlers.NewCancelFlightTicketsCommandHandler(services.transportation, eventBus).Handle),
+		cqrs.NewCommandHandler("CancelTaxi", command_handlers.NewCancelTaxiCommandHandler(services.transportation, eventBus).Handle),
+		cqrs.NewCommandHandler("TicketRefund", command_handlers.NewRefundTicketHandler(eventBus, services.receipts, services.payments).Handle),
+	))
+
+	eventProcessor, err := cqrs.NewEventProcessorWithConfig(router, events.NewEventProcessorConfig(newSubscriber, logger))
+	require.NoError(t, err)
+	require.NoError(t, eventProcessor.AddHandlers(
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnVipBundleInitialized", pm.OnVipBundleInitialized),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnBookingMade", pm.OnBookingMade),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnTicketBookingConfirmed", pm.OnTicketBookingConfirmed),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnFlightBooked", pm.OnFlightBooked),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnTaxiBooked", pm.OnTaxiBooked),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnCancellationRequested", pm.OnCancellationRequested),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnTicketRefunded", pm.OnTicketRefunded),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnFlightTicketsCanceled", pm.OnFlightTicketsCanceled),
+		cqrs.NewEventHandler(pmHandlerPrefix+"OnTaxiBookingCanceled", pm.OnTaxiBookingCanceled),
+	))
+
+	go func() {
+		assert.NoError(t, router.Run(ctx))
+	}()
+	<-router.Running()
+
+	return services
+}
+
+// finalizeVipBundle stores the bundle and publishes events which book its show, so it's finalized.
+func finalizeVipBundle(
+	t *testing.T,
+	ctx context.Context,
+	eventBus *cqrs.EventBus,
+	vipBundleRepo *db.VipBundleRepository,
+	vb entities.VipBundle,
+) {
+	t.Helper()
+
+	require.NoError(t, vipBundleRepo.Add(ctx, vb))
+
+	require.NoError(t, eventBus.Publish(ctx, entities.VipBundleInitialized_v1{
+		Header:      entities.NewEventHeader(),
+		VipBundleID: vb.VipBundleID,
+		BookingID:   vb.BookingID,
+	}))
+	assertVipBundleState(t, vipBundleRepo, vb.VipBundleID, entities.VipBundleStateBookingShow)
+
+	require.NoError(t, eventBus.Publish(ctx, entities.BookingMade_v1{
+		Header:          entities.NewEventHeader(),
+		NumberOfTickets: vb.NumberOfTickets,
+		BookingID:       vb.BookingID,
+		CustomerEmail:   vb.CustomerEmail,
+		ShowId:          vb.ShowId,
+	}))
+	for i := 0; i < vb.NumberOfTickets; i++ {
+		require.NoError(t, eventBus.Publish(ctx, entities.TicketBookingConfirmed_v1{
+			Header:        entities.NewEventHeader(),
+			TicketID:      uuid.NewString(),
+			CustomerEmail: vb.CustomerEmail,
+			BookingID:     vb.BookingID.String(),
+		}))
+	}
+	assertVipBundleState(t, vipBundleRepo, vb.VipBundleID, entities.VipBundleStateFinalized)
+}
+
+// failFirstPublish fails the first attempt to publish each message.
+type failFirstPublish struct {
+	message.Publisher
+
+	lock      sync.Mutex
+	published map[string]bool
+	failed    int
+}
+
+func (p *failFirstPublish) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, msg := range messages {
+		if !p.published[msg.UUID] {
+			p.published[msg.UUID] = true
+			p.failed++
+			return errors.New("publishing failed")
+		}
+	}
+
+	return p.Publisher.Publish(topic, messages...)
+}
+
+func (p *failFirstPublish) Failed() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.failed
+}
+
+// crashOnFirstDelivery runs the first delivery of every event of the process manager in a transaction
+// which is rolled back after the handler finishes. Commands sent by the handler are already published,
+// but the message is nacked, so it's delivered again.
+func crashOnFirstDelivery(conn *sqlx.DB) message.HandlerMiddleware {
+	var lock sync.Mutex
+	crashed := map[string]bool{}
+
+	return func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			handlerName := message.HandlerNameFromCtx(msg.Context())
+			if !strings.HasPrefix(handlerName, pmHandlerPrefix) {
+				return h(msg)
+			}
+
+			deliveryKey := handlerName + msg.UUID
+
+			lock.Lock()
+			redelivered := crashed[deliveryKey]
+			lock.Unlock()
+
+			if redelivered {
+				return h(msg)
+			}
+
+			ctx := msg.Context()
+			tx, err := conn.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+			if err != nil {
+				return nil, err
+			}
+			defer tx.Rollback()
+
+			msg.SetContext(util.ContextWithTx(ctx, tx, sql.LevelSerializable))
+			_, err = h(msg)
+			msg.SetContext(ctx)
+			if err != nil {
+				// conflicts with other handlers are retried, the crash happens once the handler succeeds
+				return nil, err
+			}
+
+			lock.Lock()
+			crashed[deliveryKey] = true
+			lock.Unlock()
+
+			return nil, errors.New("crashed before the message was acked")
+		}
+	}
+}
+
+func assertVipBundleState(t *testing.T, repo *db.VipBundleRepository, vipBundleID uuid.UUID, state entities.VipBundleState) {
+	t.Helper()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		vb, err := repo.Get(context.Background(), vipBundleID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, state, vb.State)
+		}
+	}, time.Second*30, time.Millisecond*100)
+}
+
+// assertRedeliveredWithSameKeys checks that each external call was repeated by the redelivery with the same key.
+func assertRedeliveredWithSameKeys(t assert.TestingT, expectedUniqueKeys int, keys []string) {
+	assert.GreaterOrEqual(t, len(keys), expectedUniqueKeys*2, "calls were not repeated: %v", keys)
+	assert.Len(t, lo.Uniq(keys), expectedUniqueKeys, "redelivered event sent commands with different keys: %v", keys)
+}
@@ -0,0 +1,208 @@
+package tests_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"tickets/api"
+	"tickets/db"
+	"tickets/entities"
+	"tickets/message/commands"
+	"tickets/message/events"
+	"tickets/message/events/outbox"
+	"tickets/message/inbox"
+	"tickets/message/scheduler"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWaitlist_freed_seats_offered checks that every event freeing seats of a sold-out show
+// offers them to the waitlist and that the offer expiry is scheduled.
+func TestWaitlist_freed_seats_offered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := sqlx.Open("postgres", os.Getenv("POSTGRES_URL"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, db.InitializeDatabaseSchema(conn))
+
+	logger := watermill.NopLogger{}
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+	newSubscriber := func(string) (message.Subscriber, error) {
+		return pubSub, nil
+	}
+
+	commandBus := commands.NewCommandBus(pubSub)
+	eventBus := events.NewEventBus(pubSub)
+
+	ticketRepo := db.NewTicketRepository(conn)
+	showRepo := db.NewShowRepository(conn)
+	bookingRepo := db.NewBookingRepository(conn)
+	waitlistRepo := db.NewWaitlistRepository(conn, time.Hour)
+	scheduledMessageRepo := db.NewScheduledMessageRepository(conn)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	// events published by the repositories are stored in the outbox first
+	outbox.AddForwarderHandler(outbox.NewPostgresSubscriber(conn.DB, logger), pubSub, router, logger)
+
+	router.AddNoPublisherHandler("events_splitter", "events", pubSub, func(msg *message.Message) error {
+		return pubSub.Publish("events."+events.Marshaler.NameFromMessage(msg), msg)
+	})
+
+	eventProcessor, err := cqrs.NewEventProcessorWithConfig(router, events.NewEventProcessorConfig(newSubscriber, logger))
+	require.NoError(t, err)
+	events.AddEventProcessorHandlers(
+		eventProcessor,
+		eventBus,
+		commandBus,
+		&api.ReceiptsServiceMock{IssuedReceipts: map[string]entities.IssueReceiptRequest{}},
+		&api.SpreadsheetsAPIClientMock{},
+		ticketRepo,
+		showRepo,
+		bookingRepo,
+		waitlistRepo,
+		&api.FilesApiMock{},
+		&api.DeadNationMock{},
+		scheduler.NewScheduler(pubSub, scheduledMessageRepo),
+		inbox.NewInbox(conn),
+	)
+
+	go func() {
+		assert.NoError(t, router.Run(ctx))
+	}()
+	<-router.Running()
+
+	addSoldOutShow := func(t *testing.T) (uuid.UUID, uuid.UUID) {
+		showID := uuid.New()
+		require.NoError(t, showRepo.Add(ctx, entities.Show{
+			ShowID:          showID,
+			DeadNationID:    uuid.New(),
+			NumberOfTickets: 2,
+			StartTime:       time.Now().Add(time.Hour),
+			Title:           "Example title",
+			Venue:           "Example venue",
+		}))
+
+		bookingID := uuid.New()
+		require.NoError(t, bookingRepo.Add(ctx, entities.Booking{
+			BookingID:       bookingID,
+			ShowID:          showID,
+			NumberOfTickets: 2,
+			CustomerEmail:   "foo@bar.com",
+		}))
+
+		return showID, bookingID
+	}
+
+	joinWaitlist := func(t *testing.T, showID uuid.UUID, tickets int) uuid.UUID {
+		entryID := uuid.New()
+		require.NoError(t, waitlistRepo.Add(ctx, entities.WaitlistEntry{
+			EntryID:         entryID,
+			ShowID:          showID,
+			CustomerEmail:   "waiting@bar.com",
+			NumberOfTickets: tickets,
+		}))
+
+		entry, err := waitlistRepo.FindByID(ctx, entryID)
+		require.NoError(t, err)
+		require.Equal(t, entities.WaitlistEntryWaiting, entry.Status)
+
+		return entryID
+	}
+
+	t.Run("booking_canceled", func(t *testing.T) {
+		showID, bookingID := addSoldOutShow(t)
+		entryID := joinWaitlist(t, showID, 2)
+
+		require.NoError(t, bookingRepo.Cancel(ctx, bookingID))
+
+		assertWaitlistOfferExpiryScheduled(t, waitlistRepo, scheduledMessageRepo, entryID)
+	})
+
+	t.Run("show_capacity_increased", func(t *testing.T) {
+		showID, _ := addSoldOutShow(t)
+		entryID := joinWaitlist(t, showID, 2)
+
+		_, err := showRepo.UpdateByID(ctx, showID, func(show entities.Show) (entities.Show, error) {
+			show.NumberOfTickets = 4
+			return show, nil
+		})
+		require.NoError(t, err)
+
+		assertWaitlistOfferExpiryScheduled(t, waitlistRepo, scheduledMessageRepo, entryID)
+	})
+
+	t.Run("ticket_refunded", func(t *testing.T) {
+		showID, bookingID := addSoldOutShow(t)
+		entryID := joinWaitlist(t, showID, 1)
+
+		ticketID := uuid.NewString()
+		require.NoError(t, ticketRepo.Add(ctx, entities.Ticket{
+			TicketID:      ticketID,
+			Price:         entities.MustNewMoney("50.00", "EUR"),
+			CustomerEmail: "foo@bar.com",
+			BookingID:     bookingID.String(),
+		}))
+
+		require.NoError(t, eventBus.Publish(ctx, entities.TicketRefunded_v1{
+			Header:   entities.NewEventHeader(),
+			TicketID: ticketID,
+		}))
+
+		assertWaitlistOfferExpiryScheduled(t, waitlistRepo, scheduledMessageRepo, entryID)
+	})
+}
+
+func assertWaitlistOfferExpiryScheduled(
+	t *testing.T,
+	waitlistRepo db.WaitlistRepository,
+	scheduledMessageRepo db.ScheduledMessageRepository,
+	entryID uuid.UUID,
+) {
+	t.Helper()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		entry, err := waitlistRepo.FindByID(context.Background(), entryID)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if !assert.Equal(t, entities.WaitlistEntryOffered, entry.Status) || !assert.NotNil(t, entry.OfferExpiresAt) {
+			return
+		}
+
+		scheduledMessages, err := scheduledMessageRepo.FindAll(context.Background())
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, scheduledMessage := range scheduledMessages {
+			if scheduledMessage.Topic != "commands.ExpireWaitlistOffer" {
+				continue
+			}
+
+			var cmd entities.ExpireWaitlistOffer
+			if !assert.NoError(t, json.Unmarshal(scheduledMessage.Payload, &cmd)) {
+				return
+			}
+			if cmd.EntryID == entryID {
+				assert.WithinDuration(t, *entry.OfferExpiresAt, scheduledMessage.DeliverAt, time.Millisecond)
+				return
+			}
+		}
+
+		assert.Fail(t, "ExpireWaitlistOffer is not scheduled", "entry %s", entryID)
+	}, time.Second*10, time.Millisecond*100)
+}